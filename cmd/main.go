@@ -2,12 +2,16 @@ package main
 
 import (
 	"context"
-	"flag"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	grpc_recovery "github.com/grpc-ecosystem/go-grpc-middleware/recovery"
 	"github.com/krixlion/dev_forum-lib/cert"
 	"github.com/krixlion/dev_forum-lib/env"
@@ -16,77 +20,100 @@ import (
 	"github.com/krixlion/dev_forum-lib/logging"
 	"github.com/krixlion/dev_forum-lib/tracing"
 	rabbitmq "github.com/krixlion/dev_forum-rabbitmq"
+	"github.com/krixlion/dev_forum-user/pkg/config"
+	"github.com/krixlion/dev_forum-user/pkg/consumer"
+	"github.com/krixlion/dev_forum-user/pkg/domain"
+	"github.com/krixlion/dev_forum-user/pkg/email"
+	"github.com/krixlion/dev_forum-user/pkg/gateway"
+	"github.com/krixlion/dev_forum-user/pkg/grpc/idempotency"
+	"github.com/krixlion/dev_forum-user/pkg/grpc/ratelimit"
 	"github.com/krixlion/dev_forum-user/pkg/grpc/server"
 	pb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-user/pkg/grpc/validation"
+	"github.com/krixlion/dev_forum-user/pkg/health"
+	"github.com/krixlion/dev_forum-user/pkg/metrics"
 	"github.com/krixlion/dev_forum-user/pkg/service"
 	"github.com/krixlion/dev_forum-user/pkg/storage/cockroach"
+	"github.com/krixlion/dev_forum-user/pkg/watch"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/otel"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 )
 
-var port int
-var isTLS bool
-
 // Hardcoded root dir name.
 const projectDir = "app"
 const serviceName = "user-service"
 
-func init() {
-	portFlag := flag.Int("p", 50051, "The gRPC server port")
-	insecureFlag := flag.Bool("insecure", false, "Whether to not use TLS over gRPC")
-	flag.Parse()
-	port = *portFlag
-	isTLS = !(*insecureFlag)
-}
-
 func main() {
 	env.Load(projectDir)
 
+	config, err := config.Load(os.Args[1:])
+	if err != nil {
+		logging.Log("Failed to load config", "err", err)
+		return
+	}
+	logging.Log("Config loaded", "config", config.String())
+
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 
-	deps, err := getServiceDependencies(ctx, serviceName, isTLS)
+	deps, err := getServiceDependencies(ctx, serviceName, config)
 	if err != nil {
 		logging.Log("Failed to initialize service dependencies", "err", err)
 		return
 	}
 
-	service := service.NewUserService(port, deps)
-	service.Run(ctx)
+	serviceConfig := service.Config{
+		GRPCPort:        config.GRPC.Port,
+		GatewayPort:     config.Gateway.Port,
+		HealthPort:      config.Health.Port,
+		HealthService:   pb.UserService_ServiceDesc.ServiceName,
+		ShutdownTimeout: config.Shutdown.Timeout,
+		DrainTimeout:    config.Shutdown.DrainTimeout,
+	}
+
+	service := service.NewUserService(serviceConfig, deps)
+	go service.Run(ctx)
 
 	<-ctx.Done()
+	cancel()
 	logging.Log("Service shutting down")
 
-	defer func() {
-		cancel()
-
-		if err := service.Close(); err != nil {
-			logging.Log("Failed to shutdown service", "err", err)
-			return
-		}
+	if err := service.Close(); err != nil {
+		logging.Log("Failed to shutdown service", "err", err)
+		return
+	}
 
-		logging.Log("Service shutdown successful")
-	}()
+	logging.Log("Service shutdown successful")
 }
 
 // getServiceDependencies is a Composition root.
 // Panics on any non-nil error.
-func getServiceDependencies(ctx context.Context, serviceName string, isTLS bool) (service.Dependencies, error) {
+func getServiceDependencies(ctx context.Context, serviceName string, config config.Config) (service.Dependencies, error) {
+	isTLS := !config.GRPC.Insecure
+
 	serverCreds := insecure.NewCredentials()
+	var gatewayTLS *tls.Config
 	if isTLS {
-		caCertPool, err := cert.LoadCaPool(os.Getenv("TLS_CA_PATH"))
+		caCertPool, err := cert.LoadCaPool(config.TLS.CAPath)
 		if err != nil {
 			return service.Dependencies{}, err
 		}
 
-		serverCert, err := cert.LoadX509KeyPair(os.Getenv("TLS_CERT_PATH"), os.Getenv("TLS_KEY_PATH"))
+		serverCert, err := cert.LoadX509KeyPair(config.TLS.CertPath, config.TLS.KeyPath)
 		if err != nil {
 			return service.Dependencies{}, err
 		}
 
 		serverCreds = cert.NewServerOptionalMTLSCreds(caCertPool, serverCert)
+		gatewayTLS = &tls.Config{
+			Certificates: []tls.Certificate{serverCert},
+			ClientAuth:   tls.VerifyClientCertIfGiven,
+			ClientCAs:    caCertPool,
+		}
 	}
 
 	shutdownTracing, err := tracing.InitProvider(ctx, serviceName)
@@ -101,70 +128,295 @@ func getServiceDependencies(ctx context.Context, serviceName string, isTLS bool)
 		return service.Dependencies{}, err
 	}
 
-	storage, err := cockroach.Make(os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_USER"), os.Getenv("DB_PASS"), os.Getenv("DB_NAME"), tracer)
+	storage, err := cockroach.Make(ctx, config.DB, tracer)
+	if err != nil {
+		return service.Dependencies{}, err
+	}
+
+	if config.Migrate.OnStart {
+		if err := storage.Migrate(ctx, replicaId(), config.Migrate.LockTTL); err != nil {
+			return service.Dependencies{}, err
+		}
+	}
+
+	// Refuse to serve a schema this version of the service does not know about.
+	if err := storage.CheckSchemaVersion(ctx); err != nil {
+		return service.Dependencies{}, err
+	}
+
+	// The tracing provider serves the default registry on config.TracingMetricsPort.
+	metrics, err := metrics.New(prometheus.DefaultRegisterer)
 	if err != nil {
 		return service.Dependencies{}, err
 	}
 
-	consumer := serviceName
+	if err := metrics.RegisterDB(storage.Conn(), config.DB.Name); err != nil {
+		return service.Dependencies{}, err
+	}
+
 	mqConfig := rabbitmq.Config{
-		QueueSize:         100,
-		MaxWorkers:        100,
-		ReconnectInterval: time.Second * 2,
-		MaxRequests:       30,
-		ClearInterval:     time.Second * 5,
-		ClosedTimeout:     time.Second * 15,
+		QueueSize:         config.Broker.QueueSize,
+		MaxWorkers:        config.Broker.MaxWorkers,
+		ReconnectInterval: config.Broker.ReconnectInterval,
+		MaxRequests:       config.Broker.MaxRequests,
+		ClearInterval:     config.Broker.ClearInterval,
+		ClosedTimeout:     config.Broker.ClosedTimeout,
 	}
 
 	messageQueue := rabbitmq.NewRabbitMQ(
-		consumer,
-		os.Getenv("MQ_USER"),
-		os.Getenv("MQ_PASS"),
-		os.Getenv("MQ_HOST"),
-		os.Getenv("MQ_PORT"),
+		serviceName,
+		config.Broker.User,
+		config.Broker.Password.Value(),
+		config.Broker.Host,
+		config.Broker.Port,
 		mqConfig,
 		rabbitmq.WithLogger(logger),
 		rabbitmq.WithTracer(tracer),
 	)
-	broker := broker.NewBroker(messageQueue, logger, tracer)
-	dispatcher := dispatcher.NewDispatcher(20)
+	broker := metrics.InstrumentBroker(broker.NewBroker(messageQueue, logger, tracer))
+	dispatcher := dispatcher.NewDispatcher(config.Dispatcher.MaxWorkers)
+
+	eventConsumer := consumer.New(consumer.Config{
+		Queue:         serviceName,
+		MaxAttempts:   config.Consumer.MaxAttempts,
+		RetryInterval: config.Consumer.RetryInterval,
+		Timeout:       config.Consumer.Timeout,
+	}, consumer.Dependencies{
+		Broker:  broker,
+		Storage: storage,
+		Logger:  logger,
+		Tracer:  tracer,
+	})
+
+	// Every replica consumes user events from queues of its own to notify its watching clients.
+	// The queues outlive the connection, so they're named after the replica to be reused after restarts.
+	watchReplica := config.Watch.Replica
+	if watchReplica == "" {
+		watchReplica = hostname()
+	}
+
+	watches := watch.New(watch.Config{
+		Queue:             fmt.Sprintf("%s-watch.%s", serviceName, watchReplica),
+		BufferSize:        config.Watch.BufferSize,
+		HistorySize:       config.Watch.HistorySize,
+		HeartbeatInterval: config.Watch.HeartbeatInterval,
+	}, watch.Dependencies{
+		Broker: broker,
+		Logger: logger,
+	})
+
+	if err := watches.Consume(ctx); err != nil {
+		return service.Dependencies{}, err
+	}
+
+	eventProviders, err := eventConsumer.Subscribe(ctx)
+	if err != nil {
+		return service.Dependencies{}, err
+	}
+	dispatcher.AddEventProviders(eventProviders...)
+	dispatcher.Register(eventConsumer)
+
+	healthChecker := health.NewChecker(config.Health.CheckInterval, config.Health.CheckTimeout, logger)
+	healthChecker.AddCheck(pb.UserService_ServiceDesc.ServiceName, "database", storage.Ping)
+	healthChecker.AddCheck(pb.UserService_ServiceDesc.ServiceName, "broker", health.AMQPCheck(brokerURL(config.Broker)))
+
+	emailPolicy, err := newEmailPolicy(config.Emails)
+	if err != nil {
+		return service.Dependencies{}, err
+	}
+
+	users := domain.NewUserManager(domain.Config{
+		BcryptCost:        config.Server.BcryptCost,
+		ReservedNames:     config.Names.Reserved,
+		NameReuseCooldown: config.Names.ReuseCooldown,
+		EmailPolicy:       emailPolicy,
+		FoldEmailPlus:     config.Emails.FoldPlus,
+	}, domain.Dependencies{
+		Storage: storage,
+		Broker:  broker,
+		Tracer:  tracer,
+	})
+
+	// Users acting through the gateway are told apart, in the audit log and by idempotency keys,
+	// instead of sharing the gateway's identity.
+	caller := ratelimit.IdentityKey
+	if config.RateLimit.UserHeader != "" {
+		caller = ratelimit.UserKey(config.RateLimit.UserHeader, config.RateLimit.TrustedGateway)
+	}
 
 	userConfig := server.Config{
 		VerifyClientCert: isTLS,
+		RequestTimeout:   config.Server.RequestTimeout,
+		StreamTimeout:    config.Server.StreamTimeout,
+		AdminClient:      config.Server.AdminClient,
+		Actor:            caller,
 	}
 
 	userServer := server.MakeUserServer(server.Dependencies{
-		Storage:    storage,
-		Logger:     logger,
-		Broker:     broker,
-		Tracer:     tracer,
-		Dispatcher: dispatcher,
-		Config:     userConfig,
+		Users:   users,
+		Watches: watches,
+		Logger:  logger,
+		Tracer:  tracer,
+		Config:  userConfig,
 	})
 
+	idempotencyInterceptor := idempotency.New(idempotency.Config{
+		Methods: []string{
+			pb.UserService_Create_FullMethodName,
+			pb.UserService_Update_FullMethodName,
+			pb.UserService_Delete_FullMethodName,
+		},
+		KeyTTL:     config.Idempotency.KeyTTL,
+		PendingTTL: config.Idempotency.PendingTTL,
+		Caller:     caller,
+	}, storage, logger, tracer)
+
+	rateLimiter, err := newRateLimiter(config.RateLimit, logger)
+	if err != nil {
+		return service.Dependencies{}, err
+	}
+
+	// The gateway passes HTTP requests through the same interceptors.
+	// Panics are recovered after being logged, so that they are traced, counted and logged as Internal errors.
+	streamInterceptor := grpc_middleware.ChainStreamServer(
+		otelgrpc.StreamServerInterceptor(),
+		metrics.StreamServerInterceptor(),
+		userServer.LogStreamInterceptor(),
+		grpc_recovery.StreamServerInterceptor(grpc_recovery.WithRecoveryHandlerContext(userServer.RecoverPanic)),
+		rateLimiter.StreamServerInterceptor(),
+		validation.StreamServerInterceptor(),
+	)
+	unaryInterceptor := grpc_middleware.ChainUnaryServer(
+		otelgrpc.UnaryServerInterceptor(),
+		metrics.UnaryServerInterceptor(),
+		userServer.LogRequestInterceptor(),
+		grpc_recovery.UnaryServerInterceptor(grpc_recovery.WithRecoveryHandlerContext(userServer.RecoverPanic)),
+		rateLimiter.UnaryServerInterceptor(),
+		metrics.CountRejections(validation.UnaryServerInterceptor()),
+		idempotencyInterceptor.UnaryServerInterceptor(),
+	)
+
 	grpcServer := grpc.NewServer(
 		grpc.Creds(serverCreds),
-		grpc.StreamInterceptor(otelgrpc.StreamServerInterceptor()),
-		grpc.ChainUnaryInterceptor(
-			grpc_recovery.UnaryServerInterceptor(),
-			otelgrpc.UnaryServerInterceptor(),
-			userServer.ValidateRequestInterceptor(),
-		),
+		grpc.StreamInterceptor(streamInterceptor),
+		grpc.UnaryInterceptor(unaryInterceptor),
 	)
 	reflection.Register(grpcServer)
 	pb.RegisterUserServiceServer(grpcServer, userServer)
+	healthpb.RegisterHealthServer(grpcServer, healthChecker.Server())
+
+	var gatewayHandler http.Handler
+	if config.Gateway.Enabled {
+		gatewayHandler = gateway.New(gateway.Config{
+			ForwardedHeaders: []string{idempotency.MetadataKey, server.RequestIdMetadataKey},
+			VerifiedHeaders:  []string{config.RateLimit.UserHeader},
+		}, gateway.Dependencies{
+			Server:            userServer,
+			UnaryInterceptor:  unaryInterceptor,
+			StreamInterceptor: streamInterceptor,
+			Logger:            logger,
+		}).Handler()
+	}
 
 	closeFunc := func() error {
-		grpcServer.GracefulStop()
 		shutdownTracing()
-		return userServer.Close()
+		return nil
 	}
 
 	return service.Dependencies{
 		Logger:       logger,
 		Dispatcher:   dispatcher,
 		GRPCServer:   grpcServer,
+		Gateway:      gatewayHandler,
+		Watches:      watches,
+		GatewayTLS:   gatewayTLS,
+		Health:       healthChecker,
 		Broker:       broker,
+		Storage:      storage,
 		ShutdownFunc: closeFunc,
 	}, nil
 }
+
+// newRateLimiter returns an interceptor limiting clients in memory of this replica.
+// If rate limiting is disabled, no limits are applied.
+func newRateLimiter(config config.RateLimit, logger logging.Logger) (*ratelimit.Interceptor, error) {
+	limiterConfig := ratelimit.Config{
+		Methods: make(map[string]ratelimit.Limit, len(config.Methods)),
+	}
+
+	if !config.Enabled {
+		return ratelimit.New(limiterConfig, ratelimit.NewMemoryLimiter(), ratelimit.PeerKey, logger), nil
+	}
+
+	limiterConfig.Default = ratelimit.Limit(config.Default)
+
+	methods := make(map[string]bool, len(pb.UserService_ServiceDesc.Methods)+len(pb.UserService_ServiceDesc.Streams))
+	for _, method := range pb.UserService_ServiceDesc.Methods {
+		methods[method.MethodName] = true
+	}
+	for _, stream := range pb.UserService_ServiceDesc.Streams {
+		methods[stream.StreamName] = true
+	}
+
+	for name, limit := range config.Methods {
+		if !methods[name] {
+			return nil, fmt.Errorf("rate limit configured for unknown method %q", name)
+		}
+		limiterConfig.Methods[fmt.Sprintf("/%s/%s", pb.UserService_ServiceDesc.ServiceName, name)] = ratelimit.Limit(limit)
+	}
+
+	key := ratelimit.IdentityKey
+	switch config.Key {
+	case "peer":
+		key = ratelimit.PeerKey
+	case "user":
+		key = ratelimit.UserKey(config.UserHeader, config.TrustedGateway)
+	}
+
+	return ratelimit.New(limiterConfig, ratelimit.NewMemoryLimiter(), key, logger), nil
+}
+
+// newEmailPolicy returns a policy denying the configured domains along with the disposable ones listed in the file.
+func newEmailPolicy(config config.Emails) (email.Policy, error) {
+	denied := config.Denied
+
+	if config.DisposableDomainsFile != "" {
+		file, err := os.Open(config.DisposableDomainsFile)
+		if err != nil {
+			return email.Policy{}, fmt.Errorf("failed to open disposable email domains: %w", err)
+		}
+		defer file.Close()
+
+		disposable, err := email.ReadDomains(file)
+		if err != nil {
+			return email.Policy{}, fmt.Errorf("failed to read disposable email domains: %w", err)
+		}
+		denied = append(disposable, denied...)
+	}
+
+	return email.NewPolicy(config.Allowed, denied)
+}
+
+// replicaId returns an identifier unique to this process of the replica.
+func replicaId() string {
+	return fmt.Sprintf("%s-%d", hostname(), os.Getpid())
+}
+
+func hostname() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return hostname
+}
+
+// brokerURL returns the URL of the broker the service publishes to and consumes from.
+func brokerURL(c config.Broker) string {
+	u := url.URL{
+		Scheme: "amqp",
+		User:   url.UserPassword(c.User, c.Password.Value()),
+		Host:   net.JoinHostPort(c.Host, c.Port),
+		Path:   "/",
+	}
+	return u.String()
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"testing"
 
@@ -8,13 +9,9 @@ import (
 	"go.uber.org/goleak"
 )
 
-// Avoid flags being parsed by the 'go test' before they are defined in the main's init func.
-var _ = func() bool {
-	testing.Init()
-	return true
-}()
-
 func TestMain(m *testing.M) {
+	flag.Parse()
+
 	if !testing.Short() {
 		if err := testdata.Seed(); err != nil {
 			log.Fatalf("Failed to seed before the tests: %v", err)
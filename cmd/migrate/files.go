@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/pressly/goose/v3"
+)
+
+// versionFormat matches the timestamp versions of existing migrations.
+const versionFormat = "20060102150405"
+
+var templates = map[string]*template.Template{
+	"sql": template.Must(template.New("sql").Parse(`-- +goose Up
+
+-- +goose Down
+`)),
+	"go": template.Must(template.New("go").Parse(`package migrations
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigration(up{{.Name}}, down{{.Name}})
+}
+
+func up{{.Name}}(tx *sql.Tx) error {
+	return nil
+}
+
+func down{{.Name}}(tx *sql.Tx) error {
+	return nil
+}
+`)),
+}
+
+type createResult struct {
+	Version int64  `json:"version"`
+	File    string `json:"file"`
+}
+
+// create writes a new migration named after args[0] from the template given in args[1],
+// which is sql by default.
+func create(dir string, args []string, now time.Time) (createResult, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return createResult{}, fmt.Errorf("%w: expected a name and an optional sql or go type", errUsage)
+	}
+
+	name := args[0]
+	kind := "sql"
+	if len(args) == 2 {
+		kind = args[1]
+	}
+
+	tmpl, ok := templates[kind]
+	if !ok {
+		return createResult{}, fmt.Errorf("%w: unknown migration type %q, expected sql or go", errUsage, kind)
+	}
+
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return createResult{}, fmt.Errorf("%w: invalid migration name %q", errUsage, name)
+	}
+
+	var camelName string
+	for _, word := range words {
+		camelName += strings.ToUpper(word[:1]) + strings.ToLower(word[1:])
+	}
+
+	version := now.UTC().Format(versionFormat)
+	file := filepath.Join(dir, fmt.Sprintf("%s_%s.%s", version, strings.ToLower(strings.Join(words, "_")), kind))
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, struct{ Name string }{camelName}); err != nil {
+		return createResult{}, err
+	}
+
+	// Never overwrite existing migrations.
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return createResult{}, fmt.Errorf("failed to create migration file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return createResult{}, fmt.Errorf("failed to write migration file: %w", err)
+	}
+
+	v, err := goose.NumericComponent(file)
+	if err != nil {
+		return createResult{}, err
+	}
+
+	return createResult{Version: v, File: file}, nil
+}
+
+type validateResult struct {
+	Valid      bool     `json:"valid"`
+	Migrations int      `json:"migrations"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+var errInvalidMigrations = errors.New("invalid migrations")
+
+// goPackageFile is the Go file of the migrations package which is not a migration.
+const goPackageFile = "migration.go"
+
+// validate checks that SQL and Go migrations in the root of fsys have unique versions
+// and SQL ones well-formed goose annotations. Violations are reported in the result.
+func validate(fsys fs.FS) (validateResult, error) {
+	sqlFiles, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return validateResult{}, err
+	}
+
+	goFiles, err := fs.Glob(fsys, "*.go")
+	if err != nil {
+		return validateResult{}, err
+	}
+
+	files := sqlFiles
+	for _, file := range goFiles {
+		if file != goPackageFile && !strings.HasSuffix(file, "_test.go") {
+			files = append(files, file)
+		}
+	}
+
+	result := validateResult{Migrations: len(files)}
+	versions := map[int64]string{}
+
+	for _, file := range files {
+		version, err := goose.NumericComponent(file)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", file, err))
+			continue
+		}
+
+		if other, ok := versions[version]; ok {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: version %d is already used by %s", file, version, other))
+		}
+		versions[version] = file
+
+		if path.Ext(file) != ".sql" {
+			continue
+		}
+
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return validateResult{}, err
+		}
+
+		for _, violation := range checkAnnotations(string(content)) {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", path.Base(file), violation))
+		}
+	}
+
+	result.Valid = len(result.Errors) == 0
+	return result, nil
+}
+
+// checkAnnotations returns violations of the goose annotation rules in an SQL migration.
+func checkAnnotations(content string) []string {
+	var violations []string
+	var up, down, inStatement bool
+
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "-- +goose") {
+			continue
+		}
+
+		switch annotation := strings.Fields(strings.TrimPrefix(line, "-- +goose")); {
+		case len(annotation) == 0:
+			violations = append(violations, fmt.Sprintf("line %d: empty annotation", i+1))
+		case annotation[0] == "Up":
+			if up {
+				violations = append(violations, fmt.Sprintf("line %d: duplicate Up annotation", i+1))
+			}
+			if down {
+				violations = append(violations, fmt.Sprintf("line %d: Up annotation after Down", i+1))
+			}
+			up = true
+		case annotation[0] == "Down":
+			if down {
+				violations = append(violations, fmt.Sprintf("line %d: duplicate Down annotation", i+1))
+			}
+			down = true
+		case annotation[0] == "StatementBegin":
+			if inStatement {
+				violations = append(violations, fmt.Sprintf("line %d: nested StatementBegin", i+1))
+			}
+			inStatement = true
+		case annotation[0] == "StatementEnd":
+			if !inStatement {
+				violations = append(violations, fmt.Sprintf("line %d: StatementEnd without StatementBegin", i+1))
+			}
+			inStatement = false
+		case annotation[0] == "NO" && len(annotation) > 1 && annotation[1] == "TRANSACTION":
+		default:
+			violations = append(violations, fmt.Sprintf("line %d: unknown annotation %q", i+1, line))
+		}
+	}
+
+	if !up {
+		violations = append(violations, "missing Up annotation")
+	}
+
+	if inStatement {
+		violations = append(violations, "unterminated StatementBegin")
+	}
+
+	return violations
+}
@@ -0,0 +1,264 @@
+// Command migrate manages the database schema using the migrations embedded in the service.
+//
+// Usage:
+//
+//	migrate [-config path] [-dir path] <command> [args]
+//
+// Commands:
+//
+//	up                      apply all pending migrations
+//	up-to <version>         apply pending migrations up to and including the version
+//	down                    roll back the latest applied migration
+//	down-to <version>       roll back migrations newer than the version
+//	redo                    roll back the latest applied migration and apply it again
+//	status                  show the state of every migration
+//	version                 show the current and the latest known schema version
+//	create <name> [sql|go]  create a new migration in the -dir directory
+//	validate                check the embedded migrations without connecting to the database
+//
+// Every command prints a single JSON object to stdout, including failures
+// which additionally exit with a non-zero code. Goose logs are written to stderr.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/env"
+	"github.com/krixlion/dev_forum-user/migrations"
+	"github.com/krixlion/dev_forum-user/pkg/config"
+	"github.com/krixlion/dev_forum-user/pkg/storage/cockroach"
+	"github.com/pressly/goose/v3"
+	"go.opentelemetry.io/otel"
+)
+
+const usage = `Usage: migrate [-config path] [-dir path] <command> [args]
+
+Commands: up, up-to <version>, down, down-to <version>, redo, status, version, create <name> [sql|go], validate
+`
+
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdout); err != nil {
+		os.Exit(1)
+	}
+}
+
+// run executes the command given in args and writes its result to out.
+func run(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", "", "Path to the YAML config file")
+	dir := fs.String("dir", "migrations", "Directory to create new migrations in")
+
+	if err := fs.Parse(args); err != nil {
+		return writeResult(out, nil, err)
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return writeResult(out, nil, fmt.Errorf("%w: missing command", errUsage))
+	}
+
+	command, args := fs.Arg(0), fs.Args()[1:]
+
+	// Commands which don't need the database.
+	switch command {
+	case "create":
+		result, err := create(*dir, args, time.Now())
+		return writeResult(out, result, err)
+	case "validate":
+		result, err := validate(migrations.EmbedPath)
+		if err == nil && !result.Valid {
+			// Report the violations instead of a generic error.
+			writeResult(out, result, nil)
+			return errInvalidMigrations
+		}
+		return writeResult(out, result, err)
+	}
+
+	env.Load("app")
+
+	var configArgs []string
+	if *configPath != "" {
+		configArgs = []string{"-config", *configPath}
+	}
+
+	dbConfig, err := config.LoadDB(configArgs)
+	if err != nil {
+		return writeResult(out, nil, fmt.Errorf("failed to load config: %w", err))
+	}
+
+	tracer := otel.Tracer("user-service")
+	ctx, span := tracer.Start(ctx, "Migrate")
+	defer span.End()
+
+	storage, err := cockroach.Make(ctx, dbConfig, tracer)
+	if err != nil {
+		return writeResult(out, nil, fmt.Errorf("failed to make DB: %w", err))
+	}
+	defer storage.Close()
+
+	result, err := runDBCommand(ctx, storage, command, args)
+	return writeResult(out, result, err)
+}
+
+// migrationResult is the output of commands changing the schema.
+type migrationResult struct {
+	Command     string `json:"command"`
+	FromVersion int64  `json:"from_version"`
+	ToVersion   int64  `json:"to_version"`
+	Pending     int    `json:"pending"`
+}
+
+type migrationStatus struct {
+	Version   int64      `json:"version"`
+	Source    string     `json:"source"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+type statusResult struct {
+	CurrentVersion int64             `json:"current_version"`
+	LatestVersion  int64             `json:"latest_version"`
+	Pending        int               `json:"pending"`
+	Migrations     []migrationStatus `json:"migrations"`
+}
+
+type versionResult struct {
+	CurrentVersion int64 `json:"current_version"`
+	LatestVersion  int64 `json:"latest_version"`
+	Pending        int   `json:"pending"`
+}
+
+func runDBCommand(ctx context.Context, storage cockroach.CockroachDB, command string, args []string) (interface{}, error) {
+	var migrate func(*sql.DB) error
+
+	switch command {
+	case "status":
+		return status(ctx, storage)
+	case "version":
+		s, err := status(ctx, storage)
+		if err != nil {
+			return nil, err
+		}
+		return versionResult{CurrentVersion: s.CurrentVersion, LatestVersion: s.LatestVersion, Pending: s.Pending}, nil
+	case "up":
+		migrate = func(db *sql.DB) error { return goose.Up(db, ".") }
+	case "down":
+		migrate = func(db *sql.DB) error { return goose.Down(db, ".") }
+	case "redo":
+		migrate = func(db *sql.DB) error { return goose.Redo(db, ".") }
+	case "up-to", "down-to":
+		version, err := parseVersion(args)
+		if err != nil {
+			return nil, err
+		}
+
+		migrate = func(db *sql.DB) error { return goose.UpTo(db, ".", version) }
+		if command == "down-to" {
+			migrate = func(db *sql.DB) error { return goose.DownTo(db, ".", version) }
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown command %q", errUsage, command)
+	}
+
+	from, err := storage.SchemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	owner := fmt.Sprintf("migrate-%d", os.Getpid())
+	if err := storage.WithMigrationLock(ctx, owner, config.Default().Migrate.LockTTL, migrate); err != nil {
+		return nil, err
+	}
+
+	s, err := status(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return migrationResult{Command: command, FromVersion: from, ToVersion: s.CurrentVersion, Pending: s.Pending}, nil
+}
+
+func status(ctx context.Context, storage cockroach.CockroachDB) (statusResult, error) {
+	states, err := storage.MigrationStates(ctx)
+	if err != nil {
+		return statusResult{}, err
+	}
+
+	current, err := storage.SchemaVersion(ctx)
+	if err != nil {
+		return statusResult{}, err
+	}
+
+	result := statusResult{
+		CurrentVersion: current,
+		Migrations:     make([]migrationStatus, 0, len(states)),
+	}
+
+	for _, state := range states {
+		s := migrationStatus{
+			Version: state.Version,
+			Source:  state.Source,
+			Applied: state.Applied,
+		}
+
+		if state.Applied {
+			appliedAt := state.AppliedAt
+			s.AppliedAt = &appliedAt
+		} else {
+			result.Pending++
+		}
+
+		result.Migrations = append(result.Migrations, s)
+		result.LatestVersion = state.Version
+	}
+
+	return result, nil
+}
+
+func parseVersion(args []string) (int64, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("%w: expected a single version argument", errUsage)
+	}
+
+	version, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("%w: invalid version %q", errUsage, args[0])
+	}
+
+	return version, nil
+}
+
+type errorResult struct {
+	Error string `json:"error"`
+}
+
+// writeResult writes the result or the error as JSON to out and returns the error.
+func writeResult(out io.Writer, result interface{}, err error) error {
+	if err != nil {
+		result = errorResult{Error: err.Error()}
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if encodeErr := encoder.Encode(result); encodeErr != nil {
+		return errors.Join(err, encodeErr)
+	}
+
+	return err
+}
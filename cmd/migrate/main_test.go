@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_run(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "Test if validates embedded migrations",
+			args: []string{"validate"},
+			want: `"valid": true`,
+		},
+		{
+			name:    "Test if fails on missing command",
+			args:    []string{},
+			want:    `"error": "invalid usage: missing command"`,
+			wantErr: errUsage,
+		},
+		{
+			name:    "Test if fails on invalid create args",
+			args:    []string{"-dir", t.TempDir(), "create"},
+			want:    `"error"`,
+			wantErr: errUsage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+
+			err := run(context.Background(), tt.args, out)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !json.Valid(out.Bytes()) {
+				t.Errorf("run() output is not valid JSON:\n%s", out)
+			}
+
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("run() output does not contain %q:\n%s", tt.want, out)
+			}
+		})
+	}
+}
+
+func Test_create(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 30, 45, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		args         []string
+		wantFile     string
+		wantContains string
+		wantErr      bool
+	}{
+		{
+			name:         "Test if creates sql migrations by default",
+			args:         []string{"add users bio"},
+			wantFile:     "20261019123045_add_users_bio.sql",
+			wantContains: "-- +goose Up",
+		},
+		{
+			name:         "Test if creates go migrations",
+			args:         []string{"backfill-emails", "go"},
+			wantFile:     "20261019123045_backfill_emails.go",
+			wantContains: "goose.AddMigration(upBackfillEmails, downBackfillEmails)",
+		},
+		{
+			name:    "Test if fails on unknown type",
+			args:    []string{"add_index", "yaml"},
+			wantErr: true,
+		},
+		{
+			name:    "Test if fails on name without letters or digits",
+			args:    []string{"--"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			got, err := create(dir, tt.args, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("create() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			want := createResult{Version: 20261019123045, File: filepath.Join(dir, tt.wantFile)}
+			if !cmp.Equal(got, want) {
+				t.Errorf("create():\n got = %v\n want = %v", got, want)
+				return
+			}
+
+			content, err := os.ReadFile(got.File)
+			if err != nil {
+				t.Errorf("Failed to read created file: %v", err)
+				return
+			}
+
+			if !strings.Contains(string(content), tt.wantContains) {
+				t.Errorf("create() file does not contain %q:\n%s", tt.wantContains, content)
+			}
+
+			if _, err := create(dir, tt.args, now); err == nil {
+				t.Errorf("create() overwrote an existing migration")
+			}
+		})
+	}
+}
+
+func Test_validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		fsys       fstest.MapFS
+		wantErrors []string
+	}{
+		{
+			name: "Test if passes on valid migrations",
+			fsys: fstest.MapFS{
+				"1_create.sql": {Data: []byte("-- +goose Up\nCREATE TABLE a (id INT);\n-- +goose Down\nDROP TABLE a;\n")},
+				"2_func.sql":   {Data: []byte("-- +goose Up\n-- +goose StatementBegin\nSELECT 1;\n-- +goose StatementEnd\n")},
+			},
+		},
+		{
+			name: "Test if reports duplicate versions",
+			fsys: fstest.MapFS{
+				"1_a.sql": {Data: []byte("-- +goose Up\n")},
+				"1_b.sql": {Data: []byte("-- +goose Up\n")},
+			},
+			wantErrors: []string{"version 1 is already used"},
+		},
+		{
+			name: "Test if reports versions used by SQL and Go migrations",
+			fsys: fstest.MapFS{
+				"1_a.sql":      {Data: []byte("-- +goose Up\n")},
+				"1_b.go":       {Data: []byte("package migrations\n")},
+				"migration.go": {Data: []byte("package migrations\n")},
+			},
+			wantErrors: []string{"version 1 is already used"},
+		},
+		{
+			name: "Test if skips the package file and does not check annotations of Go migrations",
+			fsys: fstest.MapFS{
+				"1_a.sql":      {Data: []byte("-- +goose Up\n")},
+				"2_b.go":       {Data: []byte("package migrations\n")},
+				"migration.go": {Data: []byte("package migrations\n")},
+			},
+		},
+		{
+			name: "Test if reports malformed annotations",
+			fsys: fstest.MapFS{
+				"1_a.sql": {Data: []byte("-- +goose Down\n-- +goose StatementBegin\nSELECT 1;\n-- +goose Upp\n")},
+			},
+			wantErrors: []string{"missing Up annotation", "unterminated StatementBegin", "unknown annotation"},
+		},
+		{
+			name: "Test if reports files without versions",
+			fsys: fstest.MapFS{
+				"create.sql": {Data: []byte("-- +goose Up\n")},
+			},
+			wantErrors: []string{"create.sql"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validate(tt.fsys)
+			if err != nil {
+				t.Errorf("validate() error = %v", err)
+				return
+			}
+
+			if got.Valid != (len(tt.wantErrors) == 0) {
+				t.Errorf("validate() valid = %v, errors = %v", got.Valid, got.Errors)
+				return
+			}
+
+			for _, want := range tt.wantErrors {
+				if !strings.Contains(strings.Join(got.Errors, "\n"), want) {
+					t.Errorf("validate() errors = %v, do not mention %q", got.Errors, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_parseVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    int64
+		wantErr bool
+	}{
+		{
+			name: "Test if parses version",
+			args: []string{"20230125193639"},
+			want: 20230125193639,
+		},
+		{
+			name:    "Test if fails on missing version",
+			wantErr: true,
+		},
+		{
+			name:    "Test if fails on negative version",
+			args:    []string{"-1"},
+			wantErr: true,
+		},
+		{
+			name:    "Test if fails on extra args",
+			args:    []string{"1", "2"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseVersion(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseVersion() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("parseVersion():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+-- +goose Up
+CREATE INDEX IF NOT EXISTS users_name_trgm_idx ON "users" USING GIN (name gin_trgm_ops);
+
+-- +goose Down
+DROP INDEX IF EXISTS "users"@users_name_trgm_idx;
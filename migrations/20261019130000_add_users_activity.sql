@@ -0,0 +1,23 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS last_login_at TIMESTAMP NULL;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS post_count INT NOT NULL DEFAULT 0;
+
+-- Articles of every user, so that deleted articles can be attributed to their authors.
+CREATE TABLE IF NOT EXISTS "user_articles" (
+    article_id VARCHAR PRIMARY KEY,
+    user_id VARCHAR NOT NULL,
+    INDEX user_articles_user_id_idx (user_id)
+);
+
+-- Ids of consumed events used to apply every event only once.
+CREATE TABLE IF NOT EXISTS "processed_events" (
+    id VARCHAR PRIMARY KEY,
+    processed_at TIMESTAMP NOT NULL DEFAULT current_timestamp()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS "processed_events";
+DROP TABLE IF EXISTS "user_articles";
+ALTER TABLE "users" DROP COLUMN IF EXISTS post_count;
+ALTER TABLE "users" DROP COLUMN IF EXISTS last_login_at;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Responses of mutating RPCs replayed for retries carrying the same idempotency key.
+-- Expired rows are ignored by the service and deleted by the row-level TTL job.
+CREATE TABLE IF NOT EXISTS "idempotency_keys" (
+    key VARCHAR PRIMARY KEY,
+    fingerprint BYTES NOT NULL,
+    response BYTES NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+) WITH (ttl_expiration_expression = 'expires_at', ttl_job_cron = '@hourly');
+
+-- +goose Down
+DROP TABLE IF EXISTS "idempotency_keys";
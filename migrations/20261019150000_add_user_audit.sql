@@ -0,0 +1,17 @@
+-- +goose Up
+-- Append-only log of changes of users, written in the same transaction as the changes.
+-- Entries outlive deleted users, so user_id does not reference "users".
+CREATE TABLE IF NOT EXISTS "user_audit" (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id VARCHAR NOT NULL,
+    actor VARCHAR NOT NULL,
+    action VARCHAR NOT NULL,
+    -- Field-level diff with values of sensitive fields redacted.
+    changes JSONB NOT NULL,
+    request_id VARCHAR NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp(),
+    INDEX user_audit_user_id_created_at_idx (user_id, created_at, id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS "user_audit";
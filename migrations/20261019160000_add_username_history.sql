@@ -0,0 +1,20 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+-- Names which look alike share a skeleton, see username.Skeleton.
+-- It's filled in for existing users by the next migration.
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS name_skeleton VARCHAR NULL;
+
+-- Names released by renamed, deleted and erased users, so that others cannot take them right away.
+CREATE TABLE IF NOT EXISTS "username_history" (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id VARCHAR NOT NULL,
+    name VARCHAR NOT NULL,
+    skeleton VARCHAR NOT NULL,
+    released_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp(),
+    INDEX username_history_skeleton_released_at_idx (skeleton, released_at),
+    INDEX username_history_user_id_idx (user_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS "username_history";
+ALTER TABLE "users" DROP COLUMN IF EXISTS name_skeleton;
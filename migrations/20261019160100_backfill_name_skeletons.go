@@ -0,0 +1,100 @@
+package migrations
+
+import (
+	"database/sql"
+	"strings"
+	"unicode"
+
+	"github.com/pressly/goose/v3"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+func init() {
+	goose.AddMigrationNoTx(backfillNameSkeletons, nil)
+}
+
+// backfillBatchSize limits users read at once while backfilling.
+const backfillBatchSize = 1000
+
+// backfillNameSkeletons computes skeletons of existing users, which cannot be done in SQL.
+// Users whose names look like the name of a user with a lower id get the skeleton followed by
+// a space and their id, so that the unique index can be created. Skeletons never contain spaces,
+// so no name gets such a skeleton, while the name is still protected by the other user's skeleton.
+func backfillNameSkeletons(db *sql.DB) error {
+	lastId := ""
+	for {
+		rows, err := db.Query(`SELECT id, name FROM "users" WHERE name_skeleton IS NULL AND id > $1 ORDER BY id LIMIT $2`, lastId, backfillBatchSize)
+		if err != nil {
+			return err
+		}
+
+		// Kept in order, so that users with lower ids get their skeletons first.
+		var ids, names []string
+		for rows.Next() {
+			var id, name string
+			if err := rows.Scan(&id, &name); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+			names = append(names, name)
+			lastId = id
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for i, id := range ids {
+			skeleton := nameSkeleton(names[i])
+			if _, err := db.Exec(`UPDATE "users" SET name_skeleton = CASE
+				WHEN EXISTS (SELECT 1 FROM "users" WHERE name_skeleton = $2) THEN $3 ELSE $2 END WHERE id = $1`, id, skeleton, skeleton+" "+id); err != nil {
+				return err
+			}
+		}
+
+		if len(ids) < backfillBatchSize {
+			return nil
+		}
+	}
+}
+
+// nameConfusables, nameSequences and nameSkeleton are a copy of username.Skeleton
+// as of this migration, which the service's skeletons have to match.
+// Migrations of names use it, so that they keep working when username.Skeleton changes.
+var nameConfusables = map[rune]string{
+	// Digits and symbols.
+	'0': "o", '1': "i", '|': "i", '$': "s", '@': "a",
+	// Latin.
+	'l': "i", 'ı': "i", 'ɩ': "i", 'ɑ': "a", 'ɡ': "g", 'ʋ': "u", 'ø': "o", 'ð': "d", 'ß': "ss",
+	// Greek.
+	'α': "a", 'β': "b", 'γ': "y", 'ε': "e", 'η': "n", 'ι': "i", 'κ': "k", 'ν': "v",
+	'ο': "o", 'ρ': "p", 'τ': "t", 'υ': "u", 'χ': "x", 'ω': "w",
+	// Cyrillic.
+	'а': "a", 'в': "b", 'г': "r", 'е': "e", 'з': "3", 'к': "k", 'м': "m",
+	'н': "h", 'о': "o", 'п': "n", 'р': "p", 'с': "c", 'т': "t", 'у': "y", 'х': "x", 'ь': "b",
+	'ѕ': "s", 'і': "i", 'ј': "j", 'һ': "h", 'ԁ': "d", 'ԛ': "q", 'ԝ': "w", 'ү': "y", 'ӏ': "i",
+}
+
+var nameSequences = strings.NewReplacer("rn", "m", "vv", "w")
+
+func nameSkeleton(name string) string {
+	name = cases.Fold().String(norm.NFKC.String(name))
+
+	var b strings.Builder
+	for _, r := range norm.NFD.String(name) {
+		switch {
+		case unicode.Is(unicode.Mn, r), unicode.Is(unicode.Cf, r), unicode.IsSpace(r):
+			continue
+		}
+
+		if s, ok := nameConfusables[r]; ok {
+			b.WriteString(s)
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return nameSequences.Replace(b.String())
+}
@@ -0,0 +1,8 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+-- Stops users from taking names which look like names of others.
+-- Look-alike names of existing users got distinct skeletons from the backfill.
+CREATE UNIQUE INDEX IF NOT EXISTS users_name_skeleton_key ON "users" (name_skeleton);
+
+-- +goose Down
+DROP INDEX IF EXISTS "users"@users_name_skeleton_key;
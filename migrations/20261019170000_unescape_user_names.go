@@ -0,0 +1,90 @@
+package migrations
+
+import (
+	"database/sql"
+	"html"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationNoTx(unescapeUserNames, nil)
+}
+
+// unescapeUserNames reverts HTML escaping of names, which used to be done before storing them
+// and is now done by the gateway on output. All escaped characters start with '&'.
+// Users whose unescaped names would be taken by, or look like the name of, another user
+// keep their escaped names, so that the unique constraints hold.
+func unescapeUserNames(db *sql.DB) error {
+	lastId := ""
+	for {
+		rows, err := db.Query(`SELECT id, name FROM "users" WHERE name LIKE '%&%' AND id > $1 ORDER BY id LIMIT $2`, lastId, backfillBatchSize)
+		if err != nil {
+			return err
+		}
+
+		// Kept in order, so that users with lower ids get their unescaped names first.
+		var ids, names []string
+		for rows.Next() {
+			var id, name string
+			if err := rows.Scan(&id, &name); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+			names = append(names, name)
+			lastId = id
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for i, id := range ids {
+			name := names[i]
+			unescaped := html.UnescapeString(name)
+			if unescaped == name {
+				continue
+			}
+
+			skeleton := nameSkeleton(unescaped)
+			if _, err := db.Exec(`UPDATE "users" SET name = $2, name_skeleton = $3 WHERE id = $1
+				AND NOT EXISTS (SELECT 1 FROM "users" WHERE id <> $1 AND (name = $2 OR name_skeleton = $3))`, id, unescaped, skeleton); err != nil {
+				return err
+			}
+		}
+
+		if len(ids) < backfillBatchSize {
+			break
+		}
+	}
+
+	// Released names are only compared by skeletons, but are unescaped as well to stay readable.
+	rows, err := db.Query(`SELECT id, name FROM "username_history" WHERE name LIKE '%&%'`)
+	if err != nil {
+		return err
+	}
+
+	names := map[string]string{}
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return err
+		}
+		names[id] = name
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, name := range names {
+		unescaped := html.UnescapeString(name)
+		if _, err := db.Exec(`UPDATE "username_history" SET name = $2, skeleton = $3 WHERE id = $1`, id, unescaped, nameSkeleton(unescaped)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,13 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+-- Addresses delivered to the same mailbox share a key, see email.Key.
+-- It's filled in for existing users by the next migration.
+-- Neither column is unique, since existing users may share addresses.
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS email_key VARCHAR NULL;
+CREATE INDEX IF NOT EXISTS users_email_idx ON "users" (email);
+CREATE INDEX IF NOT EXISTS users_email_key_idx ON "users" (email_key);
+
+-- +goose Down
+DROP INDEX IF EXISTS "users"@users_email_key_idx;
+DROP INDEX IF EXISTS "users"@users_email_idx;
+ALTER TABLE "users" DROP COLUMN IF EXISTS email_key;
@@ -0,0 +1,89 @@
+package migrations
+
+import (
+	"database/sql"
+	"errors"
+	"net/mail"
+	"strings"
+
+	"github.com/pressly/goose/v3"
+	"golang.org/x/net/idna"
+)
+
+func init() {
+	goose.AddMigrationNoTx(canonicalizeEmails, nil)
+}
+
+// canonicalizeEmails replaces emails of existing users with their canonical forms,
+// eg. stripping display names, which used to be stored, and fills in their keys,
+// which are the canonical addresses, as if plus-addressing was not folded.
+// Emails which are not valid, such as hashes of erased users, are kept and get no keys.
+// Neither do emails whose keys a user with a lower id got first, so that the keys can be made unique.
+func canonicalizeEmails(db *sql.DB) error {
+	lastId := ""
+	for {
+		rows, err := db.Query(`SELECT id, email FROM "users" WHERE email_key IS NULL AND id > $1 ORDER BY id LIMIT $2`, lastId, backfillBatchSize)
+		if err != nil {
+			return err
+		}
+
+		// Kept in order, so that users with lower ids get their keys first.
+		var ids, emails []string
+		for rows.Next() {
+			var id, address string
+			if err := rows.Scan(&id, &address); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+			emails = append(emails, address)
+			lastId = id
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for i, id := range ids {
+			canonical, err := canonicalEmail(emails[i])
+			if err != nil {
+				continue
+			}
+
+			if _, err := db.Exec(`UPDATE "users" SET email = $2, email_key = CASE
+				WHEN NOT EXISTS (SELECT 1 FROM "users" WHERE id <> $1 AND email_key = $2) THEN $2 END WHERE id = $1`, id, canonical); err != nil {
+				return err
+			}
+		}
+
+		if len(ids) < backfillBatchSize {
+			return nil
+		}
+	}
+}
+
+// canonicalEmail is a copy of email.Canonicalize as of this migration,
+// so that the migration keeps working when email.Canonicalize changes.
+func canonicalEmail(address string) (string, error) {
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return "", err
+	}
+
+	at := strings.LastIndexByte(parsed.Address, '@')
+	domain, err := idna.Lookup.ToASCII(parsed.Address[at+1:])
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.Contains(domain, ".") {
+		return "", errors.New("domain must have a top-level domain")
+	}
+
+	canonical := parsed.Address[:at] + "@" + domain
+	if reparsed, err := mail.ParseAddress(canonical); err != nil || reparsed.Address != canonical {
+		return "", errors.New("local part must not need quoting")
+	}
+
+	return canonical, nil
+}
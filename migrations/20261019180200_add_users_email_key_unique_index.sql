@@ -0,0 +1,10 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+-- Stops users from taking emails with the same keys at the same time.
+-- Multiple NULL keys, left by the backfill for emails whose keys were taken, are allowed.
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_key_key ON "users" (email_key);
+DROP INDEX IF EXISTS "users"@users_email_key_idx;
+
+-- +goose Down
+CREATE INDEX IF NOT EXISTS users_email_key_idx ON "users" (email_key);
+DROP INDEX IF EXISTS "users"@users_email_key_key;
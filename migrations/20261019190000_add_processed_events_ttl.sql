@@ -0,0 +1,8 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+-- Ids of processed events are needed only as long as the events can be redelivered,
+-- which publishers retrying with backoff do within minutes.
+ALTER TABLE "processed_events" SET (ttl_expire_after = '7 days', ttl_job_cron = '@daily');
+
+-- +goose Down
+ALTER TABLE "processed_events" RESET (ttl);
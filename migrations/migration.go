@@ -1,9 +1,15 @@
-// This package is here only to allow other packages to use its
+// This package is here to allow other packages to use its
 // embeded FS without worrying about directing to parent directories
 // and trying to find workarounds since relative paths are not allowed.
+//
+// Migrations which cannot be written in SQL are registered with goose
+// by the Go files of this package, so it has to be imported wherever migrations run.
 package migrations
 
 import "embed"
 
-//go:embed *.sql
+// EmbedPath holds the SQL migrations along with sources of the Go ones,
+// so that versions of both can be validated. Goose skips Go files of registered migrations.
+//
+//go:embed *.sql *.go
 var EmbedPath embed.FS
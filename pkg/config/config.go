@@ -0,0 +1,531 @@
+// Package config loads the service configuration from defaults,
+// an optional YAML file, environment variables and command-line flags.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[REDACTED]"
+
+// TracingMetricsPort is bound by the Prometheus server which tracing.InitProvider starts
+// and never stops. It serves the service's metrics, so no other server may use it.
+const TracingMetricsPort = 2223
+
+// Secret is a string which is never revealed when formatted or marshaled.
+// Use Value to access the underlying string.
+type Secret string
+
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+func (s Secret) MarshalYAML() (interface{}, error) {
+	return s.String(), nil
+}
+
+type Config struct {
+	GRPC        GRPC        `yaml:"grpc"`
+	Gateway     Gateway     `yaml:"gateway"`
+	TLS         TLS         `yaml:"tls"`
+	Health      Health      `yaml:"health"`
+	Server      Server      `yaml:"server"`
+	DB          DB          `yaml:"db"`
+	Broker      Broker      `yaml:"broker"`
+	Dispatcher  Dispatcher  `yaml:"dispatcher"`
+	Migrate     Migrate     `yaml:"migrate"`
+	Shutdown    Shutdown    `yaml:"shutdown"`
+	Consumer    Consumer    `yaml:"consumer"`
+	Idempotency Idempotency `yaml:"idempotency"`
+	RateLimit   RateLimit   `yaml:"rate_limit"`
+	Watch       Watch       `yaml:"watch"`
+	Names       Names       `yaml:"names"`
+	Emails      Emails      `yaml:"emails"`
+}
+
+type GRPC struct {
+	Port int `yaml:"port" env:"GRPC_PORT"`
+	// Insecure disables TLS over gRPC.
+	Insecure bool `yaml:"insecure" env:"GRPC_INSECURE"`
+}
+
+// Gateway serves the HTTP/JSON API. It uses TLS unless gRPC is insecure.
+type Gateway struct {
+	Enabled bool `yaml:"enabled" env:"GATEWAY_ENABLED"`
+	Port    int  `yaml:"port" env:"GATEWAY_PORT"`
+}
+
+type TLS struct {
+	CertPath string `yaml:"cert_path" env:"TLS_CERT_PATH"`
+	KeyPath  string `yaml:"key_path" env:"TLS_KEY_PATH"`
+	CAPath   string `yaml:"ca_path" env:"TLS_CA_PATH"`
+}
+
+type Health struct {
+	Port          int           `yaml:"port" env:"HEALTH_PORT"`
+	CheckInterval time.Duration `yaml:"check_interval" env:"HEALTH_CHECK_INTERVAL"`
+	CheckTimeout  time.Duration `yaml:"check_timeout" env:"HEALTH_CHECK_TIMEOUT"`
+}
+
+type Server struct {
+	// RequestTimeout limits the duration of unary RPCs.
+	RequestTimeout time.Duration `yaml:"request_timeout" env:"SERVER_REQUEST_TIMEOUT"`
+	// StreamTimeout limits the duration of streaming RPCs.
+	StreamTimeout time.Duration `yaml:"stream_timeout" env:"SERVER_STREAM_TIMEOUT"`
+	BcryptCost    int           `yaml:"bcrypt_cost" env:"BCRYPT_COST"`
+	// AdminClient is the name the client certificate has to hold to call admin RPCs, such as ListAuditEntries.
+	AdminClient string `yaml:"admin_client" env:"SERVER_ADMIN_CLIENT"`
+}
+
+type DB struct {
+	// DSN is a full connection string. If set, all other connection settings are ignored.
+	DSN      Secret `yaml:"dsn" env:"DB_DSN"`
+	Host     string `yaml:"host" env:"DB_HOST"`
+	Port     string `yaml:"port" env:"DB_PORT"`
+	User     string `yaml:"user" env:"DB_USER"`
+	Password Secret `yaml:"password" env:"DB_PASS"`
+	Name     string `yaml:"name" env:"DB_NAME"`
+	// SSLMode is one of disable, require, verify-ca or verify-full, the modes supported by lib/pq.
+	SSLMode string `yaml:"sslmode" env:"DB_SSLMODE"`
+	// RootCert is a path to the CA certificate used to verify the server.
+	RootCert string `yaml:"root_cert" env:"DB_SSL_ROOT_CERT"`
+	// ClientCert and ClientKey are paths to the client certificate
+	// and its key used for certificate authentication.
+	ClientCert string `yaml:"client_cert" env:"DB_SSL_CERT"`
+	ClientKey  string `yaml:"client_key" env:"DB_SSL_KEY"`
+
+	// Connection pool limits. Zero means unlimited.
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+	// ConnectTimeout bounds retrying the initial connection on startup.
+	ConnectTimeout time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT"`
+}
+
+// ConnString returns the DSN if set or a postgres URL built from the rest of the settings.
+func (db DB) ConnString() string {
+	if db.DSN != "" {
+		return db.DSN.Value()
+	}
+
+	query := url.Values{}
+	query.Set("sslmode", db.SSLMode)
+
+	if db.RootCert != "" {
+		query.Set("sslrootcert", db.RootCert)
+	}
+
+	if db.ClientCert != "" {
+		query.Set("sslcert", db.ClientCert)
+	}
+
+	if db.ClientKey != "" {
+		query.Set("sslkey", db.ClientKey)
+	}
+
+	user := url.User(db.User)
+	if db.Password != "" {
+		user = url.UserPassword(db.User, db.Password.Value())
+	}
+
+	u := url.URL{
+		Scheme:   "postgresql",
+		User:     user,
+		Host:     net.JoinHostPort(db.Host, db.Port),
+		Path:     "/" + db.Name,
+		RawQuery: query.Encode(),
+	}
+
+	return u.String()
+}
+
+type Broker struct {
+	Host              string        `yaml:"host" env:"MQ_HOST"`
+	Port              string        `yaml:"port" env:"MQ_PORT"`
+	User              string        `yaml:"user" env:"MQ_USER"`
+	Password          Secret        `yaml:"password" env:"MQ_PASS"`
+	QueueSize         int           `yaml:"queue_size" env:"MQ_QUEUE_SIZE"`
+	MaxWorkers        int           `yaml:"max_workers" env:"MQ_MAX_WORKERS"`
+	ReconnectInterval time.Duration `yaml:"reconnect_interval" env:"MQ_RECONNECT_INTERVAL"`
+	MaxRequests       uint32        `yaml:"max_requests" env:"MQ_MAX_REQUESTS"`
+	ClearInterval     time.Duration `yaml:"clear_interval" env:"MQ_CLEAR_INTERVAL"`
+	ClosedTimeout     time.Duration `yaml:"closed_timeout" env:"MQ_CLOSED_TIMEOUT"`
+}
+
+type Dispatcher struct {
+	MaxWorkers int `yaml:"max_workers" env:"DISPATCHER_MAX_WORKERS"`
+}
+
+type Migrate struct {
+	// OnStart applies pending migrations before the service starts serving.
+	OnStart bool `yaml:"on_start" env:"MIGRATE_ON_START"`
+	// LockTTL is how long the migration lock is held without being renewed
+	// before other replicas may take it over.
+	LockTTL time.Duration `yaml:"lock_ttl" env:"MIGRATE_LOCK_TTL"`
+}
+
+type Shutdown struct {
+	// Timeout limits the whole graceful shutdown.
+	// It should be lower than the termination grace period of the pod.
+	Timeout time.Duration `yaml:"timeout" env:"SHUTDOWN_TIMEOUT"`
+	// DrainTimeout limits waiting for in-flight RPCs and streams to finish
+	// before they are cancelled.
+	DrainTimeout time.Duration `yaml:"drain_timeout" env:"SHUTDOWN_DRAIN_TIMEOUT"`
+}
+
+type Consumer struct {
+	// MaxAttempts limits processing of a consumed event before it's dead-lettered.
+	MaxAttempts int `yaml:"max_attempts" env:"CONSUMER_MAX_ATTEMPTS"`
+	// RetryInterval is multiplied by the number of failed attempts to get the delay before the next one.
+	RetryInterval time.Duration `yaml:"retry_interval" env:"CONSUMER_RETRY_INTERVAL"`
+	// Timeout limits a single attempt.
+	Timeout time.Duration `yaml:"timeout" env:"CONSUMER_TIMEOUT"`
+}
+
+type Idempotency struct {
+	// KeyTTL is how long responses are replayed for retries with the same idempotency key.
+	KeyTTL time.Duration `yaml:"key_ttl" env:"IDEMPOTENCY_KEY_TTL"`
+	// PendingTTL is how long a key stays reserved by a request which is still being handled.
+	PendingTTL time.Duration `yaml:"pending_ttl" env:"IDEMPOTENCY_PENDING_TTL"`
+}
+
+type RateLimit struct {
+	Enabled bool `yaml:"enabled" env:"RATE_LIMIT_ENABLED"`
+	// Key is what clients are told apart by, one of:
+	//	peer      the IP address
+	//	identity  the mTLS certificate, falling back to the IP address
+	//	user      the user id in the UserHeader, falling back to identity
+	Key string `yaml:"key" env:"RATE_LIMIT_KEY"`
+	// UserHeader holds the id of the user on whose behalf the trusted gateway calls the service.
+	// It's also recorded as the actor in the audit log.
+	UserHeader string `yaml:"user_header" env:"RATE_LIMIT_USER_HEADER"`
+	// TrustedGateway is the name the client certificate of the gateway has to hold
+	// for its UserHeader to be trusted. The header of other clients is ignored.
+	TrustedGateway string `yaml:"trusted_gateway" env:"RATE_LIMIT_TRUSTED_GATEWAY"`
+	// Default applies to methods without a limit of their own.
+	// Its fields are loaded from RATE_LIMIT_REQUESTS, RATE_LIMIT_INTERVAL and RATE_LIMIT_BURST.
+	Default Limit `yaml:"default" env:"RATE_LIMIT"`
+	// Methods holds limits keyed by RPC names, eg. Create.
+	Methods map[string]Limit `yaml:"methods"`
+}
+
+// Limit allows Requests per Interval on average with bursts of up to Burst requests.
+// Zero Requests means unlimited.
+type Limit struct {
+	Requests int           `yaml:"requests"`
+	Interval time.Duration `yaml:"interval"`
+	Burst    int           `yaml:"burst"`
+}
+
+// Watch serves WatchUsers streams.
+type Watch struct {
+	// Replica names the queues this replica consumes user events from. It must be unique to the replica
+	// and stable across its restarts, eg. the name of a StatefulSet's pod, so that queues aren't left behind.
+	// Defaults to the hostname.
+	Replica string `yaml:"replica" env:"WATCH_REPLICA"`
+	// BufferSize limits changes waiting to be sent to a single client before it's disconnected.
+	BufferSize int `yaml:"buffer_size" env:"WATCH_BUFFER_SIZE"`
+	// HistorySize limits retained changes which clients can resume watching from.
+	HistorySize int `yaml:"history_size" env:"WATCH_HISTORY_SIZE"`
+	// HeartbeatInterval is how often heartbeats are sent to clients.
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval" env:"WATCH_HEARTBEAT_INTERVAL"`
+}
+
+// Names guards user names against impersonation.
+type Names struct {
+	// Reserved names, and names looking like them, cannot be taken by users.
+	Reserved []string `yaml:"reserved"`
+	// ReuseCooldown is how long names released by users cannot be taken by others.
+	ReuseCooldown time.Duration `yaml:"reuse_cooldown" env:"NAMES_REUSE_COOLDOWN"`
+}
+
+// Emails restricts the addresses users can have. Rules for a domain apply to its subdomains too.
+type Emails struct {
+	// Allowed domains, if any, are the only ones addresses may belong to.
+	Allowed []string `yaml:"allowed"`
+	// Denied domains take precedence over allowed ones.
+	Denied []string `yaml:"denied"`
+	// DisposableDomainsFile is a path to a list of disposable email domains, one per line, which are denied too.
+	DisposableDomainsFile string `yaml:"disposable_domains_file" env:"EMAILS_DISPOSABLE_DOMAINS_FILE"`
+	// FoldPlus makes addresses differing only in plus-addressing tags, eg. bob+forum@example.com, taken by one user.
+	FoldPlus bool `yaml:"fold_plus" env:"EMAILS_FOLD_PLUS"`
+}
+
+// Default returns the config used as a base for loading.
+func Default() Config {
+	return Config{
+		GRPC: GRPC{
+			Port: 50051,
+		},
+		Gateway: Gateway{
+			Enabled: true,
+			Port:    8080,
+		},
+		Health: Health{
+			Port:          8081,
+			CheckInterval: time.Second * 5,
+			CheckTimeout:  time.Second * 2,
+		},
+		DB: DB{
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: time.Minute * 5,
+			ConnMaxIdleTime: time.Minute,
+			ConnectTimeout:  time.Second * 30,
+		},
+		Server: Server{
+			RequestTimeout: time.Second * 5,
+			StreamTimeout:  time.Second * 10,
+			BcryptCost:     bcrypt.MinCost,
+			AdminClient:    "admin-service",
+		},
+		Broker: Broker{
+			QueueSize:         100,
+			MaxWorkers:        100,
+			ReconnectInterval: time.Second * 2,
+			MaxRequests:       30,
+			ClearInterval:     time.Second * 5,
+			ClosedTimeout:     time.Second * 15,
+		},
+		Dispatcher: Dispatcher{
+			MaxWorkers: 20,
+		},
+		Migrate: Migrate{
+			LockTTL: time.Minute,
+		},
+		Shutdown: Shutdown{
+			Timeout:      time.Second * 25,
+			DrainTimeout: time.Second * 15,
+		},
+		Consumer: Consumer{
+			MaxAttempts:   5,
+			RetryInterval: time.Second,
+			Timeout:       time.Second * 5,
+		},
+		Idempotency: Idempotency{
+			KeyTTL:     time.Hour * 24,
+			PendingTTL: time.Minute,
+		},
+		RateLimit: RateLimit{
+			Enabled: true,
+			Key:     "identity",
+			Default: Limit{
+				Requests: 100,
+				Interval: time.Second,
+				Burst:    200,
+			},
+			Methods: map[string]Limit{
+				"Create": {
+					Requests: 10,
+					Interval: time.Minute,
+					Burst:    5,
+				},
+				"GetStream": {
+					Requests: 10,
+					Interval: time.Second,
+					Burst:    20,
+				},
+			},
+		},
+		Watch: Watch{
+			BufferSize:        100,
+			HistorySize:       1000,
+			HeartbeatInterval: time.Second * 30,
+		},
+		Names: Names{
+			Reserved:      []string{"admin", "administrator", "moderator", "mod", "root", "system", "support", "staff", "security", "official"},
+			ReuseCooldown: time.Hour * 24 * 30,
+		},
+	}
+}
+
+// Validate returns all found violations joined into a single error.
+func (c Config) Validate() error {
+	v := validator{}
+
+	v.port("grpc.port", c.GRPC.Port)
+	if c.Gateway.Enabled {
+		v.port("gateway.port", c.Gateway.Port)
+	}
+	v.port("health.port", c.Health.Port)
+
+	if !c.GRPC.Insecure {
+		v.required("tls.cert_path", c.TLS.CertPath)
+		v.required("tls.key_path", c.TLS.KeyPath)
+		v.required("tls.ca_path", c.TLS.CAPath)
+	}
+
+	v.positive("health.check_interval", int64(c.Health.CheckInterval))
+	v.positive("health.check_timeout", int64(c.Health.CheckTimeout))
+	v.positive("server.request_timeout", int64(c.Server.RequestTimeout))
+	v.positive("server.stream_timeout", int64(c.Server.StreamTimeout))
+
+	if c.Server.BcryptCost < bcrypt.MinCost || c.Server.BcryptCost > bcrypt.MaxCost {
+		v.errs = append(v.errs, fmt.Errorf("server.bcrypt_cost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, c.Server.BcryptCost))
+	}
+
+	v.errs = append(v.errs, c.DB.Validate())
+
+	v.required("broker.host", c.Broker.Host)
+	v.required("broker.port", c.Broker.Port)
+	v.required("broker.user", c.Broker.User)
+	v.positive("broker.queue_size", int64(c.Broker.QueueSize))
+	v.positive("broker.max_workers", int64(c.Broker.MaxWorkers))
+	v.positive("broker.reconnect_interval", int64(c.Broker.ReconnectInterval))
+	v.positive("broker.max_requests", int64(c.Broker.MaxRequests))
+	v.positive("broker.clear_interval", int64(c.Broker.ClearInterval))
+	v.positive("broker.closed_timeout", int64(c.Broker.ClosedTimeout))
+
+	v.positive("dispatcher.max_workers", int64(c.Dispatcher.MaxWorkers))
+	v.positive("migrate.lock_ttl", int64(c.Migrate.LockTTL))
+	v.positive("shutdown.timeout", int64(c.Shutdown.Timeout))
+	v.positive("shutdown.drain_timeout", int64(c.Shutdown.DrainTimeout))
+
+	if c.Shutdown.DrainTimeout > c.Shutdown.Timeout {
+		v.errs = append(v.errs, fmt.Errorf("shutdown.drain_timeout must not exceed shutdown.timeout, got %s > %s", c.Shutdown.DrainTimeout, c.Shutdown.Timeout))
+	}
+
+	v.positive("consumer.max_attempts", int64(c.Consumer.MaxAttempts))
+	v.nonNegative("consumer.retry_interval", int64(c.Consumer.RetryInterval))
+	v.positive("consumer.timeout", int64(c.Consumer.Timeout))
+	v.positive("idempotency.key_ttl", int64(c.Idempotency.KeyTTL))
+	v.positive("idempotency.pending_ttl", int64(c.Idempotency.PendingTTL))
+
+	if c.Idempotency.PendingTTL < c.Server.RequestTimeout {
+		v.errs = append(v.errs, fmt.Errorf("idempotency.pending_ttl must not be lower than server.request_timeout, got %s < %s", c.Idempotency.PendingTTL, c.Server.RequestTimeout))
+	}
+
+	v.errs = append(v.errs, c.RateLimit.Validate())
+
+	v.positive("watch.buffer_size", int64(c.Watch.BufferSize))
+	v.nonNegative("watch.history_size", int64(c.Watch.HistorySize))
+	v.positive("watch.heartbeat_interval", int64(c.Watch.HeartbeatInterval))
+	v.nonNegative("names.reuse_cooldown", int64(c.Names.ReuseCooldown))
+
+	return errors.Join(v.errs...)
+}
+
+// Validate returns all found violations joined into a single error.
+func (r RateLimit) Validate() error {
+	v := validator{}
+
+	switch r.Key {
+	case "peer", "identity":
+	case "user":
+		v.required("rate_limit.user_header", r.UserHeader)
+	default:
+		v.errs = append(v.errs, fmt.Errorf("rate_limit.key must be one of peer, identity or user, got %q", r.Key))
+	}
+
+	if r.Key == "user" || r.UserHeader != "" {
+		v.required("rate_limit.trusted_gateway", r.TrustedGateway)
+	}
+
+	v.limit("rate_limit.default", r.Default)
+	for method, limit := range r.Methods {
+		v.limit("rate_limit.methods."+method, limit)
+	}
+
+	return errors.Join(v.errs...)
+}
+
+// Validate returns all found violations joined into a single error.
+func (db DB) Validate() error {
+	v := validator{}
+
+	v.nonNegative("db.max_open_conns", int64(db.MaxOpenConns))
+	v.nonNegative("db.max_idle_conns", int64(db.MaxIdleConns))
+	v.nonNegative("db.conn_max_lifetime", int64(db.ConnMaxLifetime))
+	v.nonNegative("db.conn_max_idle_time", int64(db.ConnMaxIdleTime))
+	v.positive("db.connect_timeout", int64(db.ConnectTimeout))
+
+	if db.DSN != "" {
+		return errors.Join(v.errs...)
+	}
+
+	v.required("db.host", db.Host)
+	v.required("db.port", db.Port)
+	v.required("db.user", db.User)
+	v.required("db.name", db.Name)
+
+	switch db.SSLMode {
+	case "disable", "require", "verify-ca", "verify-full":
+	default:
+		v.errs = append(v.errs, fmt.Errorf("db.sslmode must be one of disable, require, verify-ca or verify-full, got %q", db.SSLMode))
+	}
+
+	if (db.ClientCert == "") != (db.ClientKey == "") {
+		v.errs = append(v.errs, errors.New("db.client_cert and db.client_key must be provided together"))
+	}
+
+	return errors.Join(v.errs...)
+}
+
+type validator struct {
+	errs []error
+}
+
+func (v *validator) port(name string, port int) {
+	if port < 1 || port > 65535 {
+		v.errs = append(v.errs, fmt.Errorf("%s must be between 1 and 65535, got %d", name, port))
+	}
+
+	if port == TracingMetricsPort {
+		v.errs = append(v.errs, fmt.Errorf("%s must not be %d, which is taken by the tracing provider", name, TracingMetricsPort))
+	}
+}
+
+func (v *validator) positive(name string, n int64) {
+	if n <= 0 {
+		v.errs = append(v.errs, fmt.Errorf("%s must be positive, got %d", name, n))
+	}
+}
+
+func (v *validator) nonNegative(name string, n int64) {
+	if n < 0 {
+		v.errs = append(v.errs, fmt.Errorf("%s must not be negative, got %d", name, n))
+	}
+}
+
+func (v *validator) limit(name string, limit Limit) {
+	v.nonNegative(name+".requests", int64(limit.Requests))
+	if limit.Requests > 0 {
+		v.positive(name+".interval", int64(limit.Interval))
+		v.positive(name+".burst", int64(limit.Burst))
+	}
+}
+
+func (v *validator) required(name, value string) {
+	if value == "" {
+		v.errs = append(v.errs, fmt.Errorf("%s is required", name))
+	}
+}
+
+// String returns the config in YAML format with all secrets redacted.
+func (c Config) String() string {
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Sprintf("failed to marshal config: %v", err)
+	}
+	return string(out)
+}
@@ -0,0 +1,387 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func validConfig() Config {
+	c := Default()
+	c.GRPC.Insecure = true
+	c.DB.Host = "db"
+	c.DB.Port = "26257"
+	c.DB.User = "admin"
+	c.DB.Password = "db-secret"
+	c.DB.Name = "postgres"
+	c.Broker.Host = "mq"
+	c.Broker.Port = "5672"
+	c.Broker.User = "guest"
+	c.Broker.Password = "mq-secret"
+	return c
+}
+
+// setEnv sets env variables required for the config to be valid.
+func setEnv(t *testing.T) {
+	t.Setenv("GRPC_INSECURE", "true")
+	t.Setenv("DB_HOST", "db")
+	t.Setenv("DB_PORT", "26257")
+	t.Setenv("DB_USER", "admin")
+	t.Setenv("DB_PASS", "db-secret")
+	t.Setenv("DB_NAME", "postgres")
+	t.Setenv("MQ_HOST", "mq")
+	t.Setenv("MQ_PORT", "5672")
+	t.Setenv("MQ_USER", "guest")
+	t.Setenv("MQ_PASS", "mq-secret")
+}
+
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		file    string
+		args    []string
+		want    func() Config
+		wantErr bool
+	}{
+		{
+			name: "Test if loads defaults and env",
+			want: validConfig,
+		},
+		{
+			name: "Test if env overrides the config file",
+			file: "server:\n  request_timeout: 3s\n  bcrypt_cost: 12\nbroker:\n  queue_size: 7\n",
+			env:  map[string]string{"BCRYPT_COST": "10"},
+			want: func() Config {
+				c := validConfig()
+				c.Server.RequestTimeout = time.Second * 3
+				c.Server.BcryptCost = 10
+				c.Broker.QueueSize = 7
+				return c
+			},
+		},
+		{
+			name: "Test if flags override env",
+			env:  map[string]string{"GRPC_PORT": "50052", "HEALTH_PORT": "9000", "GATEWAY_PORT": "8090"},
+			args: []string{"-p", "50053", "-health-port", "8082", "-gateway-port", "8083", "-migrate"},
+			want: func() Config {
+				c := validConfig()
+				c.GRPC.Port = 50053
+				c.Gateway.Port = 8083
+				c.Health.Port = 8082
+				c.Migrate.OnStart = true
+				return c
+			},
+		},
+		{
+			name: "Test if parses durations and unsigned ints from env",
+			env:  map[string]string{"MQ_CLOSED_TIMEOUT": "1m", "MQ_MAX_REQUESTS": "5"},
+			want: func() Config {
+				c := validConfig()
+				c.Broker.ClosedTimeout = time.Minute
+				c.Broker.MaxRequests = 5
+				return c
+			},
+		},
+		{
+			name: "Test if loads the default rate limit from prefixed env",
+			env:  map[string]string{"RATE_LIMIT_REQUESTS": "5", "RATE_LIMIT_INTERVAL": "1m", "RATE_LIMIT_BURST": "10"},
+			want: func() Config {
+				c := validConfig()
+				c.RateLimit.Default = Limit{Requests: 5, Interval: time.Minute, Burst: 10}
+				return c
+			},
+		},
+		{
+			name:    "Test if fails on malformed env",
+			env:     map[string]string{"HEALTH_CHECK_INTERVAL": "often"},
+			wantErr: true,
+		},
+		{
+			name:    "Test if fails on unknown file fields",
+			file:    "unknown: true\n",
+			wantErr: true,
+		},
+		{
+			name:    "Test if fails on invalid config",
+			env:     map[string]string{"BCRYPT_COST": "99"},
+			wantErr: true,
+		},
+		{
+			name:    "Test if requires TLS paths unless insecure",
+			env:     map[string]string{"GRPC_INSECURE": "false"},
+			wantErr: true,
+		},
+		{
+			name:    "Test if fails on unknown flags",
+			args:    []string{"-unknown"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, tt.file)}, args...)
+			}
+
+			got, err := Load(args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Load() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if want := tt.want(); !cmp.Equal(got, want) {
+				t.Errorf("Load():\n got = %v\n want = %v\n %v", got, want, cmp.Diff(got, want))
+			}
+		})
+	}
+}
+
+func TestLoadDB(t *testing.T) {
+	// Only the DB config is required.
+	t.Setenv("DB_HOST", "db")
+	t.Setenv("DB_PORT", "26257")
+	t.Setenv("DB_USER", "admin")
+	t.Setenv("DB_PASS", "db-secret")
+	t.Setenv("DB_NAME", "postgres")
+
+	got, err := LoadDB(nil)
+	if err != nil {
+		t.Errorf("LoadDB() error = %v", err)
+		return
+	}
+
+	want := Default().DB
+	want.Host = "db"
+	want.Port = "26257"
+	want.User = "admin"
+	want.Password = "db-secret"
+	want.Name = "postgres"
+	if !cmp.Equal(got, want) {
+		t.Errorf("LoadDB():\n got = %v\n want = %v\n %v", got, want, cmp.Diff(got, want))
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(*Config)
+		wantErrs []string
+	}{
+		{
+			name:   "Test if passes on valid config",
+			modify: func(*Config) {},
+		},
+		{
+			name: "Test if reports all violations",
+			modify: func(c *Config) {
+				c.GRPC.Port = 0
+				c.DB.Host = ""
+				c.Server.StreamTimeout = 0
+			},
+			wantErrs: []string{"grpc.port", "db.host", "server.stream_timeout"},
+		},
+		{
+			name: "Test if validates the gateway port only if enabled",
+			modify: func(c *Config) {
+				c.Gateway.Enabled = false
+				c.Gateway.Port = 0
+			},
+		},
+		{
+			name: "Test if fails on the port of the tracing provider",
+			modify: func(c *Config) {
+				c.Health.Port = TracingMetricsPort
+			},
+			wantErrs: []string{"health.port"},
+		},
+		{
+			name: "Test if fails on unknown sslmode",
+			modify: func(c *Config) {
+				c.DB.SSLMode = "always"
+			},
+			wantErrs: []string{"db.sslmode"},
+		},
+		{
+			name: "Test if fails on sslmode unsupported by lib/pq",
+			modify: func(c *Config) {
+				c.DB.SSLMode = "prefer"
+			},
+			wantErrs: []string{"db.sslmode"},
+		},
+		{
+			name: "Test if requires both client cert and key",
+			modify: func(c *Config) {
+				c.DB.ClientCert = "/certs/client.crt"
+			},
+			wantErrs: []string{"db.client_cert"},
+		},
+		{
+			name: "Test if fails on negative pool limits",
+			modify: func(c *Config) {
+				c.DB.MaxOpenConns = -1
+				c.DB.ConnMaxLifetime = -time.Second
+			},
+			wantErrs: []string{"db.max_open_conns", "db.conn_max_lifetime"},
+		},
+		{
+			name: "Test if drain timeout must fit in shutdown timeout",
+			modify: func(c *Config) {
+				c.Shutdown.DrainTimeout = c.Shutdown.Timeout + time.Second
+			},
+			wantErrs: []string{"shutdown.drain_timeout"},
+		},
+		{
+			name: "Test if fails on non-positive consumer attempts",
+			modify: func(c *Config) {
+				c.Consumer.MaxAttempts = 0
+			},
+			wantErrs: []string{"consumer.max_attempts"},
+		},
+		{
+			name: "Test if pending idempotency keys outlive requests",
+			modify: func(c *Config) {
+				c.Idempotency.PendingTTL = c.Server.RequestTimeout - time.Second
+			},
+			wantErrs: []string{"idempotency.pending_ttl"},
+		},
+		{
+			name: "Test if fails on non-positive watch settings",
+			modify: func(c *Config) {
+				c.Watch.BufferSize = 0
+				c.Watch.HistorySize = -1
+			},
+			wantErrs: []string{"watch.buffer_size", "watch.history_size"},
+		},
+		{
+			name: "Test if fails on negative name reuse cooldown",
+			modify: func(c *Config) {
+				c.Names.ReuseCooldown = -time.Hour
+			},
+			wantErrs: []string{"names.reuse_cooldown"},
+		},
+		{
+			name: "Test if validates rate limits",
+			modify: func(c *Config) {
+				c.RateLimit.Key = "user"
+				c.RateLimit.Methods = map[string]Limit{"Create": {Requests: 1}}
+			},
+			wantErrs: []string{"rate_limit.user_header", "rate_limit.trusted_gateway", "rate_limit.methods.Create.interval", "rate_limit.methods.Create.burst"},
+		},
+		{
+			name: "Test if DSN replaces other DB settings",
+			modify: func(c *Config) {
+				c.DB.DSN = "postgresql://admin@db:26257/postgres"
+				c.DB.Host = ""
+				c.DB.SSLMode = ""
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig()
+			tt.modify(&c)
+
+			err := c.Validate()
+			if (err != nil) != (len(tt.wantErrs) > 0) {
+				t.Errorf("Config.Validate() error = %v, wantErrs %v", err, tt.wantErrs)
+				return
+			}
+
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Config.Validate() error = %v, does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfig_String(t *testing.T) {
+	c := validConfig()
+
+	for _, got := range []string{c.String(), fmt.Sprintf("%v", c), fmt.Sprintf("%+v", c), fmt.Sprintf("%#v", c.DB.Password)} {
+		if strings.Contains(got, "db-secret") || strings.Contains(got, "mq-secret") {
+			t.Errorf("Config.String() reveals secrets:\n%s", got)
+		}
+	}
+
+	if !strings.Contains(c.String(), redacted) {
+		t.Errorf("Config.String() does not contain %q:\n%s", redacted, c.String())
+	}
+}
+
+func TestDB_ConnString(t *testing.T) {
+	tests := []struct {
+		name string
+		db   DB
+		want string
+	}{
+		{
+			name: "Test if escapes special characters",
+			db: DB{
+				Host:     "db",
+				Port:     "26257",
+				User:     "admin",
+				Password: "p@ss:w/rd?#%",
+				Name:     "user-service",
+				SSLMode:  "disable",
+			},
+			want: "postgresql://admin:p%40ss%3Aw%2Frd%3F%23%25@db:26257/user-service?sslmode=disable",
+		},
+		{
+			name: "Test if includes TLS settings",
+			db: DB{
+				Host:       "db",
+				Port:       "26257",
+				User:       "user-service",
+				Name:       "postgres",
+				SSLMode:    "verify-full",
+				RootCert:   "/certs/ca.crt",
+				ClientCert: "/certs/client.user-service.crt",
+				ClientKey:  "/certs/client.user-service.key",
+			},
+			want: "postgresql://user-service@db:26257/postgres?sslcert=%2Fcerts%2Fclient.user-service.crt&sslkey=%2Fcerts%2Fclient.user-service.key&sslmode=verify-full&sslrootcert=%2Fcerts%2Fca.crt",
+		},
+		{
+			name: "Test if formats IPv6 hosts",
+			db:   DB{Host: "::1", Port: "26257", User: "admin", Name: "postgres", SSLMode: "require"},
+			want: "postgresql://admin@[::1]:26257/postgres?sslmode=require",
+		},
+		{
+			name: "Test if returns DSN as is",
+			db:   DB{DSN: "host=db user=admin", Host: "ignored"},
+			want: "host=db user=admin",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.db.ConnString(); got != tt.want {
+				t.Errorf("DB.ConnString():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv is the environment variable holding the path
+// to the YAML config file, unless it's given with the -config flag.
+const FileEnv = "CONFIG_FILE"
+
+// Load returns a validated config built from, in ascending order of precedence:
+// defaults, the YAML config file, environment variables and flags parsed from args.
+//
+// Recognized flags:
+//
+//	-config        path to the YAML config file
+//	-p             the gRPC server port
+//	-insecure      whether to not use TLS over gRPC
+//	-gateway-port  the HTTP/JSON gateway port
+//	-health-port   the HTTP liveness and readiness probes port
+//	-migrate       whether to apply pending migrations before serving
+//
+// Args are usually os.Args[1:]. Parsing stops at the first non-flag argument.
+func Load(args []string) (Config, error) {
+	config, err := load(args)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return config, nil
+}
+
+// LoadDB works just like Load but validates and returns only the database config.
+// It's meant for tools which don't need the rest of the config to be valid.
+func LoadDB(args []string) (DB, error) {
+	config, err := load(args)
+	if err != nil {
+		return DB{}, err
+	}
+
+	if err := config.DB.Validate(); err != nil {
+		return DB{}, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return config.DB, nil
+}
+
+func load(args []string) (Config, error) {
+	config := Default()
+
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv(FileEnv), "Path to the YAML config file")
+	port := fs.Int("p", 0, "The gRPC server port")
+	insecure := fs.Bool("insecure", false, "Whether to not use TLS over gRPC")
+	gatewayPort := fs.Int("gateway-port", 0, "The HTTP/JSON gateway port")
+	healthPort := fs.Int("health-port", 0, "The HTTP liveness and readiness probes port")
+	migrate := fs.Bool("migrate", false, "Whether to apply pending migrations before serving")
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if *path != "" {
+		if err := loadFile(&config, *path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := loadEnv(&config); err != nil {
+		return Config{}, err
+	}
+
+	// Override only the flags which were explicitly set.
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "p":
+			config.GRPC.Port = *port
+		case "insecure":
+			config.GRPC.Insecure = *insecure
+		case "gateway-port":
+			config.Gateway.Port = *gatewayPort
+		case "health-port":
+			config.Health.Port = *healthPort
+		case "migrate":
+			config.Migrate.OnStart = *migrate
+		}
+	})
+
+	return config, nil
+}
+
+func loadFile(config *Config, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to decode config file %q: %w", path, err)
+	}
+
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// loadEnv sets every field tagged with `env` to the value
+// of the corresponding environment variable, if set.
+// The `env` tag of a struct field is a prefix of variables setting its fields,
+// eg. RATE_LIMIT_REQUESTS for the `requests` field of a struct tagged RATE_LIMIT.
+func loadEnv(config *Config) error {
+	return loadEnvStruct(reflect.ValueOf(config).Elem(), "")
+}
+
+func loadEnvStruct(v reflect.Value, prefix string) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		tag := v.Type().Field(i).Tag
+
+		if field.Kind() == reflect.Struct && field.Type() != durationType {
+			fieldPrefix, _ := tag.Lookup("env")
+			if err := loadEnvStruct(field, fieldPrefix); err != nil {
+				return err
+			}
+			continue
+		}
+
+		key, ok := tag.Lookup("env")
+		if !ok && prefix != "" {
+			name, _, _ := strings.Cut(tag.Get("yaml"), ",")
+			key, ok = prefix+"_"+strings.ToUpper(name), name != ""
+		}
+		if !ok {
+			continue
+		}
+
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			continue
+		}
+
+		if err := setField(field, value); err != nil {
+			return fmt.Errorf("invalid value of %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+func setField(field reflect.Value, value string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+// Package consumer keeps denormalized user data consistent
+// with events published by other dev_forum services.
+package consumer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/logging"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DeadLettered events carry consumed events which could not be processed.
+// A queue has to be bound to their route for them to be retained.
+const DeadLettered event.EventType = "user_event-dead_lettered"
+
+// ErrMalformedEvent is returned for events which can never be processed,
+// so they are dead-lettered without retrying.
+var ErrMalformedEvent = errors.New("malformed event")
+
+// DeadLetter is the body of DeadLettered events.
+type DeadLetter struct {
+	EventId  string      `json:"event_id"`
+	Event    event.Event `json:"event"`
+	Reason   string      `json:"reason"`
+	Attempts int         `json:"attempts"`
+}
+
+type Config struct {
+	// Queue is prepended to the names of queues consumed from,
+	// so that replicas share them and every event is delivered to only one of them.
+	Queue string
+	// MaxAttempts limits processing of an event before it's dead-lettered.
+	MaxAttempts int
+	// RetryInterval is multiplied by the number of failed attempts to get the delay before the next one.
+	RetryInterval time.Duration
+	// Timeout limits a single attempt.
+	Timeout time.Duration
+}
+
+type Consumer struct {
+	// ctx is the one events are consumed with. Cancelling it stops retries of handled events.
+	ctx     context.Context
+	config  Config
+	broker  event.Broker
+	storage storage.EventStorage
+	logger  logging.Logger
+	tracer  trace.Tracer
+}
+
+type Dependencies struct {
+	Broker  event.Broker
+	Storage storage.EventStorage
+	Logger  logging.Logger
+	Tracer  trace.Tracer
+}
+
+func New(config Config, d Dependencies) *Consumer {
+	return &Consumer{
+		ctx:     context.Background(),
+		config:  config,
+		broker:  d.Broker,
+		storage: d.Storage,
+		logger:  d.Logger,
+		tracer:  d.Tracer,
+	}
+}
+
+// processFunc applies the event identified by the id.
+type processFunc func(ctx context.Context, id string, e event.Event) error
+
+func (c *Consumer) processors() map[event.EventType]processFunc {
+	return map[event.EventType]processFunc{
+		event.UserLoggedIn:   c.processLogin,
+		event.ArticleCreated: c.processArticleCreated,
+		event.ArticleDeleted: c.processArticleDeleted,
+	}
+}
+
+// EventHandlers implements dispatcher.Listener.
+func (c *Consumer) EventHandlers() map[event.EventType][]event.Handler {
+	handlers := make(map[event.EventType][]event.Handler)
+	for eType, process := range c.processors() {
+		process := process
+		handlers[eType] = []event.Handler{event.HandlerFunc(func(e event.Event) {
+			c.handle(e, process)
+		})}
+	}
+	return handlers
+}
+
+// Subscribe starts consuming every handled type of events
+// and returns channels to be added as the dispatcher's event providers.
+// Handling of events is cancelled along with the ctx.
+func (c *Consumer) Subscribe(ctx context.Context) ([]<-chan event.Event, error) {
+	c.ctx = ctx
+	providers := make([]<-chan event.Event, 0, len(c.processors()))
+	for eType := range c.processors() {
+		queue := fmt.Sprintf("%s.%s", c.config.Queue, eType)
+
+		events, err := c.broker.Consume(ctx, queue, eType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to consume %s events: %w", eType, err)
+		}
+
+		providers = append(providers, events)
+	}
+
+	return providers, nil
+}
+
+// handle processes the event, retrying on failures until MaxAttempts is reached.
+// Events which were already processed are skipped and events which could not be processed
+// are dead-lettered, as are events still being retried once the consumer's ctx is cancelled.
+func (c *Consumer) handle(e event.Event, process processFunc) {
+	id := EventId(e)
+
+	ctx, span := c.tracer.Start(c.ctx, "consumer.handle", trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(
+		attribute.String("event.id", id),
+		attribute.String("event.type", string(e.Type)),
+	))
+	defer span.End()
+
+	var err error
+	attempts := 0
+	for attempts < c.config.MaxAttempts {
+		attempts++
+
+		err = c.attempt(ctx, id, e, process)
+		if err == nil {
+			return
+		}
+
+		if errors.Is(err, storage.ErrEventProcessed) {
+			c.logger.Log(ctx, "Skipping already processed event", "eventId", id, "type", e.Type)
+			return
+		}
+
+		if errors.Is(err, ErrMalformedEvent) || attempts == c.config.MaxAttempts {
+			break
+		}
+
+		c.logger.Log(ctx, "Failed to process event, retrying", "eventId", id, "type", e.Type, "attempt", attempts, "err", err)
+		if waitErr := wait(ctx, c.config.RetryInterval*time.Duration(attempts)); waitErr != nil {
+			err = errors.Join(err, waitErr)
+			break
+		}
+	}
+
+	tracing.SetSpanErr(span, err)
+	c.deadLetter(ctx, id, e, err, attempts)
+}
+
+// wait blocks for the given duration or until the ctx is done, in which case it returns the ctx's error.
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Consumer) attempt(ctx context.Context, id string, e event.Event, process processFunc) error {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	return process(ctx, id, e)
+}
+
+func (c *Consumer) deadLetter(ctx context.Context, id string, e event.Event, reason error, attempts int) {
+	c.logger.Log(ctx, "Dead-lettering event", "eventId", id, "type", e.Type, "attempts", attempts, "err", reason)
+
+	deadLetter, err := event.MakeEvent(event.UserAggregate, DeadLettered, DeadLetter{
+		EventId:  id,
+		Event:    e,
+		Reason:   reason.Error(),
+		Attempts: attempts,
+	})
+	if err != nil {
+		c.logger.Log(ctx, "Failed to make dead letter", "eventId", id, "err", err)
+		return
+	}
+
+	if err := c.broker.ResilientPublish(deadLetter); err != nil {
+		c.logger.Log(ctx, "Failed to publish dead letter", "eventId", id, "err", err)
+	}
+}
+
+// EventId returns an id derived from the content of the event, since events carry no ids.
+// Redelivered copies of an event share the id.
+func EventId(e event.Event) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00%s\x00%s\x00", e.AggregateId, e.Type, e.Timestamp.UTC().Format(time.RFC3339Nano))
+	hash.Write(e.Body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
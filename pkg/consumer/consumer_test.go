@@ -0,0 +1,228 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/mocks"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+	"github.com/krixlion/dev_forum-user/pkg/storage/storagemocks"
+	"github.com/stretchr/testify/mock"
+)
+
+func setUpConsumer(broker mocks.Broker, storage storagemocks.EventStorage) *Consumer {
+	return New(Config{
+		Queue:         "user-service",
+		MaxAttempts:   3,
+		RetryInterval: time.Millisecond,
+		Timeout:       time.Second,
+	}, Dependencies{
+		Broker:  broker,
+		Storage: storage,
+		Logger:  nulls.NullLogger{},
+		Tracer:  nulls.NullTracer{},
+	})
+}
+
+func isDeadLetter(attempts int) func(event.Event) bool {
+	return func(e event.Event) bool {
+		var deadLetter DeadLetter
+		if err := json.Unmarshal(e.Body, &deadLetter); err != nil {
+			return false
+		}
+		return e.Type == DeadLettered && deadLetter.Attempts == attempts
+	}
+}
+
+func TestConsumer_handle(t *testing.T) {
+	timestamp := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	login := event.Event{AggregateId: event.AuthAggregate, Type: event.UserLoggedIn, Body: []byte(`"user-1"`), Timestamp: timestamp}
+
+	tests := []struct {
+		name  string
+		event event.Event
+		mock  func(broker mocks.Broker, storage storagemocks.EventStorage)
+	}{
+		{
+			name:  "Test if records logins",
+			event: login,
+			mock: func(broker mocks.Broker, storage storagemocks.EventStorage) {
+				storage.On("RecordLogin", mock.Anything, EventId(login), "user-1", timestamp).Return(nil).Once()
+			},
+		},
+		{
+			name:  "Test if skips already processed events",
+			event: login,
+			mock: func(broker mocks.Broker, s storagemocks.EventStorage) {
+				s.On("RecordLogin", mock.Anything, EventId(login), "user-1", timestamp).Return(storage.ErrEventProcessed).Once()
+			},
+		},
+		{
+			name:  "Test if retries failures and dead-letters the event after max attempts",
+			event: login,
+			mock: func(broker mocks.Broker, storage storagemocks.EventStorage) {
+				storage.On("RecordLogin", mock.Anything, EventId(login), "user-1", timestamp).Return(errors.New("test err")).Times(3)
+				broker.On("ResilientPublish", mock.MatchedBy(isDeadLetter(3))).Return(nil).Once()
+			},
+		},
+		{
+			name:  "Test if dead-letters malformed events without retrying",
+			event: event.Event{Type: event.ArticleCreated, Body: []byte(`{"id": "article-1"}`), Timestamp: timestamp},
+			mock: func(broker mocks.Broker, storage storagemocks.EventStorage) {
+				broker.On("ResilientPublish", mock.MatchedBy(isDeadLetter(1))).Return(nil).Once()
+			},
+		},
+		{
+			name:  "Test if attributes created articles to their authors",
+			event: event.Event{Type: event.ArticleCreated, Body: []byte(`{"id": "article-1", "user_id": "user-1", "title": "title"}`), Timestamp: timestamp},
+			mock: func(broker mocks.Broker, storage storagemocks.EventStorage) {
+				storage.On("AddArticle", mock.Anything, mock.AnythingOfType("string"), "user-1", "article-1").Return(nil).Once()
+			},
+		},
+		{
+			name:  "Test if removes deleted articles",
+			event: event.Event{Type: event.ArticleDeleted, Body: []byte(`"article-1"`), Timestamp: timestamp},
+			mock: func(broker mocks.Broker, storage storagemocks.EventStorage) {
+				storage.On("RemoveArticle", mock.Anything, mock.AnythingOfType("string"), "article-1").Return(nil).Once()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := mocks.NewBroker()
+			storage := storagemocks.NewEventStorage()
+			tt.mock(broker, storage)
+
+			c := setUpConsumer(broker, storage)
+			for _, handler := range c.EventHandlers()[tt.event.Type] {
+				handler.Handle(tt.event)
+			}
+
+			broker.AssertExpectations(t)
+			storage.AssertExpectations(t)
+		})
+	}
+}
+
+func TestConsumer_handle_cancelled(t *testing.T) {
+	login := event.Event{AggregateId: event.AuthAggregate, Type: event.UserLoggedIn, Body: []byte(`"user-1"`), Timestamp: time.Now()}
+
+	broker := mocks.NewBroker()
+	storage := storagemocks.NewEventStorage()
+	storage.On("RecordLogin", mock.Anything, EventId(login), "user-1", login.Timestamp).Return(errors.New("test err")).Once()
+	broker.On("ResilientPublish", mock.MatchedBy(isDeadLetter(1))).Return(nil).Once()
+
+	c := setUpConsumer(broker, storage)
+	c.config.RetryInterval = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.ctx = ctx
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, handler := range c.EventHandlers()[login.Type] {
+			handler.Handle(login)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Consumer.handle() kept retrying after the ctx was cancelled")
+	}
+
+	broker.AssertExpectations(t)
+	storage.AssertExpectations(t)
+}
+
+func TestConsumer_Subscribe(t *testing.T) {
+	broker := mocks.NewBroker()
+	for _, eType := range []event.EventType{event.UserLoggedIn, event.ArticleCreated, event.ArticleDeleted} {
+		broker.On("Consume", mock.Anything, "user-service."+string(eType), eType).Return(make(<-chan event.Event), nil).Once()
+	}
+
+	providers, err := setUpConsumer(broker, storagemocks.NewEventStorage()).Subscribe(context.Background())
+	if err != nil {
+		t.Errorf("Consumer.Subscribe() error = %v", err)
+		return
+	}
+
+	if len(providers) != 3 {
+		t.Errorf("Consumer.Subscribe() providers:\n got = %v\n want = %v", len(providers), 3)
+	}
+
+	broker.AssertExpectations(t)
+}
+
+func TestEventId(t *testing.T) {
+	e := event.Event{AggregateId: event.AuthAggregate, Type: event.UserLoggedIn, Body: []byte(`"user-1"`), Timestamp: time.Now()}
+
+	if EventId(e) != EventId(e) {
+		t.Errorf("EventId() is not deterministic")
+	}
+
+	other := e
+	other.Timestamp = e.Timestamp.Add(time.Nanosecond)
+	if EventId(e) == EventId(other) {
+		t.Errorf("EventId() is equal for different events")
+	}
+}
+
+func Test_decodeId(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Test if decodes strings",
+			body: `"user-1"`,
+			want: "user-1",
+		},
+		{
+			name: "Test if decodes objects",
+			body: `{"user_id": "user-1", "ip": "127.0.0.1"}`,
+			want: "user-1",
+		},
+		{
+			name:    "Test if fails on missing key",
+			body:    `{"id": "user-1"}`,
+			wantErr: true,
+		},
+		{
+			name:    "Test if fails on empty id",
+			body:    `""`,
+			wantErr: true,
+		},
+		{
+			name:    "Test if fails on invalid JSON",
+			body:    `user-1`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeId([]byte(tt.body), "user_id")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decodeId() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr && !errors.Is(err, ErrMalformedEvent) {
+				t.Errorf("decodeId() error = %v, want %v", err, ErrMalformedEvent)
+			}
+
+			if got != tt.want {
+				t.Errorf("decodeId():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/krixlion/dev_forum-lib/event"
+)
+
+// articleCreated is the part of the article-created event body the service depends on.
+type articleCreated struct {
+	Id     string `json:"id"`
+	UserId string `json:"user_id"`
+}
+
+func (c *Consumer) processLogin(ctx context.Context, id string, e event.Event) error {
+	userId, err := decodeId(e.Body, "user_id")
+	if err != nil {
+		return err
+	}
+
+	if e.Timestamp.IsZero() {
+		return fmt.Errorf("%w: missing timestamp", ErrMalformedEvent)
+	}
+
+	return c.storage.RecordLogin(ctx, id, userId, e.Timestamp)
+}
+
+func (c *Consumer) processArticleCreated(ctx context.Context, id string, e event.Event) error {
+	var article articleCreated
+	if err := json.Unmarshal(e.Body, &article); err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformedEvent, err)
+	}
+
+	if article.Id == "" || article.UserId == "" {
+		return fmt.Errorf("%w: missing article id or user_id", ErrMalformedEvent)
+	}
+
+	return c.storage.AddArticle(ctx, id, article.UserId, article.Id)
+}
+
+func (c *Consumer) processArticleDeleted(ctx context.Context, id string, e event.Event) error {
+	articleId, err := decodeId(e.Body, "id")
+	if err != nil {
+		return err
+	}
+
+	return c.storage.RemoveArticle(ctx, id, articleId)
+}
+
+// decodeId accepts a body which is either a JSON string
+// or an object holding the id under the given key.
+func decodeId(body []byte, key string) (string, error) {
+	var id string
+	if err := json.Unmarshal(body, &id); err == nil {
+		if id == "" {
+			return "", fmt.Errorf("%w: empty id", ErrMalformedEvent)
+		}
+		return id, nil
+	}
+
+	var object map[string]interface{}
+	if err := json.Unmarshal(body, &object); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMalformedEvent, err)
+	}
+
+	id, ok := object[key].(string)
+	if !ok || id == "" {
+		return "", fmt.Errorf("%w: missing %s", ErrMalformedEvent, key)
+	}
+
+	return id, nil
+}
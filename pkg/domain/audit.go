@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/krixlion/dev_forum-user/pkg/entity"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+)
+
+// AuditInfo identifies who requested a change and in which request.
+type AuditInfo = storage.AuditInfo
+
+type auditInfoKey struct{}
+
+// WithAuditInfo returns a context carrying the info recorded along with changes made by the UserManager.
+func WithAuditInfo(ctx context.Context, info AuditInfo) context.Context {
+	return context.WithValue(ctx, auditInfoKey{}, info)
+}
+
+func auditInfoFrom(ctx context.Context) AuditInfo {
+	info, _ := ctx.Value(auditInfoKey{}).(AuditInfo)
+	return info
+}
+
+// ListAuditEntries returns changes of the user made in the [from, to) range, oldest first.
+// Zero times leave the range unbounded.
+func (m *UserManager) ListAuditEntries(ctx context.Context, userId string, from, to time.Time, offset, limit uint) ([]entity.AuditEntry, error) {
+	return m.storage.ListAuditEntries(ctx, userId, from, to, offset, limit)
+}
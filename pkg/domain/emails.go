@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"context"
+
+	"github.com/krixlion/dev_forum-user/pkg/email"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+)
+
+var (
+	// ErrInvalidEmail is wrapped by errors describing why the email is not valid.
+	ErrInvalidEmail = email.ErrInvalid
+	// ErrEmailNotAllowed is returned when the email belongs to a domain denied by the email policy.
+	ErrEmailNotAllowed = email.ErrDomainNotAllowed
+	// ErrEmailTaken is returned when another user has the email or, if plus-addressing is folded,
+	// one delivered to the same mailbox.
+	ErrEmailTaken = storage.ErrEmailTaken
+)
+
+// emailKey returns the key of the canonical address, which the storage keeps unique:
+// the address itself or, if plus-addressing is folded, its email.Key.
+func (m *UserManager) emailKey(address string) string {
+	if m.config.FoldEmailPlus {
+		return email.Key(address)
+	}
+	return address
+}
+
+// checkEmail returns ErrEmailNotAllowed if the domain of the canonical address is not allowed
+// and ErrEmailTaken if a user other than userId has the address.
+// Users taking the same address at the same time are told apart by the storage,
+// which fails all but one of them with ErrEmailTaken.
+func (m *UserManager) checkEmail(ctx context.Context, userId, address string) error {
+	if err := m.config.EmailPolicy.Check(address); err != nil {
+		return err
+	}
+
+	var key string
+	if m.config.FoldEmailPlus {
+		key = email.Key(address)
+	}
+
+	taken, err := m.storage.EmailTaken(ctx, address, key, userId)
+	if err != nil {
+		return err
+	}
+
+	if taken {
+		return ErrEmailTaken
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/krixlion/dev_forum-lib/mocks"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	"github.com/krixlion/dev_forum-user/pkg/email"
+	"github.com/krixlion/dev_forum-user/pkg/storage/storagemocks"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUserManager_checkEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		arg      string
+		foldPlus bool
+		wantKey  string
+		taken    bool
+		wantErr  error
+	}{
+		{
+			name: "Test if accepts emails which are not taken",
+			arg:  "bob+forum@example.com",
+		},
+		{
+			name:    "Test if rejects taken emails",
+			arg:     "bob+forum@example.com",
+			taken:   true,
+			wantErr: ErrEmailTaken,
+		},
+		{
+			name:     "Test if looks up keys if plus-addressing is folded",
+			arg:      "Bob+forum@example.com",
+			foldPlus: true,
+			wantKey:  "bob@example.com",
+		},
+		{
+			name:    "Test if rejects denied domains",
+			arg:     "bob@mail.mailinator.com",
+			wantErr: ErrEmailNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := storagemocks.NewStorage()
+			db.On("EmailTaken", mock.Anything, tt.arg, tt.wantKey, "id").Return(tt.taken, nil)
+
+			policy, err := email.NewPolicy(nil, []string{"mailinator.com"})
+			if err != nil {
+				t.Fatalf("Failed to create email policy: %v", err)
+			}
+
+			m := NewUserManager(Config{
+				BcryptCost:    bcrypt.MinCost,
+				EmailPolicy:   policy,
+				FoldEmailPlus: tt.foldPlus,
+			}, Dependencies{
+				Storage: db,
+				Broker:  mocks.NewBroker(),
+				Tracer:  nulls.NullTracer{},
+			})
+
+			if err := m.checkEmail(context.Background(), "id", tt.arg); !errors.Is(err, tt.wantErr) {
+				t.Errorf("UserManager.checkEmail() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUserManager_emailKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		foldPlus bool
+		want     string
+	}{
+		{
+			name: "Test if keys addresses by themselves",
+			want: "Bob+forum@example.com",
+		},
+		{
+			name:     "Test if folds keys if plus-addressing is folded",
+			foldPlus: true,
+			want:     "bob@example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewUserManager(Config{FoldEmailPlus: tt.foldPlus}, Dependencies{
+				Storage: storagemocks.NewStorage(),
+				Broker:  mocks.NewBroker(),
+				Tracer:  nulls.NullTracer{},
+			})
+
+			if got := m.emailKey("Bob+forum@example.com"); got != tt.want {
+				t.Errorf("UserManager.emailKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/filter"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	"github.com/krixlion/dev_forum-user/pkg/email"
+	"github.com/krixlion/dev_forum-user/pkg/entity"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrNotFound is returned when the user does not exist.
+	ErrNotFound = storage.ErrNotFound
+	// ErrInvalidQuery is returned when users cannot be listed as requested.
+	ErrInvalidQuery = storage.ErrInvalidQuery
+	// ErrInvalidCredentials is returned when the email or the password is wrong.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+type Config struct {
+	// BcryptCost is used to hash passwords.
+	BcryptCost int
+	// ReservedNames, and names looking like them, cannot be taken by users.
+	ReservedNames []string
+	// NameReuseCooldown is how long names released by users cannot be taken by others.
+	NameReuseCooldown time.Duration
+	// EmailPolicy decides which domains emails of users may belong to.
+	EmailPolicy email.Policy
+	// FoldEmailPlus makes emails differing only in plus-addressing tags, or in case,
+	// taken by a single user, eg. bob+forum@example.com along with bob@example.com.
+	FoldEmailPlus bool
+}
+
+// UserManager applies the business rules to users regardless of the transport
+// and publishes events about their changes.
+type UserManager struct {
+	config  Config
+	storage storage.Storage
+	broker  event.Broker
+	tracer  trace.Tracer
+	now     func() time.Time
+	// reserved holds skeletons of the reserved names.
+	reserved map[string]struct{}
+	// dummyHash is compared against passwords of unknown users,
+	// so that verifying them takes as long as verifying existing ones.
+	dummyHash []byte
+}
+
+type Dependencies struct {
+	Storage storage.Storage
+	Broker  event.Broker
+	Tracer  trace.Tracer
+}
+
+func NewUserManager(config Config, d Dependencies) *UserManager {
+	if config.BcryptCost == 0 {
+		config.BcryptCost = bcrypt.MinCost
+	}
+
+	// The hash cannot fail with a valid cost and a short password.
+	dummyHash, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), config.BcryptCost)
+
+	return &UserManager{
+		config:    config,
+		storage:   d.Storage,
+		broker:    d.Broker,
+		tracer:    d.Tracer,
+		now:       time.Now,
+		reserved:  reservedSkeletons(config.ReservedNames),
+		dummyHash: dummyHash,
+	}
+}
+
+// Create saves a new user and returns it with its id assigned.
+func (m *UserManager) Create(ctx context.Context, user entity.User) (entity.User, error) {
+	ctx, span := m.tracer.Start(ctx, "domain.Create")
+	defer span.End()
+
+	user, err := NewUser(user, m.config.BcryptCost, m.now())
+	if err != nil {
+		tracing.SetSpanErr(span, err)
+		return entity.User{}, err
+	}
+
+	if err := m.checkName(ctx, user.Id, user.Name); err != nil {
+		tracing.SetSpanErr(span, err)
+		return entity.User{}, err
+	}
+
+	if err := m.checkEmail(ctx, user.Id, user.Email); err != nil {
+		tracing.SetSpanErr(span, err)
+		return entity.User{}, err
+	}
+	user.EmailKey = m.emailKey(user.Email)
+
+	if err := m.storage.Create(ctx, user, auditInfoFrom(ctx)); err != nil {
+		tracing.SetSpanErr(span, err)
+		return entity.User{}, err
+	}
+
+	if err := m.publish(event.UserCreated, user); err != nil {
+		tracing.SetSpanErr(span, err)
+		return entity.User{}, err
+	}
+
+	return user, nil
+}
+
+// Update saves the user's non-zero fields.
+// A UserRenamed event is published along with the update if the name changed.
+// ErrNotFound is returned, and nothing published, if the user does not exist.
+func (m *UserManager) Update(ctx context.Context, user entity.User) error {
+	ctx, span := m.tracer.Start(ctx, "domain.Update")
+	defer span.End()
+
+	// Unchanged passwords are left out, since their fresh hashes would differ
+	// from the stored ones and be recorded as changes in the audit log.
+	if user.Password != "" {
+		old, err := m.storage.Get(ctx, byId(user.Id), []string{"password"})
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			tracing.SetSpanErr(span, err)
+			return err
+		}
+
+		if old.Password != "" && bcrypt.CompareHashAndPassword([]byte(old.Password), []byte(user.Password)) == nil {
+			user.Password = ""
+		}
+	}
+
+	user, err := UpdatedUser(user, m.config.BcryptCost, m.now())
+	if err != nil {
+		tracing.SetSpanErr(span, err)
+		return err
+	}
+
+	var oldName string
+	if user.Name != "" {
+		if err := m.checkName(ctx, user.Id, user.Name); err != nil {
+			tracing.SetSpanErr(span, err)
+			return err
+		}
+
+		old, err := m.storage.Get(ctx, byId(user.Id), []string{"name"})
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			tracing.SetSpanErr(span, err)
+			return err
+		}
+		oldName = old.Name
+	}
+
+	if user.Email != "" {
+		if err := m.checkEmail(ctx, user.Id, user.Email); err != nil {
+			tracing.SetSpanErr(span, err)
+			return err
+		}
+		user.EmailKey = m.emailKey(user.Email)
+	}
+
+	if err := m.storage.Update(ctx, user, auditInfoFrom(ctx)); err != nil {
+		tracing.SetSpanErr(span, err)
+		return err
+	}
+
+	if err := m.publish(event.UserUpdated, user); err != nil {
+		tracing.SetSpanErr(span, err)
+		return err
+	}
+
+	if oldName != "" && oldName != user.Name {
+		if err := m.publish(UserRenamed, Rename{UserId: user.Id, OldName: oldName, NewName: user.Name}); err != nil {
+			tracing.SetSpanErr(span, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete deletes the user. Deleting a user which does not exist succeeds,
+// so that callers cannot find out whether it existed.
+func (m *UserManager) Delete(ctx context.Context, id string) error {
+	ctx, span := m.tracer.Start(ctx, "domain.Delete")
+	defer span.End()
+
+	if _, err := m.storage.Get(ctx, byId(id), []string{"id"}); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		tracing.SetSpanErr(span, err)
+		return err
+	}
+
+	if err := m.storage.Delete(ctx, id, auditInfoFrom(ctx)); err != nil {
+		// The user was deleted in the meantime.
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		tracing.SetSpanErr(span, err)
+		return err
+	}
+
+	if err := m.publish(event.UserDeleted, id); err != nil {
+		tracing.SetSpanErr(span, err)
+		return err
+	}
+
+	return nil
+}
+
+// Get returns the given fields of the user with the id.
+func (m *UserManager) Get(ctx context.Context, id string, fields []string) (entity.User, error) {
+	return m.storage.Get(ctx, byId(id), fields)
+}
+
+// GetByEmail returns the given fields of the user with the email.
+func (m *UserManager) GetByEmail(ctx context.Context, address string, fields []string) (entity.User, error) {
+	return m.storage.Get(ctx, byEmail(address), fields)
+}
+
+// List returns the given fields of users matching the query.
+func (m *UserManager) List(ctx context.Context, offset, limit string, query storage.FilterGroup, fields []string) ([]entity.User, error) {
+	return m.storage.GetMultiple(ctx, offset, limit, query, fields)
+}
+
+// Search returns users with names similar to the phrase ordered by relevance.
+func (m *UserManager) Search(ctx context.Context, phrase string, offset, limit uint) ([]entity.User, error) {
+	return m.storage.Search(ctx, strings.TrimSpace(phrase), offset, limit)
+}
+
+// Verify returns the user with the email if the password matches theirs.
+// Otherwise ErrInvalidCredentials is returned, regardless of whether the user exists.
+func (m *UserManager) Verify(ctx context.Context, address, password string) (entity.User, error) {
+	ctx, span := m.tracer.Start(ctx, "domain.Verify")
+	defer span.End()
+
+	user, err := m.storage.Get(ctx, byEmail(address), []string{"id", "name", "email", "password"})
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			bcrypt.CompareHashAndPassword(m.dummyHash, []byte(password))
+			return entity.User{}, ErrInvalidCredentials
+		}
+		tracing.SetSpanErr(span, err)
+		return entity.User{}, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return entity.User{}, ErrInvalidCredentials
+	}
+
+	user.Password = ""
+	return user, nil
+}
+
+func (m *UserManager) publish(eventType event.EventType, body interface{}) error {
+	e, err := event.MakeEvent(event.UserAggregate, eventType, body)
+	if err != nil {
+		return err
+	}
+
+	return m.broker.ResilientPublish(e)
+}
+
+func byId(id string) filter.Filter {
+	return filter.Filter{{
+		Attribute: "id",
+		Operator:  filter.Equal,
+		Value:     id,
+	}}
+}
+
+// byEmail matches the canonical form of the address, so that eg. the case of its domain does not matter.
+// Addresses which are not valid are matched as given and so are not found.
+func byEmail(address string) filter.Filter {
+	if canonical, err := email.Canonicalize(address); err == nil {
+		address = canonical
+	}
+
+	return filter.Filter{{
+		Attribute: "email",
+		Operator:  filter.Equal,
+		Value:     address,
+	}}
+}
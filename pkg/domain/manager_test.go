@@ -0,0 +1,275 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/mocks"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	"github.com/krixlion/dev_forum-user/pkg/entity"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+	"github.com/krixlion/dev_forum-user/pkg/storage/storagemocks"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func setUpManager(db storagemocks.Storage, broker mocks.Broker) *UserManager {
+	return NewUserManager(Config{BcryptCost: bcrypt.MinCost}, Dependencies{
+		Storage: db,
+		Broker:  broker,
+		Tracer:  nulls.NullTracer{},
+	})
+}
+
+func TestUserManager_Create(t *testing.T) {
+	tests := []struct {
+		name        string
+		storage     storagemocks.Storage
+		wantErr     bool
+		wantPublish int
+	}{
+		{
+			name: "Test if saves the user and publishes an event",
+			storage: func() storagemocks.Storage {
+				m := storagemocks.NewStorage()
+				m.On("EmailTaken", mock.Anything, "krixlion@example.com", "", mock.AnythingOfType("string")).Return(false, nil).Once()
+				m.On("Create", mock.Anything, mock.AnythingOfType("entity.User"), mock.AnythingOfType("storage.AuditInfo")).Return(nil).Once()
+				return m
+			}(),
+			wantPublish: 1,
+		},
+		{
+			name: "Test if does not publish an event on storage error",
+			storage: func() storagemocks.Storage {
+				m := storagemocks.NewStorage()
+				m.On("EmailTaken", mock.Anything, "krixlion@example.com", "", mock.AnythingOfType("string")).Return(false, nil).Once()
+				m.On("Create", mock.Anything, mock.AnythingOfType("entity.User"), mock.AnythingOfType("storage.AuditInfo")).Return(errors.New("test err")).Once()
+				return m
+			}(),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := mocks.NewBroker()
+			broker.On("ResilientPublish", mock.AnythingOfType("event.Event")).Return(nil)
+
+			m := setUpManager(tt.storage, broker)
+			m.now = func() time.Time { return time.Date(2026, 10, 19, 14, 0, 0, 0, time.UTC) }
+
+			got, err := m.Create(context.Background(), entity.User{Name: "krixlion", Email: "krixlion@Example.com", Password: "12345678"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("UserManager.Create() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			broker.AssertNumberOfCalls(t, "ResilientPublish", tt.wantPublish)
+
+			if tt.wantErr {
+				return
+			}
+
+			if got.Id == "" || got.Password == "12345678" || !got.CreatedAt.Equal(m.now()) {
+				t.Errorf("UserManager.Create() returned user without business rules applied: %+v", got)
+			}
+		})
+	}
+}
+
+func TestUserManager_Update(t *testing.T) {
+	db := storagemocks.NewStorage()
+	db.On("Update", mock.Anything, mock.MatchedBy(func(user entity.User) bool {
+		return user.Id == "id" && user.Password == "" && !user.UpdatedAt.IsZero()
+	}), AuditInfo{Actor: "user:1", RequestId: "request"}).Return(nil).Once()
+	db.On("Get", mock.Anything, byId("id"), []string{"name"}).Return(entity.User{Id: "id", Name: "krixlion"}, nil).Once()
+
+	broker := mocks.NewBroker()
+	broker.On("ResilientPublish", mock.AnythingOfType("event.Event")).Return(nil).Once()
+
+	ctx := WithAuditInfo(context.Background(), AuditInfo{Actor: "user:1", RequestId: "request"})
+	if err := setUpManager(db, broker).Update(ctx, entity.User{Id: "id", Name: "krixlion"}); err != nil {
+		t.Errorf("UserManager.Update() error = %v", err)
+	}
+
+	db.AssertExpectations(t)
+	broker.AssertExpectations(t)
+}
+
+func TestUserManager_Update_notFound(t *testing.T) {
+	db := storagemocks.NewStorage()
+	db.On("Update", mock.Anything, mock.AnythingOfType("entity.User"), mock.AnythingOfType("storage.AuditInfo")).Return(storage.ErrNotFound).Once()
+
+	broker := mocks.NewBroker()
+
+	if err := setUpManager(db, broker).Update(context.Background(), entity.User{Id: "id"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UserManager.Update() error = %v, want %v", err, ErrNotFound)
+	}
+
+	db.AssertExpectations(t)
+	broker.AssertNumberOfCalls(t, "ResilientPublish", 0)
+}
+
+func TestUserManager_Update_password(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("12345678"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		password     string
+		wantPassword bool
+	}{
+		{
+			name:     "Test if leaves out unchanged passwords",
+			password: "12345678",
+		},
+		{
+			name:         "Test if hashes changed passwords",
+			password:     "87654321",
+			wantPassword: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := storagemocks.NewStorage()
+			db.On("Get", mock.Anything, byId("id"), []string{"password"}).Return(entity.User{Id: "id", Password: string(hash)}, nil).Once()
+			db.On("Update", mock.Anything, mock.MatchedBy(func(user entity.User) bool {
+				changed := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(tt.password)) == nil
+				return user.Id == "id" && changed == tt.wantPassword && (changed || user.Password == "")
+			}), mock.AnythingOfType("storage.AuditInfo")).Return(nil).Once()
+
+			broker := mocks.NewBroker()
+			broker.On("ResilientPublish", mock.AnythingOfType("event.Event")).Return(nil).Once()
+
+			if err := setUpManager(db, broker).Update(context.Background(), entity.User{Id: "id", Password: tt.password}); err != nil {
+				t.Errorf("UserManager.Update() error = %v", err)
+			}
+
+			db.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUserManager_Delete(t *testing.T) {
+	tests := []struct {
+		name          string
+		storage       storagemocks.Storage
+		wantErr       bool
+		wantDeletes   int
+		wantPublishes int
+	}{
+		{
+			name: "Test if deletes existing users and publishes an event",
+			storage: func() storagemocks.Storage {
+				m := storagemocks.NewStorage()
+				m.On("Get", mock.Anything, byId("id"), []string{"id"}).Return(entity.User{Id: "id"}, nil).Once()
+				m.On("Delete", mock.Anything, "id", mock.AnythingOfType("storage.AuditInfo")).Return(nil).Once()
+				return m
+			}(),
+			wantDeletes:   1,
+			wantPublishes: 1,
+		},
+		{
+			name: "Test if succeeds without deleting users which do not exist",
+			storage: func() storagemocks.Storage {
+				m := storagemocks.NewStorage()
+				m.On("Get", mock.Anything, byId("id"), []string{"id"}).Return(entity.User{}, storage.ErrNotFound).Once()
+				return m
+			}(),
+		},
+		{
+			name: "Test if succeeds without publishing if the user was deleted in the meantime",
+			storage: func() storagemocks.Storage {
+				m := storagemocks.NewStorage()
+				m.On("Get", mock.Anything, byId("id"), []string{"id"}).Return(entity.User{Id: "id"}, nil).Once()
+				m.On("Delete", mock.Anything, "id", mock.AnythingOfType("storage.AuditInfo")).Return(storage.ErrNotFound).Once()
+				return m
+			}(),
+			wantDeletes: 1,
+		},
+		{
+			name: "Test if fails on other storage errors",
+			storage: func() storagemocks.Storage {
+				m := storagemocks.NewStorage()
+				m.On("Get", mock.Anything, byId("id"), []string{"id"}).Return(entity.User{}, errors.New("test err")).Once()
+				return m
+			}(),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := mocks.NewBroker()
+			broker.On("ResilientPublish", mock.AnythingOfType("event.Event")).Return(nil)
+
+			err := setUpManager(tt.storage, broker).Delete(context.Background(), "id")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("UserManager.Delete() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			tt.storage.AssertNumberOfCalls(t, "Delete", tt.wantDeletes)
+			broker.AssertNumberOfCalls(t, "ResilientPublish", tt.wantPublishes)
+		})
+	}
+}
+
+func TestUserManager_Search(t *testing.T) {
+	db := storagemocks.NewStorage()
+	db.On("Search", mock.Anything, "krix", uint(0), uint(10)).Return([]entity.User{}, nil).Once()
+
+	if _, err := setUpManager(db, mocks.NewBroker()).Search(context.Background(), "  krix ", 0, 10); err != nil {
+		t.Errorf("UserManager.Search() error = %v", err)
+	}
+
+	db.AssertExpectations(t)
+}
+
+func TestUserManager_Verify(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("12345678"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		getErr   error
+		wantErr  error
+	}{
+		{
+			name:     "Test if returns the user on matching password",
+			password: "12345678",
+		},
+		{
+			name:     "Test if fails on wrong password",
+			password: "87654321",
+			wantErr:  ErrInvalidCredentials,
+		},
+		{
+			name:     "Test if fails the same way for unknown users",
+			password: "12345678",
+			getErr:   storage.ErrNotFound,
+			wantErr:  ErrInvalidCredentials,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := storagemocks.NewStorage()
+			db.On("Get", mock.Anything, byEmail("krixlion@example.com"), mock.Anything).Return(entity.User{Id: "id", Password: string(hash)}, tt.getErr).Once()
+
+			got, err := setUpManager(db, mocks.NewBroker()).Verify(context.Background(), "krixlion@example.com", tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("UserManager.Verify() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr == nil && (got.Id != "id" || got.Password != "") {
+				t.Errorf("UserManager.Verify() = %+v, want the user without the password", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+	"github.com/krixlion/dev_forum-user/pkg/username"
+)
+
+var (
+	// ErrNameTaken is returned when the name looks like the name of another user
+	// or one released by another user within the reuse cooldown.
+	ErrNameTaken = storage.ErrNameTaken
+	// ErrNameReserved is returned when the name looks like a reserved name or a tombstone.
+	ErrNameReserved = errors.New("name is reserved")
+	// ErrInvalidName is wrapped by errors describing why the name is not valid.
+	ErrInvalidName = username.ErrInvalid
+)
+
+// UserRenamed events announce a change of the user's name with a Rename body.
+const UserRenamed event.EventType = "user-renamed"
+
+// Rename is the body of UserRenamed events.
+type Rename struct {
+	UserId  string `json:"user_id"`
+	OldName string `json:"old_name"`
+	NewName string `json:"new_name"`
+}
+
+// reservedSkeletons returns skeletons of the names, so that names looking like them are reserved too.
+func reservedSkeletons(names []string) map[string]struct{} {
+	skeletons := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		skeletons[username.Skeleton(name)] = struct{}{}
+	}
+	return skeletons
+}
+
+// checkName returns ErrNameReserved if the name looks like a reserved name or a tombstone
+// and ErrNameTaken if it looks like a name released by a user other than userId within the reuse cooldown.
+// Names of current users are left to the storage, which checks them atomically.
+func (m *UserManager) checkName(ctx context.Context, userId, name string) error {
+	skeleton := username.Skeleton(name)
+
+	if _, ok := m.reserved[skeleton]; ok || strings.HasPrefix(skeleton, username.Skeleton(Tombstone(""))) {
+		return ErrNameReserved
+	}
+
+	if m.config.NameReuseCooldown <= 0 {
+		return nil
+	}
+
+	releasedAt, err := m.storage.NameReleasedAt(ctx, skeleton, userId)
+	if err != nil {
+		return err
+	}
+
+	if !releasedAt.IsZero() && m.now().Before(releasedAt.Add(m.config.NameReuseCooldown)) {
+		return ErrNameTaken
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/mocks"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	"github.com/krixlion/dev_forum-user/pkg/entity"
+	"github.com/krixlion/dev_forum-user/pkg/storage/storagemocks"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUserManager_checkName(t *testing.T) {
+	now := time.Date(2026, 10, 19, 14, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		arg        string
+		releasedAt time.Time
+		wantErr    error
+	}{
+		{
+			name: "Test if accepts names which were never taken",
+			arg:  "krixlion",
+		},
+		{
+			name:    "Test if rejects reserved names",
+			arg:     "Admin",
+			wantErr: ErrNameReserved,
+		},
+		{
+			name:    "Test if rejects names looking like reserved names",
+			arg:     "аdmіn",
+			wantErr: ErrNameReserved,
+		},
+		{
+			name:    "Test if rejects tombstones",
+			arg:     Tombstone("id"),
+			wantErr: ErrNameReserved,
+		},
+		{
+			name:       "Test if rejects names released within the cooldown",
+			arg:        "krixlion",
+			releasedAt: now.Add(-time.Hour),
+			wantErr:    ErrNameTaken,
+		},
+		{
+			name:       "Test if accepts names released before the cooldown",
+			arg:        "krixlion",
+			releasedAt: now.Add(-time.Hour * 25),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := storagemocks.NewStorage()
+			db.On("NameReleasedAt", mock.Anything, mock.AnythingOfType("string"), "id").Return(tt.releasedAt, nil)
+
+			m := NewUserManager(Config{
+				BcryptCost:        bcrypt.MinCost,
+				ReservedNames:     []string{"admin"},
+				NameReuseCooldown: time.Hour * 24,
+			}, Dependencies{
+				Storage: db,
+				Broker:  mocks.NewBroker(),
+				Tracer:  nulls.NullTracer{},
+			})
+			m.now = func() time.Time { return now }
+
+			if err := m.checkName(context.Background(), "id", tt.arg); !errors.Is(err, tt.wantErr) {
+				t.Errorf("UserManager.checkName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUserManager_Update_rename(t *testing.T) {
+	db := storagemocks.NewStorage()
+	db.On("Get", mock.Anything, byId("id"), []string{"name"}).Return(entity.User{Id: "id", Name: "krixlion"}, nil).Once()
+	db.On("Update", mock.Anything, mock.AnythingOfType("entity.User"), mock.AnythingOfType("storage.AuditInfo")).Return(nil).Once()
+
+	broker := mocks.NewBroker()
+	broker.On("ResilientPublish", mock.MatchedBy(func(e event.Event) bool { return e.Type == event.UserUpdated })).Return(nil).Once()
+	broker.On("ResilientPublish", mock.MatchedBy(func(e event.Event) bool {
+		var rename Rename
+		return e.Type == UserRenamed && json.Unmarshal(e.Body, &rename) == nil &&
+			rename == Rename{UserId: "id", OldName: "krixlion", NewName: "krixlion2"}
+	})).Return(nil).Once()
+
+	if err := setUpManager(db, broker).Update(context.Background(), entity.User{Id: "id", Name: "krixlion2"}); err != nil {
+		t.Errorf("UserManager.Update() error = %v", err)
+	}
+
+	db.AssertExpectations(t)
+	broker.AssertExpectations(t)
+}
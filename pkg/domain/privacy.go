@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	"github.com/krixlion/dev_forum-user/pkg/entity"
+)
+
+// UserErased events announce that personal data of the user in the body was erased.
+// The body holds the id and the tombstone name the user's name was replaced with.
+const UserErased event.EventType = "user-erased"
+
+// exportPageSize limits audit entries fetched at once while exporting users.
+const exportPageSize = 100
+
+// Tombstone returns the name erased users are renamed to.
+// It's derived from the id, since names have to be unique.
+func Tombstone(id string) string {
+	return "erased-" + id
+}
+
+// Export returns everything stored about the user but the password hash.
+func (m *UserManager) Export(ctx context.Context, id string) (entity.UserExport, error) {
+	ctx, span := m.tracer.Start(ctx, "domain.Export")
+	defer span.End()
+
+	user, err := m.storage.Get(ctx, byId(id), nil)
+	if err != nil {
+		tracing.SetSpanErr(span, err)
+		return entity.UserExport{}, err
+	}
+	user.Password = ""
+
+	articleIds, err := m.storage.ListArticleIds(ctx, id)
+	if err != nil {
+		tracing.SetSpanErr(span, err)
+		return entity.UserExport{}, err
+	}
+
+	entries := []entity.AuditEntry{}
+	for offset := uint(0); ; offset += exportPageSize {
+		page, err := m.storage.ListAuditEntries(ctx, id, time.Time{}, time.Time{}, offset, exportPageSize)
+		if err != nil {
+			tracing.SetSpanErr(span, err)
+			return entity.UserExport{}, err
+		}
+
+		entries = append(entries, page...)
+		if len(page) < exportPageSize {
+			break
+		}
+	}
+
+	return entity.UserExport{
+		User:         user,
+		ArticleIds:   articleIds,
+		AuditEntries: entries,
+		ExportedAt:   m.now(),
+	}, nil
+}
+
+// Erase anonymizes the user's personal data in place, so that ids referenced
+// by other services, eg. as authors of articles, stay valid. Its name is replaced
+// with the Tombstone, its email hashed and its password cleared, so that it cannot log in.
+// Erasing a user which was already erased only publishes the event again.
+func (m *UserManager) Erase(ctx context.Context, id string) error {
+	ctx, span := m.tracer.Start(ctx, "domain.Erase")
+	defer span.End()
+
+	tombstone := Tombstone(id)
+	if err := m.storage.Erase(ctx, id, tombstone, auditInfoFrom(ctx)); err != nil {
+		tracing.SetSpanErr(span, err)
+		return err
+	}
+
+	if err := m.publish(UserErased, entity.User{Id: id, Name: tombstone, UpdatedAt: m.now()}); err != nil {
+		tracing.SetSpanErr(span, err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/mocks"
+	"github.com/krixlion/dev_forum-user/pkg/entity"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+	"github.com/krixlion/dev_forum-user/pkg/storage/storagemocks"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUserManager_Export(t *testing.T) {
+	now := time.Date(2026, 10, 19, 14, 0, 0, 0, time.UTC)
+	user := entity.User{Id: "id", Name: "krixlion", Email: "krixlion@example.com", Password: "hash", CreatedAt: now}
+
+	// Entries span more than one page.
+	entries := make([]entity.AuditEntry, exportPageSize+1)
+	for i := range entries {
+		entries[i] = entity.AuditEntry{UserId: "id", Action: entity.AuditUpdate}
+	}
+
+	db := storagemocks.NewStorage()
+	db.On("Get", mock.Anything, byId("id"), []string(nil)).Return(user, nil).Once()
+	db.On("ListArticleIds", mock.Anything, "id").Return([]string{"article"}, nil).Once()
+	db.On("ListAuditEntries", mock.Anything, "id", time.Time{}, time.Time{}, uint(0), uint(exportPageSize)).Return(entries[:exportPageSize], nil).Once()
+	db.On("ListAuditEntries", mock.Anything, "id", time.Time{}, time.Time{}, uint(exportPageSize), uint(exportPageSize)).Return(entries[exportPageSize:], nil).Once()
+
+	m := setUpManager(db, mocks.NewBroker())
+	m.now = func() time.Time { return now }
+
+	got, err := m.Export(context.Background(), "id")
+	if err != nil {
+		t.Fatalf("UserManager.Export() error = %v", err)
+	}
+
+	user.Password = ""
+	want := entity.UserExport{
+		User:         user,
+		ArticleIds:   []string{"article"},
+		AuditEntries: entries,
+		ExportedAt:   now,
+	}
+
+	if !cmp.Equal(got, want) {
+		t.Errorf("UserManager.Export():\n got = %+v\n want = %+v", got, want)
+	}
+
+	db.AssertExpectations(t)
+}
+
+func TestUserManager_Erase(t *testing.T) {
+	tests := []struct {
+		name        string
+		storage     storagemocks.Storage
+		wantErr     error
+		wantPublish int
+	}{
+		{
+			name: "Test if erases the user and publishes an event",
+			storage: func() storagemocks.Storage {
+				m := storagemocks.NewStorage()
+				m.On("Erase", mock.Anything, "id", Tombstone("id"), AuditInfo{Actor: "identity:admin-service"}).Return(nil).Once()
+				return m
+			}(),
+			wantPublish: 1,
+		},
+		{
+			name: "Test if does not publish an event for users which do not exist",
+			storage: func() storagemocks.Storage {
+				m := storagemocks.NewStorage()
+				m.On("Erase", mock.Anything, "id", Tombstone("id"), mock.AnythingOfType("storage.AuditInfo")).Return(storage.ErrNotFound).Once()
+				return m
+			}(),
+			wantErr: ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := mocks.NewBroker()
+			broker.On("ResilientPublish", mock.MatchedBy(func(e event.Event) bool {
+				var user entity.User
+				return e.Type == UserErased && json.Unmarshal(e.Body, &user) == nil && user.Id == "id" && user.Name == Tombstone("id")
+			})).Return(nil)
+
+			ctx := WithAuditInfo(context.Background(), AuditInfo{Actor: "identity:admin-service"})
+			if err := setUpManager(tt.storage, broker).Erase(ctx, "id"); !errors.Is(err, tt.wantErr) {
+				t.Errorf("UserManager.Erase() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			broker.AssertNumberOfCalls(t, "ResilientPublish", tt.wantPublish)
+			tt.storage.AssertExpectations(t)
+		})
+	}
+}
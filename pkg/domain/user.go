@@ -0,0 +1,101 @@
+// Package domain holds the business rules applied to users
+// regardless of the transport they were received with.
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/krixlion/dev_forum-user/pkg/email"
+	"github.com/krixlion/dev_forum-user/pkg/entity"
+	"github.com/krixlion/dev_forum-user/pkg/username"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrPasswordTooLong is returned for passwords longer than bcrypt's limit of 72 bytes,
+// which passwords of multi-byte characters exceed before reaching 72 characters.
+var ErrPasswordTooLong = errors.New("password must be at most 72 bytes long")
+
+// NewUser returns the user ready to be created. It's assigned a new id,
+// so that users cannot choose their own, its name is normalized, its email canonicalized,
+// its password hashed with the given bcrypt cost and its creation time is set to now.
+// Returns ErrInvalidName, ErrInvalidEmail or ErrPasswordTooLong if the name, the email or the password is not valid.
+func NewUser(user entity.User, bcryptCost int, now time.Time) (entity.User, error) {
+	name, err := username.Normalize(user.Name)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	address, err := email.Canonicalize(user.Email)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	hash, err := hashPassword(user.Password, bcryptCost)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	user.Id = id.String()
+	user.Name = name
+	user.Email = address
+	user.Password = hash
+	user.CreatedAt = now
+	user.UpdatedAt = time.Time{}
+
+	return user, nil
+}
+
+// UpdatedUser returns the user's changes ready to be saved. Its name, if changed, is normalized,
+// its email, if changed, canonicalized, its password, if changed, hashed, its creation time cleared,
+// so that it cannot be overwritten, and its update time set to now.
+// Returns ErrInvalidName, ErrInvalidEmail or ErrPasswordTooLong if the name, the email or the password is not valid.
+func UpdatedUser(user entity.User, bcryptCost int, now time.Time) (entity.User, error) {
+	if user.Name != "" {
+		name, err := username.Normalize(user.Name)
+		if err != nil {
+			return entity.User{}, err
+		}
+		user.Name = name
+	}
+
+	if user.Email != "" {
+		address, err := email.Canonicalize(user.Email)
+		if err != nil {
+			return entity.User{}, err
+		}
+		user.Email = address
+	}
+
+	if user.Password != "" {
+		hash, err := hashPassword(user.Password, bcryptCost)
+		if err != nil {
+			return entity.User{}, err
+		}
+		user.Password = hash
+	}
+
+	user.CreatedAt = time.Time{}
+	user.UpdatedAt = now
+
+	return user, nil
+}
+
+func hashPassword(password string, bcryptCost int) (string, error) {
+	if len(password) > 72 {
+		return "", ErrPasswordTooLong
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
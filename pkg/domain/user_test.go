@@ -0,0 +1,198 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/krixlion/dev_forum-user/pkg/entity"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestNewUser(t *testing.T) {
+	now := time.Date(2026, 10, 19, 14, 0, 0, 0, time.UTC)
+	user := entity.User{
+		Id:        "chosen-id",
+		Name:      "ｋｒｉｘｌｉｏｎ",
+		Email:     `"Krix Lion" <krixlion@Example.com>`,
+		Password:  "12345678",
+		UpdatedAt: now.Add(time.Hour),
+	}
+
+	got, err := NewUser(user, bcrypt.MinCost, now)
+	if err != nil {
+		t.Fatalf("NewUser() error = %v", err)
+	}
+
+	if _, err := uuid.FromString(got.Id); err != nil || got.Id == user.Id {
+		t.Errorf("NewUser() id = %q, want a new UUID", got.Id)
+	}
+
+	if want := "krixlion"; got.Name != want {
+		t.Errorf("NewUser() name = %q, want %q", got.Name, want)
+	}
+
+	if want := "krixlion@example.com"; got.Email != want {
+		t.Errorf("NewUser() email = %q, want %q", got.Email, want)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(got.Password), []byte(user.Password)); err != nil {
+		t.Errorf("NewUser() password is not hashed: %v", err)
+	}
+
+	if !got.CreatedAt.Equal(now) || !got.UpdatedAt.IsZero() {
+		t.Errorf("NewUser() createdAt = %v, updatedAt = %v, want %v and zero", got.CreatedAt, got.UpdatedAt, now)
+	}
+}
+
+func TestNewUser_invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    entity.User
+		wantErr error
+	}{
+		{
+			name:    "Test if rejects invalid names",
+			user:    entity.User{Name: "<b>krixlion</b>", Email: "krixlion@example.com", Password: "12345678"},
+			wantErr: ErrInvalidName,
+		},
+		{
+			name:    "Test if rejects invalid emails",
+			user:    entity.User{Name: "krixlion", Email: "krixlion", Password: "12345678"},
+			wantErr: ErrInvalidEmail,
+		},
+		{
+			name:    "Test if rejects passwords longer than 72 bytes",
+			user:    entity.User{Name: "krixlion", Email: "krixlion@example.com", Password: strings.Repeat("ż", 37)},
+			wantErr: ErrPasswordTooLong,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewUser(tt.user, bcrypt.MinCost, time.Now()); !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewUser() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdatedUser(t *testing.T) {
+	now := time.Date(2026, 10, 19, 14, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		user         entity.User
+		wantName     string
+		wantEmail    string
+		wantPassword bool
+		wantErr      error
+	}{
+		{
+			name: "Test if hashes changed password",
+			user: entity.User{
+				Id:        "id",
+				Name:      "krixlion",
+				Password:  "12345678",
+				CreatedAt: now.Add(-time.Hour),
+			},
+			wantName:     "krixlion",
+			wantPassword: true,
+		},
+		{
+			name: "Test if leaves empty password unchanged",
+			user: entity.User{
+				Id:   "id",
+				Name: "krixlion",
+			},
+			wantName: "krixlion",
+		},
+		{
+			name: "Test if normalizes changed name",
+			user: entity.User{
+				Id:   "id",
+				Name: "ｋｒｉｘｌｉｏｎ",
+			},
+			wantName: "krixlion",
+		},
+		{
+			name: "Test if leaves empty name unchanged",
+			user: entity.User{
+				Id:       "id",
+				Password: "12345678",
+			},
+			wantPassword: true,
+		},
+		{
+			name: "Test if canonicalizes changed email",
+			user: entity.User{
+				Id:    "id",
+				Email: "krixlion@Example.com",
+			},
+			wantEmail: "krixlion@example.com",
+		},
+		{
+			name: "Test if rejects invalid email",
+			user: entity.User{
+				Id:    "id",
+				Email: "krixlion",
+			},
+			wantErr: ErrInvalidEmail,
+		},
+		{
+			name: "Test if rejects invalid name",
+			user: entity.User{
+				Id:   "id",
+				Name: "<b>krixlion</b>",
+			},
+			wantErr: ErrInvalidName,
+		},
+		{
+			name: "Test if rejects passwords longer than 72 bytes",
+			user: entity.User{
+				Id:       "id",
+				Password: strings.Repeat("ż", 37),
+			},
+			wantErr: ErrPasswordTooLong,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UpdatedUser(tt.user, bcrypt.MinCost, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdatedUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if got.Id != tt.user.Id {
+				t.Errorf("UpdatedUser() id = %q, want %q", got.Id, tt.user.Id)
+			}
+
+			if got.Name != tt.wantName {
+				t.Errorf("UpdatedUser() name = %q, want %q", got.Name, tt.wantName)
+			}
+
+			if got.Email != tt.wantEmail {
+				t.Errorf("UpdatedUser() email = %q, want %q", got.Email, tt.wantEmail)
+			}
+
+			if !got.CreatedAt.IsZero() || !got.UpdatedAt.Equal(now) {
+				t.Errorf("UpdatedUser() createdAt = %v, updatedAt = %v, want zero and %v", got.CreatedAt, got.UpdatedAt, now)
+			}
+
+			if !tt.wantPassword {
+				if got.Password != "" {
+					t.Errorf("UpdatedUser() password = %q, want empty", got.Password)
+				}
+				return
+			}
+
+			if err := bcrypt.CompareHashAndPassword([]byte(got.Password), []byte(tt.user.Password)); err != nil {
+				t.Errorf("UpdatedUser() password is not hashed: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// Package email canonicalizes email addresses and decides which domains they may belong to.
+package email
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// ErrInvalid is wrapped by errors describing why an address is not valid.
+var ErrInvalid = errors.New("invalid email")
+
+// Canonicalize returns the addr-spec of the address, eg. bob@example.com of "Bob" <bob@Example.com>,
+// with its domain lowercased and IDNA-encoded, or an error wrapping ErrInvalid.
+// Local parts are kept as given, since they may be case-sensitive.
+// Addresses whose local parts have to be quoted are not supported.
+func Canonicalize(address string) (string, error) {
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	at := strings.LastIndexByte(parsed.Address, '@')
+	domain, err := Domain(parsed.Address[at+1:])
+	if err != nil {
+		return "", err
+	}
+
+	canonical := parsed.Address[:at] + "@" + domain
+	if reparsed, err := mail.ParseAddress(canonical); err != nil || reparsed.Address != canonical {
+		return "", fmt.Errorf("%w: local part must not need quoting", ErrInvalid)
+	}
+
+	return canonical, nil
+}
+
+// Domain returns the domain lowercased and IDNA-encoded, eg. xn--bcher-kva.de of Bücher.de,
+// or an error wrapping ErrInvalid.
+func Domain(domain string) (string, error) {
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", fmt.Errorf("%w: domain %q: %v", ErrInvalid, domain, err)
+	}
+
+	if !strings.Contains(ascii, ".") {
+		return "", fmt.Errorf("%w: domain %q must have a top-level domain", ErrInvalid, domain)
+	}
+
+	return ascii, nil
+}
+
+// Key returns the canonical address lowercased and without its plus-addressing tag,
+// eg. bob@example.com of Bob+forum@example.com, so that addresses which most providers
+// deliver to the same mailbox share a key.
+func Key(address string) string {
+	address = strings.ToLower(address)
+
+	at := strings.LastIndexByte(address, '@')
+	if at < 0 {
+		return address
+	}
+
+	local, domain := address[:at], address[at+1:]
+	if plus := strings.IndexByte(local, '+'); plus > 0 {
+		local = local[:plus]
+	}
+
+	return local + "@" + domain
+}
@@ -0,0 +1,114 @@
+package email
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		arg     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Test if keeps canonical addresses",
+			arg:  "bob@example.com",
+			want: "bob@example.com",
+		},
+		{
+			name: "Test if strips display names",
+			arg:  `"Bob" <bob@example.com>`,
+			want: "bob@example.com",
+		},
+		{
+			name: "Test if lowercases only the domain",
+			arg:  "Bob@Example.COM",
+			want: "Bob@example.com",
+		},
+		{
+			name: "Test if IDNA-encodes the domain",
+			arg:  "bob@Bücher.de",
+			want: "bob@xn--bcher-kva.de",
+		},
+		{
+			name: "Test if keeps plus-addressing tags",
+			arg:  "bob+forum@example.com",
+			want: "bob+forum@example.com",
+		},
+		{
+			name:    "Test if rejects addresses without a domain",
+			arg:     "bob",
+			wantErr: true,
+		},
+		{
+			name:    "Test if rejects domains without a top-level domain",
+			arg:     "bob@localhost",
+			wantErr: true,
+		},
+		{
+			name:    "Test if rejects invalid domains",
+			arg:     "bob@exa_mple.com",
+			wantErr: true,
+		},
+		{
+			name:    "Test if rejects quoted local parts",
+			arg:     `"bob smith"@example.com`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Canonicalize(tt.arg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Canonicalize() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if err != nil && !errors.Is(err, ErrInvalid) {
+				t.Errorf("Canonicalize() error = %v, want wrapped %v", err, ErrInvalid)
+			}
+
+			if got != tt.want {
+				t.Errorf("Canonicalize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  string
+		want string
+	}{
+		{
+			name: "Test if lowercases addresses",
+			arg:  "Bob@example.com",
+			want: "bob@example.com",
+		},
+		{
+			name: "Test if drops plus-addressing tags",
+			arg:  "bob+forum+news@example.com",
+			want: "bob@example.com",
+		},
+		{
+			name: "Test if keeps local parts starting with a plus",
+			arg:  "+bob@example.com",
+			want: "+bob@example.com",
+		},
+		{
+			name: "Test if keeps plus signs in domains",
+			arg:  "bob@a+b.example.com",
+			want: "bob@a+b.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Key(tt.arg); got != tt.want {
+				t.Errorf("Key() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
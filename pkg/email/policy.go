@@ -0,0 +1,91 @@
+package email
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrDomainNotAllowed is returned when the policy rejects the domain of an address.
+var ErrDomainNotAllowed = errors.New("email domain is not allowed")
+
+// Policy decides which domains addresses may belong to.
+// Rules for a domain apply to its subdomains too. The zero Policy allows all domains.
+type Policy struct {
+	allowed map[string]struct{}
+	denied  map[string]struct{}
+}
+
+// NewPolicy returns a policy denying the denied domains and, unless allowed is empty,
+// every domain which is not allowed. Denied domains take precedence over allowed ones.
+// Returns an error wrapping ErrInvalid if any of the domains is not valid.
+func NewPolicy(allowed, denied []string) (Policy, error) {
+	allowedSet, err := domainSet(allowed)
+	if err != nil {
+		return Policy{}, err
+	}
+
+	deniedSet, err := domainSet(denied)
+	if err != nil {
+		return Policy{}, err
+	}
+
+	return Policy{allowed: allowedSet, denied: deniedSet}, nil
+}
+
+func domainSet(domains []string) (map[string]struct{}, error) {
+	set := make(map[string]struct{}, len(domains))
+	for _, domain := range domains {
+		ascii, err := Domain(domain)
+		if err != nil {
+			return nil, err
+		}
+		set[ascii] = struct{}{}
+	}
+	return set, nil
+}
+
+// Check returns an error wrapping ErrDomainNotAllowed if the domain of the canonical address is not allowed.
+func (p Policy) Check(address string) error {
+	domain := address[strings.LastIndexByte(address, '@')+1:]
+
+	if matches(p.denied, domain) || len(p.allowed) > 0 && !matches(p.allowed, domain) {
+		return fmt.Errorf("%w: %s", ErrDomainNotAllowed, domain)
+	}
+
+	return nil
+}
+
+// matches reports whether the set holds the domain or any of its parent domains.
+func matches(set map[string]struct{}, domain string) bool {
+	for {
+		if _, ok := set[domain]; ok {
+			return true
+		}
+
+		dot := strings.IndexByte(domain, '.')
+		if dot < 0 {
+			return false
+		}
+		domain = domain[dot+1:]
+	}
+}
+
+// ReadDomains returns domains listed one per line, eg. in a disposable email blocklist.
+// Empty lines and lines starting with '#' are skipped.
+func ReadDomains(r io.Reader) ([]string, error) {
+	var domains []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains = append(domains, line)
+	}
+
+	return domains, scanner.Err()
+}
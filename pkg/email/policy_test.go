@@ -0,0 +1,100 @@
+package email
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPolicy_Check(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		denied  []string
+		arg     string
+		wantErr bool
+	}{
+		{
+			name: "Test if the zero policy allows all domains",
+			arg:  "bob@example.com",
+		},
+		{
+			name:    "Test if denies denied domains",
+			denied:  []string{"example.com"},
+			arg:     "bob@example.com",
+			wantErr: true,
+		},
+		{
+			name:    "Test if denies subdomains of denied domains",
+			denied:  []string{"Example.com"},
+			arg:     "bob@mail.example.com",
+			wantErr: true,
+		},
+		{
+			name:   "Test if allows domains merely ending like denied ones",
+			denied: []string{"example.com"},
+			arg:    "bob@myexample.com",
+		},
+		{
+			name:    "Test if allows only allowed domains",
+			allowed: []string{"example.com"},
+			arg:     "bob@example.org",
+			wantErr: true,
+		},
+		{
+			name:    "Test if allows subdomains of allowed domains",
+			allowed: []string{"example.com"},
+			arg:     "bob@mail.example.com",
+		},
+		{
+			name:    "Test if denied domains take precedence",
+			allowed: []string{"example.com"},
+			denied:  []string{"mail.example.com"},
+			arg:     "bob@mail.example.com",
+			wantErr: true,
+		},
+		{
+			name:    "Test if matches IDNA-encoded domains",
+			denied:  []string{"bücher.de"},
+			arg:     "bob@xn--bcher-kva.de",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPolicy(tt.allowed, tt.denied)
+			if err != nil {
+				t.Fatalf("NewPolicy() error = %v", err)
+			}
+
+			err = p.Check(tt.arg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Policy.Check() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if err != nil && !errors.Is(err, ErrDomainNotAllowed) {
+				t.Errorf("Policy.Check() error = %v, want wrapped %v", err, ErrDomainNotAllowed)
+			}
+		})
+	}
+}
+
+func TestNewPolicy_invalidDomain(t *testing.T) {
+	if _, err := NewPolicy(nil, []string{"exa_mple.com"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("NewPolicy() error = %v, want wrapped %v", err, ErrInvalid)
+	}
+}
+
+func TestReadDomains(t *testing.T) {
+	got, err := ReadDomains(strings.NewReader("# Disposable domains\nmailinator.com\n\n  10minutemail.com  \n"))
+	if err != nil {
+		t.Fatalf("ReadDomains() error = %v", err)
+	}
+
+	if want := []string{"mailinator.com", "10minutemail.com"}; !cmp.Equal(got, want) {
+		t.Errorf("ReadDomains() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,33 @@
+package entity
+
+import "time"
+
+type AuditAction string
+
+const (
+	AuditCreate AuditAction = "create"
+	AuditUpdate AuditAction = "update"
+	AuditDelete AuditAction = "delete"
+	// AuditErase records anonymizing the user's personal data.
+	AuditErase AuditAction = "erase"
+)
+
+// AuditEntry records a change of a user.
+type AuditEntry struct {
+	Id     string      `json:"id"`
+	UserId string      `json:"user_id"`
+	Actor  string      `json:"actor"`
+	Action AuditAction `json:"action"`
+	// Changes hold only the fields which changed.
+	Changes   []FieldChange `json:"changes"`
+	RequestId string        `json:"request_id,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// FieldChange holds the values of a field before and after a change.
+// Values of sensitive fields are redacted.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
@@ -5,10 +5,16 @@ import (
 )
 
 type User struct {
-	Id        string    `json:"id,omitempty"`
-	Name      string    `json:"name,omitempty"`
-	Email     string    `json:"email,omitempty"`
-	Password  string    `json:"password,omitempty"`
+	Id       string `json:"id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Password string `json:"password,omitempty"`
+	// EmailKey is shared by emails taken by a single user and kept unique by the storage.
+	// It's set along with the email by the domain and never published.
+	EmailKey  string    `json:"-"`
 	CreatedAt time.Time `json:"created_at,omitempty"`
 	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	// LastLoginAt and PostCount are maintained from events of other services.
+	LastLoginAt time.Time `json:"last_login_at,omitempty"`
+	PostCount   int64     `json:"post_count,omitempty"`
 }
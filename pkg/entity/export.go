@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// UserExport holds everything stored about a user, as requested by data subjects.
+type UserExport struct {
+	// User holds all fields but the password hash.
+	User User `json:"user"`
+	// ArticleIds are ids of articles attributed to the user.
+	ArticleIds   []string     `json:"article_ids"`
+	AuditEntries []AuditEntry `json:"audit_entries"`
+	ExportedAt   time.Time    `json:"exported_at"`
+}
@@ -0,0 +1,444 @@
+// Package gateway serves the user service as JSON over HTTP for clients which cannot use gRPC.
+// Requests are handled in-process by the gRPC service implementation
+// through the same interceptors as gRPC requests.
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/krixlion/dev_forum-lib/logging"
+	pb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// maxBodySize limits request bodies.
+const maxBodySize = 1 << 20
+
+var (
+	marshaler   = protojson.MarshalOptions{UseProtoNames: true}
+	unmarshaler = protojson.UnmarshalOptions{}
+)
+
+// marshal encodes the message with HTML characters escaped, eg. < as \u003c.
+// User data, such as names, is stored as given, so it's escaped on output
+// in case the JSON ends up embedded in HTML.
+func marshal(m proto.Message) ([]byte, error) {
+	body, err := marshaler.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	var escaped bytes.Buffer
+	json.HTMLEscape(&escaped, body)
+	return escaped.Bytes(), nil
+}
+
+type Config struct {
+	// ForwardedHeaders are passed to the interceptors as gRPC metadata, eg. the idempotency key.
+	ForwardedHeaders []string
+	// VerifiedHeaders are forwarded like ForwardedHeaders, but only from clients
+	// with verified certificates, eg. the id of the user set by an authenticating proxy.
+	VerifiedHeaders []string
+}
+
+type Dependencies struct {
+	Server pb.UserServiceServer
+	// UnaryInterceptor and StreamInterceptor should be the chains the gRPC server uses. Both are optional.
+	UnaryInterceptor  grpc.UnaryServerInterceptor
+	StreamInterceptor grpc.StreamServerInterceptor
+	Logger            logging.Logger
+}
+
+type Gateway struct {
+	config  Config
+	server  pb.UserServiceServer
+	unary   grpc.UnaryServerInterceptor
+	stream  grpc.StreamServerInterceptor
+	logger  logging.Logger
+	methods map[string]grpc.MethodDesc
+	streams map[string]grpc.StreamDesc
+}
+
+func New(config Config, d Dependencies) *Gateway {
+	g := &Gateway{
+		config:  config,
+		server:  d.Server,
+		unary:   d.UnaryInterceptor,
+		stream:  d.StreamInterceptor,
+		logger:  d.Logger,
+		methods: make(map[string]grpc.MethodDesc, len(pb.UserService_ServiceDesc.Methods)),
+		streams: make(map[string]grpc.StreamDesc, len(pb.UserService_ServiceDesc.Streams)),
+	}
+
+	for _, method := range pb.UserService_ServiceDesc.Methods {
+		g.methods[method.MethodName] = method
+	}
+
+	for _, stream := range pb.UserService_ServiceDesc.Streams {
+		g.streams[stream.StreamName] = stream
+	}
+
+	return g
+}
+
+// Handler returns the handler serving the routes:
+//
+//	POST   /v1/users          Create
+//	GET    /v1/users          GetStream, as newline-delimited JSON
+//	GET    /v1/users:search   SearchUsers
+//	GET    /v1/users:watch    WatchUsers, as newline-delimited JSON
+//	GET    /v1/users/{id}     Get
+//	PATCH  /v1/users/{id}     Update
+//	DELETE /v1/users/{id}     Delete
+//
+// GetSecret is available only over gRPC.
+func (g *Gateway) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/users", g.create)
+	mux.HandleFunc("GET /v1/users", g.list)
+	mux.HandleFunc("GET /v1/users:search", g.search)
+	mux.HandleFunc("GET /v1/users:watch", g.watch)
+	mux.HandleFunc("GET /v1/users/{id}", g.get)
+	mux.HandleFunc("PATCH /v1/users/{id}", g.update)
+	mux.HandleFunc("DELETE /v1/users/{id}", g.delete)
+	return mux
+}
+
+func (g *Gateway) create(w http.ResponseWriter, r *http.Request) {
+	user := &pb.User{}
+	if err := g.decodeBody(w, r, user); err != nil {
+		g.writeError(w, r, err)
+		return
+	}
+
+	resp, err := g.invoke(r, "Create", &pb.CreateUserRequest{User: user})
+	if err != nil {
+		g.writeError(w, r, err)
+		return
+	}
+
+	g.write(w, r, http.StatusCreated, resp)
+}
+
+func (g *Gateway) get(w http.ResponseWriter, r *http.Request) {
+	resp, err := g.invoke(r, "Get", &pb.GetUserRequest{
+		Id:       r.PathValue("id"),
+		ReadMask: fieldMask(r.URL.Query().Get("read_mask")),
+	})
+	if err != nil {
+		g.writeError(w, r, err)
+		return
+	}
+
+	g.write(w, r, http.StatusOK, resp)
+}
+
+func (g *Gateway) update(w http.ResponseWriter, r *http.Request) {
+	user := &pb.User{}
+	if err := g.decodeBody(w, r, user); err != nil {
+		g.writeError(w, r, err)
+		return
+	}
+	user.Id = r.PathValue("id")
+
+	if _, err := g.invoke(r, "Update", &pb.UpdateUserRequest{
+		User:      user,
+		FieldMask: fieldMask(r.URL.Query().Get("update_mask")),
+	}); err != nil {
+		g.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (g *Gateway) delete(w http.ResponseWriter, r *http.Request) {
+	if _, err := g.invoke(r, "Delete", &pb.DeleteUserRequest{Id: r.PathValue("id")}); err != nil {
+		g.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (g *Gateway) search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	req := &pb.SearchUsersRequest{
+		Query:     query.Get("q"),
+		PageToken: query.Get("page_token"),
+	}
+
+	if pageSize := query.Get("page_size"); pageSize != "" {
+		var size uint32
+		if _, err := fmt.Sscan(pageSize, &size); err != nil {
+			g.writeError(w, r, status.Error(codes.InvalidArgument, "Invalid page_size"))
+			return
+		}
+		req.PageSize = size
+	}
+
+	resp, err := g.invoke(r, "SearchUsers", req)
+	if err != nil {
+		g.writeError(w, r, err)
+		return
+	}
+
+	g.write(w, r, http.StatusOK, resp)
+}
+
+// list streams users as newline-delimited JSON.
+func (g *Gateway) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	g.serveStream(w, r, "GetStream", &pb.GetUsersRequest{
+		Offset:   query.Get("offset"),
+		Limit:    query.Get("limit"),
+		Filter:   query.Get("filter"),
+		ReadMask: fieldMask(query.Get("read_mask")),
+	})
+}
+
+// watch streams changes of users and heartbeats as newline-delimited JSON.
+func (g *Gateway) watch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	req := &pb.WatchUsersRequest{
+		UserIds: split(query.Get("user_ids")),
+		Since:   query.Get("since"),
+	}
+
+	for _, name := range split(query.Get("types")) {
+		changeType, ok := pb.UserChange_Type_value[name]
+		if !ok {
+			g.writeError(w, r, status.Errorf(codes.InvalidArgument, "Invalid change type %q", name))
+			return
+		}
+		req.Types = append(req.Types, pb.UserChange_Type(changeType))
+	}
+
+	g.serveStream(w, r, "WatchUsers", req)
+}
+
+// serveStream passes the request through the stream interceptors to the server-streaming method's handler.
+// Errors which occur after the first message was sent are written as the last line, eg. {"error": {...}}.
+func (g *Gateway) serveStream(w http.ResponseWriter, r *http.Request, method string, req proto.Message) {
+	desc, ok := g.streams[method]
+	if !ok {
+		g.writeError(w, r, status.Errorf(codes.Unimplemented, "Method %s not found", method))
+		return
+	}
+
+	stream := &ndjsonStream{
+		ctx: g.context(r),
+		w:   w,
+		req: req,
+	}
+
+	var err error
+	if g.stream == nil {
+		err = desc.Handler(g.server, stream)
+	} else {
+		err = g.stream(g.server, stream, &grpc.StreamServerInfo{
+			FullMethod:     fmt.Sprintf("/%s/%s", pb.UserService_ServiceDesc.ServiceName, method),
+			IsServerStream: true,
+		}, desc.Handler)
+	}
+
+	if err == nil {
+		if !stream.started {
+			// Send headers even if there were no messages.
+			stream.start()
+		}
+		return
+	}
+
+	if !stream.started {
+		g.writeError(w, r, err)
+		return
+	}
+
+	body, marshalErr := marshal(status.Convert(err).Proto())
+	if marshalErr != nil {
+		g.logger.Log(r.Context(), "Failed to marshal stream error", "err", marshalErr)
+		return
+	}
+
+	if _, err := fmt.Fprintf(w, "{\"error\":%s}\n", body); err != nil {
+		g.logger.Log(r.Context(), "Failed to write stream error", "err", err)
+	}
+}
+
+// invoke passes the request through the unary interceptors to the method's handler.
+func (g *Gateway) invoke(r *http.Request, method string, req proto.Message) (interface{}, error) {
+	desc, ok := g.methods[method]
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "Method %s not found", method)
+	}
+
+	dec := func(in interface{}) error {
+		proto.Merge(in.(proto.Message), req)
+		return nil
+	}
+
+	return desc.Handler(g.server, g.context(r), dec, g.unary)
+}
+
+// context returns the request's context carrying what gRPC would propagate,
+// ie. the client's address, its TLS state and the forwarded headers as metadata,
+// so that interceptors and handlers treat HTTP clients just like gRPC ones.
+func (g *Gateway) context(r *http.Request) context.Context {
+	ctx := r.Context()
+
+	headers := g.config.ForwardedHeaders
+	p := &peer.Peer{Addr: remoteAddr(r.RemoteAddr)}
+	if r.TLS != nil {
+		if len(r.TLS.VerifiedChains) > 0 {
+			headers = append(headers[:len(headers):len(headers)], g.config.VerifiedHeaders...)
+		}
+		p.AuthInfo = credentials.TLSInfo{
+			State:          *r.TLS,
+			CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity},
+		}
+	}
+	ctx = peer.NewContext(ctx, p)
+
+	md := metadata.MD{}
+	for _, header := range headers {
+		if values := r.Header.Values(header); len(values) > 0 {
+			md.Append(strings.ToLower(header), values...)
+		}
+	}
+
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+func remoteAddr(addr string) net.Addr {
+	addrPort, err := netip.ParseAddrPort(addr)
+	if err != nil {
+		return &net.TCPAddr{}
+	}
+	return net.TCPAddrFromAddrPort(addrPort)
+}
+
+func (g *Gateway) decodeBody(w http.ResponseWriter, r *http.Request, m proto.Message) error {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "Failed to read body: %v", err)
+	}
+
+	if err := unmarshaler.Unmarshal(body, m); err != nil {
+		return status.Errorf(codes.InvalidArgument, "Invalid body: %v", err)
+	}
+
+	return nil
+}
+
+func (g *Gateway) write(w http.ResponseWriter, r *http.Request, code int, resp interface{}) {
+	body, err := marshal(resp.(proto.Message))
+	if err != nil {
+		g.writeError(w, r, status.Error(codes.Internal, err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+
+	if _, err := w.Write(body); err != nil {
+		g.logger.Log(r.Context(), "Failed to write response", "err", err)
+	}
+}
+
+// writeError writes the error as a google.rpc.Status with the matching HTTP status code.
+func (g *Gateway) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	st := status.Convert(err)
+
+	body, marshalErr := marshal(st.Proto())
+	if marshalErr != nil {
+		g.logger.Log(r.Context(), "Failed to marshal error", "err", marshalErr)
+		http.Error(w, st.Message(), HTTPStatus(st.Code()))
+		return
+	}
+
+	for _, detail := range st.Details() {
+		if retryInfo, ok := detail.(*errdetails.RetryInfo); ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryInfo.GetRetryDelay().AsDuration().Seconds()))))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(HTTPStatus(st.Code()))
+
+	if _, err := w.Write(body); err != nil {
+		g.logger.Log(r.Context(), "Failed to write response", "err", err)
+	}
+}
+
+// split returns comma-separated values or nil if there are none.
+func split(values string) []string {
+	if values == "" {
+		return nil
+	}
+	return strings.Split(values, ",")
+}
+
+// fieldMask returns a mask of comma-separated paths or nil if there are none.
+func fieldMask(paths string) *fieldmaskpb.FieldMask {
+	if paths == "" {
+		return nil
+	}
+	return &fieldmaskpb.FieldMask{Paths: split(paths)}
+}
+
+// HTTPStatus returns the HTTP status code matching the gRPC code.
+func HTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
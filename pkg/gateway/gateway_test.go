@@ -0,0 +1,385 @@
+package gateway
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	pb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-user/pkg/grpc/validation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const testId = "b7c8e7bb-6a3f-4a4a-bd3a-0f0e6fe5f6f4"
+
+// stubServer records the last request and responds with the configured users or error.
+type stubServer struct {
+	pb.UnimplementedUserServiceServer
+	users []*pb.User
+	err   error
+	got   proto.Message
+	ctx   context.Context
+}
+
+func (s *stubServer) Create(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
+	s.got, s.ctx = req, ctx
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &pb.CreateUserResponse{Id: testId}, nil
+}
+
+func (s *stubServer) Update(ctx context.Context, req *pb.UpdateUserRequest) (*emptypb.Empty, error) {
+	s.got, s.ctx = req, ctx
+	return &emptypb.Empty{}, s.err
+}
+
+func (s *stubServer) Delete(ctx context.Context, req *pb.DeleteUserRequest) (*emptypb.Empty, error) {
+	s.got, s.ctx = req, ctx
+	return &emptypb.Empty{}, s.err
+}
+
+func (s *stubServer) Get(ctx context.Context, req *pb.GetUserRequest) (*pb.GetUserResponse, error) {
+	s.got, s.ctx = req, ctx
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &pb.GetUserResponse{User: s.users[0]}, nil
+}
+
+func (s *stubServer) SearchUsers(ctx context.Context, req *pb.SearchUsersRequest) (*pb.SearchUsersResponse, error) {
+	s.got, s.ctx = req, ctx
+	return &pb.SearchUsersResponse{Users: s.users}, s.err
+}
+
+func (s *stubServer) GetStream(req *pb.GetUsersRequest, stream pb.UserService_GetStreamServer) error {
+	s.got, s.ctx = req, stream.Context()
+	for _, user := range s.users {
+		if err := stream.Send(user); err != nil {
+			return err
+		}
+	}
+	return s.err
+}
+
+func (s *stubServer) WatchUsers(req *pb.WatchUsersRequest, stream pb.UserService_WatchUsersServer) error {
+	s.got, s.ctx = req, stream.Context()
+	for _, user := range s.users {
+		if err := stream.Send(&pb.WatchUsersResponse{
+			Token:   "token",
+			Message: &pb.WatchUsersResponse_Change{Change: &pb.UserChange{Type: pb.UserChange_UPDATED, User: user}},
+		}); err != nil {
+			return err
+		}
+	}
+	return s.err
+}
+
+func setUpGateway(server *stubServer) http.Handler {
+	return New(Config{
+		ForwardedHeaders: []string{"Idempotency-Key"},
+	}, Dependencies{
+		Server:            server,
+		UnaryInterceptor:  validation.UnaryServerInterceptor(),
+		StreamInterceptor: validation.StreamServerInterceptor(),
+		Logger:            nulls.NullLogger{},
+	}).Handler()
+}
+
+func TestGateway_Handler(t *testing.T) {
+	tests := []struct {
+		name     string
+		server   *stubServer
+		method   string
+		target   string
+		body     string
+		wantCode int
+		wantBody string
+		wantReq  proto.Message
+	}{
+		{
+			name:     "Test if creates users",
+			server:   &stubServer{},
+			method:   http.MethodPost,
+			target:   "/v1/users",
+			body:     `{"name": "krixlion", "email": "krixlion@example.com", "password": "12345678"}`,
+			wantCode: http.StatusCreated,
+			wantBody: `{"id":"` + testId + `"}`,
+			wantReq: &pb.CreateUserRequest{
+				User: &pb.User{Name: "krixlion", Email: "krixlion@example.com", Password: "12345678"},
+			},
+		},
+		{
+			name:     "Test if rejects malformed bodies",
+			server:   &stubServer{},
+			method:   http.MethodPost,
+			target:   "/v1/users",
+			body:     `{"name": `,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Test if validates requests like gRPC",
+			server:   &stubServer{},
+			method:   http.MethodPost,
+			target:   "/v1/users",
+			body:     `{"name": "krixlion", "email": "invalid email", "password": "12345678"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Test if gets users with the read mask",
+			server:   &stubServer{users: []*pb.User{{Id: testId, Name: "krixlion"}}},
+			method:   http.MethodGet,
+			target:   "/v1/users/" + testId + "?read_mask=id,name",
+			wantCode: http.StatusOK,
+			wantBody: `{"user":{"id":"` + testId + `","name":"krixlion"}}`,
+		},
+		{
+			name:     "Test if escapes HTML in users",
+			server:   &stubServer{users: []*pb.User{{Id: testId, Name: "<b>krix&lion</b>"}}},
+			method:   http.MethodGet,
+			target:   "/v1/users/" + testId,
+			wantCode: http.StatusOK,
+			wantBody: `{"user":{"id":"` + testId + `","name":"\u003cb\u003ekrix\u0026lion\u003c/b\u003e"}}`,
+		},
+		{
+			name:     "Test if maps gRPC codes to HTTP statuses",
+			server:   &stubServer{err: status.Error(codes.NotFound, "User not found")},
+			method:   http.MethodGet,
+			target:   "/v1/users/" + testId,
+			wantCode: http.StatusNotFound,
+			wantBody: `{"code":5,"message":"User not found"}`,
+		},
+		{
+			name:     "Test if deletes users",
+			server:   &stubServer{},
+			method:   http.MethodDelete,
+			target:   "/v1/users/" + testId,
+			wantCode: http.StatusNoContent,
+			wantReq:  &pb.DeleteUserRequest{Id: testId},
+		},
+		{
+			name:     "Test if searches users",
+			server:   &stubServer{users: []*pb.User{{Id: testId, Name: "krixlion"}}},
+			method:   http.MethodGet,
+			target:   "/v1/users:search?q=krix&page_size=10",
+			wantCode: http.StatusOK,
+			wantBody: `{"users":[{"id":"` + testId + `","name":"krixlion"}]}`,
+			wantReq:  &pb.SearchUsersRequest{Query: "krix", PageSize: 10},
+		},
+		{
+			name:     "Test if rejects invalid page sizes",
+			server:   &stubServer{},
+			method:   http.MethodGet,
+			target:   "/v1/users:search?q=krix&page_size=-1",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Test if streams users as NDJSON",
+			server:   &stubServer{users: []*pb.User{{Id: "1"}, {Id: "2"}}},
+			method:   http.MethodGet,
+			target:   "/v1/users?limit=2&filter=name[$prefix]=kri",
+			wantCode: http.StatusOK,
+			wantBody: "{\"id\":\"1\"}\n{\"id\":\"2\"}\n",
+			wantReq:  &pb.GetUsersRequest{Limit: "2", Filter: "name[$prefix]=kri"},
+		},
+		{
+			name:     "Test if escapes HTML in streamed users",
+			server:   &stubServer{users: []*pb.User{{Id: "1", Name: "<b>"}}},
+			method:   http.MethodGet,
+			target:   "/v1/users",
+			wantCode: http.StatusOK,
+			wantBody: `{"id":"1","name":"\u003cb\u003e"}` + "\n",
+		},
+		{
+			name:     "Test if streams an empty list",
+			server:   &stubServer{},
+			method:   http.MethodGet,
+			target:   "/v1/users",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Test if validates stream requests",
+			server:   &stubServer{},
+			method:   http.MethodGet,
+			target:   "/v1/users?limit=ten",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Test if streams changes of users as NDJSON",
+			server:   &stubServer{users: []*pb.User{{Id: testId}}},
+			method:   http.MethodGet,
+			target:   "/v1/users:watch?user_ids=" + testId + "&types=UPDATED,DELETED&since=abc",
+			wantCode: http.StatusOK,
+			wantBody: `{"token":"token","change":{"type":"UPDATED","user":{"id":"` + testId + `"}}}` + "\n",
+			wantReq: &pb.WatchUsersRequest{
+				UserIds: []string{testId},
+				Types:   []pb.UserChange_Type{pb.UserChange_UPDATED, pb.UserChange_DELETED},
+				Since:   "abc",
+			},
+		},
+		{
+			name:     "Test if rejects invalid change types",
+			server:   &stubServer{},
+			method:   http.MethodGet,
+			target:   "/v1/users:watch?types=RENAMED",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Test if writes errors after the first user as the last line",
+			server:   &stubServer{users: []*pb.User{{Id: "1"}}, err: status.Error(codes.Internal, "test err")},
+			method:   http.MethodGet,
+			target:   "/v1/users",
+			wantCode: http.StatusOK,
+			wantBody: "{\"id\":\"1\"}\n{\"error\":{\"code\":13,\"message\":\"test err\"}}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+
+			setUpGateway(tt.server).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("Gateway.Handler() code = %d, want %d, body = %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+
+			if tt.wantBody != "" {
+				// protojson randomizes whitespace, so that its output is not relied upon.
+				got := strings.Join(strings.Fields(rec.Body.String()), "") + strings.Repeat("\n", strings.Count(rec.Body.String(), "\n"))
+				want := strings.Join(strings.Fields(tt.wantBody), "") + strings.Repeat("\n", strings.Count(tt.wantBody, "\n"))
+				if got != want {
+					t.Errorf("Gateway.Handler() body:\n got = %q\n want = %q", rec.Body.String(), tt.wantBody)
+				}
+			}
+
+			if tt.wantReq != nil && !proto.Equal(tt.server.got, tt.wantReq) {
+				t.Errorf("Gateway.Handler() request:\n got = %v\n want = %v", tt.server.got, tt.wantReq)
+			}
+		})
+	}
+}
+
+func TestGateway_Handler_nosniff(t *testing.T) {
+	tests := []struct {
+		name   string
+		server *stubServer
+		target string
+	}{
+		{
+			name:   "Test if sets the header on responses",
+			server: &stubServer{users: []*pb.User{{Id: testId}}},
+			target: "/v1/users/" + testId,
+		},
+		{
+			name:   "Test if sets the header on errors",
+			server: &stubServer{err: status.Error(codes.NotFound, "User not found")},
+			target: "/v1/users/" + testId,
+		},
+		{
+			name:   "Test if sets the header on streams",
+			server: &stubServer{users: []*pb.User{{Id: testId}}},
+			target: "/v1/users",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			setUpGateway(tt.server).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if got := rec.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+				t.Errorf("Gateway.Handler() X-Content-Type-Options = %q, want %q", got, "nosniff")
+			}
+		})
+	}
+}
+
+func TestGateway_Handler_update(t *testing.T) {
+	server := &stubServer{}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPatch, "/v1/users/"+testId+"?update_mask=name", strings.NewReader(`{"id": "other", "name": "krixlion", "email": "krixlion@example.com", "password": "12345678"}`))
+
+	setUpGateway(server).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Gateway.Handler() code = %d, want %d, body = %s", rec.Code, http.StatusNoContent, rec.Body.String())
+	}
+
+	got := server.got.(*pb.UpdateUserRequest)
+	if got.GetUser().GetId() != testId {
+		t.Errorf("Gateway.Handler() updated id = %q, want %q", got.GetUser().GetId(), testId)
+	}
+
+	if !cmp.Equal(got.GetFieldMask().GetPaths(), []string{"name"}) {
+		t.Errorf("Gateway.Handler() update mask = %v, want [name]", got.GetFieldMask().GetPaths())
+	}
+}
+
+func TestGateway_context(t *testing.T) {
+	server := &stubServer{}
+	req := httptest.NewRequest(http.MethodDelete, "/v1/users/"+testId, nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("Idempotency-Key", "key")
+	req.Header.Set("Authorization", "secret")
+	req.Header.Set("X-User-Id", "user-1")
+
+	var intercepted string
+	handler := New(Config{ForwardedHeaders: []string{"Idempotency-Key"}, VerifiedHeaders: []string{"X-User-Id"}}, Dependencies{
+		Server: server,
+		UnaryInterceptor: func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			intercepted = info.FullMethod
+			return handler(ctx, req)
+		},
+		Logger: nulls.NullLogger{},
+	}).Handler()
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if intercepted != pb.UserService_Delete_FullMethodName {
+		t.Errorf("Gateway.Handler() intercepted method = %q, want %q", intercepted, pb.UserService_Delete_FullMethodName)
+	}
+
+	p, ok := peer.FromContext(server.ctx)
+	if !ok || p.Addr.String() != req.RemoteAddr {
+		t.Errorf("Gateway.Handler() peer = %v, want %s", p, req.RemoteAddr)
+	}
+
+	md, _ := metadata.FromIncomingContext(server.ctx)
+	want := metadata.Pairs("idempotency-key", "key")
+	if !cmp.Equal(md, want) {
+		t.Errorf("Gateway.Handler() metadata = %v, want %v", md, want)
+	}
+}
+
+func TestGateway_context_verifiedHeaders(t *testing.T) {
+	server := &stubServer{}
+	req := httptest.NewRequest(http.MethodDelete, "/v1/users/"+testId, nil)
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+	req.Header.Set("Idempotency-Key", "key")
+	req.Header.Set("X-User-Id", "user-1")
+
+	handler := New(Config{ForwardedHeaders: []string{"Idempotency-Key"}, VerifiedHeaders: []string{"X-User-Id"}}, Dependencies{
+		Server: server,
+		Logger: nulls.NullLogger{},
+	}).Handler()
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	md, _ := metadata.FromIncomingContext(server.ctx)
+	want := metadata.Pairs("idempotency-key", "key", "x-user-id", "user-1")
+	if !cmp.Equal(md, want) {
+		t.Errorf("Gateway.Handler() metadata = %v, want %v", md, want)
+	}
+}
@@ -0,0 +1,72 @@
+package gateway
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// ndjsonStream is a server stream receiving the request once
+// and writing every sent message as a line of JSON.
+type ndjsonStream struct {
+	ctx      context.Context
+	w        http.ResponseWriter
+	req      proto.Message
+	received bool
+	// started is set once the response headers were written,
+	// after which errors can no longer change the status code.
+	started bool
+}
+
+var _ grpc.ServerStream = (*ndjsonStream)(nil)
+
+func (s *ndjsonStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *ndjsonStream) RecvMsg(m interface{}) error {
+	if s.received {
+		return io.EOF
+	}
+	s.received = true
+
+	proto.Merge(m.(proto.Message), s.req)
+	return nil
+}
+
+func (s *ndjsonStream) SendMsg(m interface{}) error {
+	body, err := marshal(m.(proto.Message))
+	if err != nil {
+		return err
+	}
+
+	if !s.started {
+		s.start()
+	}
+
+	if _, err := s.w.Write(append(body, '\n')); err != nil {
+		return err
+	}
+
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
+	return nil
+}
+
+func (s *ndjsonStream) start() {
+	s.w.Header().Set("Content-Type", "application/x-ndjson")
+	s.w.Header().Set("X-Content-Type-Options", "nosniff")
+	s.w.WriteHeader(http.StatusOK)
+	s.started = true
+}
+
+// Headers and trailers have no HTTP equivalent.
+func (s *ndjsonStream) SetHeader(metadata.MD) error  { return nil }
+func (s *ndjsonStream) SendHeader(metadata.MD) error { return nil }
+func (s *ndjsonStream) SetTrailer(metadata.MD)       {}
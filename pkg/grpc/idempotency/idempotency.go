@@ -0,0 +1,224 @@
+// Package idempotency lets clients safely retry mutating RPCs
+// by sending an idempotency key in the request metadata.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/logging"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	"github.com/krixlion/dev_forum-user/pkg/grpc/ratelimit"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// MetadataKey is the gRPC metadata header holding the idempotency key.
+const MetadataKey = "idempotency-key"
+
+// MaxKeyLength limits the length of accepted idempotency keys.
+const MaxKeyLength = 255
+
+type Config struct {
+	// Methods are full names of the RPCs accepting idempotency keys.
+	Methods []string
+	// KeyTTL is how long responses are replayed for.
+	KeyTTL time.Duration
+	// PendingTTL is how long a key stays reserved by a request which is still being handled,
+	// eg. in case the replica handling it is killed. It should exceed the duration of the RPCs.
+	PendingTTL time.Duration
+	// Caller identifies clients, so that keys sent by different clients never collide.
+	// Defaults to ratelimit.IdentityKey.
+	Caller ratelimit.KeyFunc
+}
+
+type Interceptor struct {
+	config  Config
+	methods map[string]bool
+	storage storage.IdempotencyStorage
+	logger  logging.Logger
+	tracer  trace.Tracer
+}
+
+func New(config Config, storage storage.IdempotencyStorage, logger logging.Logger, tracer trace.Tracer) *Interceptor {
+	if config.Caller == nil {
+		config.Caller = ratelimit.IdentityKey
+	}
+
+	methods := make(map[string]bool, len(config.Methods))
+	for _, method := range config.Methods {
+		methods[method] = true
+	}
+
+	return &Interceptor{
+		config:  config,
+		methods: methods,
+		storage: storage,
+		logger:  logger,
+		tracer:  tracer,
+	}
+}
+
+// UnaryServerInterceptor stores successful responses under the idempotency key sent with the request
+// and replays them for retries with the same key and payload.
+// Retries with a different payload fail with FailedPrecondition and those sent while the first request
+// is still being handled fail with Aborted. Failed requests are not stored, so they can be retried.
+// Requests without the key are handled as usual.
+//
+// It has to run before interceptors modifying requests, so that retries have the same fingerprints.
+func (i *Interceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !i.methods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		key, err := keyFromContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if key == "" {
+			return handler(ctx, req)
+		}
+
+		return i.handle(ctx, info.FullMethod, key, req, handler)
+	}
+}
+
+func (i *Interceptor) handle(ctx context.Context, method, key string, req interface{}, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, span := i.tracer.Start(ctx, "idempotency.handle")
+	defer span.End()
+
+	fingerprint, err := fingerprint(method, req)
+	if err != nil {
+		tracing.SetSpanErr(span, err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// Keys are scoped to callers, so that clients cannot replay responses to others,
+	// and to methods, so that the same key can be reused for different operations.
+	key = i.config.Caller(ctx) + ":" + method + ":" + key
+
+	record, err := i.storage.ReserveIdempotencyKey(ctx, key, fingerprint, i.config.PendingTTL)
+	if errors.Is(err, storage.ErrIdempotencyKeyExists) {
+		return replay(record, fingerprint)
+	}
+	if err != nil {
+		tracing.SetSpanErr(span, err)
+		return nil, status.Errorf(codes.Internal, "Failed to reserve idempotency key: %v", err)
+	}
+
+	resp, err := handler(ctx, req)
+	if err != nil {
+		i.release(ctx, key)
+		return resp, err
+	}
+
+	response, err := marshalResponse(resp)
+	if err != nil {
+		i.logger.Log(ctx, "Failed to marshal response for idempotency key", "key", key, "err", err)
+		i.release(ctx, key)
+		return resp, nil
+	}
+
+	// The request already succeeded, so it's only logged if the response could not be stored.
+	if err := i.storage.SaveIdempotencyResponse(context.WithoutCancel(ctx), key, response, i.config.KeyTTL); err != nil {
+		tracing.SetSpanErr(span, err)
+		i.logger.Log(ctx, "Failed to save response for idempotency key", "key", key, "err", err)
+	}
+
+	return resp, nil
+}
+
+// release lets the request be retried under the same key.
+func (i *Interceptor) release(ctx context.Context, key string) {
+	if err := i.storage.ReleaseIdempotencyKey(context.WithoutCancel(ctx), key); err != nil {
+		i.logger.Log(ctx, "Failed to release idempotency key", "key", key, "err", err)
+	}
+}
+
+func replay(record storage.IdempotencyRecord, fingerprint []byte) (interface{}, error) {
+	if string(record.Fingerprint) != string(fingerprint) {
+		return nil, status.Error(codes.FailedPrecondition, "Idempotency key was already used for a different request")
+	}
+
+	if record.Response == nil {
+		return nil, status.Error(codes.Aborted, "Request with the same idempotency key is still being processed")
+	}
+
+	resp, err := unmarshalResponse(record.Response)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to replay response: %v", err)
+	}
+
+	return resp, nil
+}
+
+// keyFromContext returns the idempotency key from the incoming metadata or an empty string if none was sent.
+func keyFromContext(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	values := md.Get(MetadataKey)
+	switch {
+	case len(values) == 0:
+		return "", nil
+	case len(values) > 1:
+		return "", status.Errorf(codes.InvalidArgument, "Only one %s may be provided", MetadataKey)
+	case values[0] == "" || len(values[0]) > MaxKeyLength:
+		return "", status.Errorf(codes.InvalidArgument, "%s must be between 1 and %d characters long", MetadataKey, MaxKeyLength)
+	}
+
+	return values[0], nil
+}
+
+// fingerprint returns a hash of the method and the request's payload.
+func fingerprint(method string, req interface{}) ([]byte, error) {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("request of type %T is not a proto message", req)
+	}
+
+	payload, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(method))
+	hash.Write([]byte{0})
+	hash.Write(payload)
+	return hash.Sum(nil), nil
+}
+
+// marshalResponse encodes the response along with its type, so that it can be replayed without knowing it.
+func marshalResponse(resp interface{}) ([]byte, error) {
+	msg, ok := resp.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("response of type %T is not a proto message", resp)
+	}
+
+	wrapped, err := anypb.New(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(wrapped)
+}
+
+func unmarshalResponse(response []byte) (proto.Message, error) {
+	wrapped := &anypb.Any{}
+	if err := proto.Unmarshal(response, wrapped); err != nil {
+		return nil, err
+	}
+
+	return wrapped.UnmarshalNew()
+}
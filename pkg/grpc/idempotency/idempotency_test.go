@@ -0,0 +1,307 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/nulls"
+	"github.com/krixlion/dev_forum-user/pkg/grpc/ratelimit"
+	pb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+	"github.com/krixlion/dev_forum-user/pkg/storage/storagemocks"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestInterceptor_UnaryServerInterceptor(t *testing.T) {
+	const method = "/user.UserService/Create"
+	// Requests without a peer are identified as an unknown peer.
+	const key = "peer:unknown:" + method + ":key"
+
+	req := &pb.CreateUserRequest{User: &pb.User{Name: "name", Email: "name@test.test", Password: "password"}}
+	resp := &pb.CreateUserResponse{Id: "id"}
+
+	reqFingerprint, err := fingerprint(method, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storedResp, err := marshalResponse(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withKey := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "key"))
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		method  string
+		storage func() storagemocks.IdempotencyStorage
+		// handlerErr is returned by the handler if it's called.
+		handlerErr  error
+		wantHandled bool
+		want        *pb.CreateUserResponse
+		wantCode    codes.Code
+	}{
+		{
+			name:   "Test if handles requests without the key as usual",
+			ctx:    context.Background(),
+			method: method,
+			storage: func() storagemocks.IdempotencyStorage {
+				return storagemocks.NewIdempotencyStorage()
+			},
+			wantHandled: true,
+			want:        resp,
+		},
+		{
+			name:   "Test if ignores methods not configured",
+			ctx:    withKey,
+			method: "/user.UserService/Get",
+			storage: func() storagemocks.IdempotencyStorage {
+				return storagemocks.NewIdempotencyStorage()
+			},
+			wantHandled: true,
+			want:        resp,
+		},
+		{
+			name:   "Test if stores the response of new requests",
+			ctx:    withKey,
+			method: method,
+			storage: func() storagemocks.IdempotencyStorage {
+				m := storagemocks.NewIdempotencyStorage()
+				m.On("ReserveIdempotencyKey", mock.Anything, key, reqFingerprint, time.Minute).Return(storage.IdempotencyRecord{Fingerprint: reqFingerprint}, nil).Once()
+				m.On("SaveIdempotencyResponse", mock.Anything, key, storedResp, time.Hour).Return(nil).Once()
+				return m
+			},
+			wantHandled: true,
+			want:        resp,
+		},
+		{
+			name:   "Test if releases the key on failure",
+			ctx:    withKey,
+			method: method,
+			storage: func() storagemocks.IdempotencyStorage {
+				m := storagemocks.NewIdempotencyStorage()
+				m.On("ReserveIdempotencyKey", mock.Anything, key, reqFingerprint, time.Minute).Return(storage.IdempotencyRecord{Fingerprint: reqFingerprint}, nil).Once()
+				m.On("ReleaseIdempotencyKey", mock.Anything, key).Return(nil).Once()
+				return m
+			},
+			handlerErr:  status.Error(codes.Internal, "test err"),
+			wantHandled: true,
+			wantCode:    codes.Internal,
+		},
+		{
+			name:   "Test if replays stored responses",
+			ctx:    withKey,
+			method: method,
+			storage: func() storagemocks.IdempotencyStorage {
+				m := storagemocks.NewIdempotencyStorage()
+				m.On("ReserveIdempotencyKey", mock.Anything, key, reqFingerprint, time.Minute).Return(storage.IdempotencyRecord{Fingerprint: reqFingerprint, Response: storedResp}, storage.ErrIdempotencyKeyExists).Once()
+				return m
+			},
+			want: resp,
+		},
+		{
+			name:   "Test if fails on a different payload under the same key",
+			ctx:    withKey,
+			method: method,
+			storage: func() storagemocks.IdempotencyStorage {
+				m := storagemocks.NewIdempotencyStorage()
+				m.On("ReserveIdempotencyKey", mock.Anything, key, reqFingerprint, time.Minute).Return(storage.IdempotencyRecord{Fingerprint: []byte("other"), Response: storedResp}, storage.ErrIdempotencyKeyExists).Once()
+				return m
+			},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name:   "Test if aborts while the first request is still being handled",
+			ctx:    withKey,
+			method: method,
+			storage: func() storagemocks.IdempotencyStorage {
+				m := storagemocks.NewIdempotencyStorage()
+				m.On("ReserveIdempotencyKey", mock.Anything, key, reqFingerprint, time.Minute).Return(storage.IdempotencyRecord{Fingerprint: reqFingerprint}, storage.ErrIdempotencyKeyExists).Once()
+				return m
+			},
+			wantCode: codes.Aborted,
+		},
+		{
+			name:   "Test if fails on storage errors",
+			ctx:    withKey,
+			method: method,
+			storage: func() storagemocks.IdempotencyStorage {
+				m := storagemocks.NewIdempotencyStorage()
+				m.On("ReserveIdempotencyKey", mock.Anything, key, reqFingerprint, time.Minute).Return(storage.IdempotencyRecord{}, errors.New("test err")).Once()
+				return m
+			},
+			wantCode: codes.Internal,
+		},
+		{
+			name:   "Test if fails on too long keys",
+			ctx:    metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, string(make([]byte, MaxKeyLength+1)))),
+			method: method,
+			storage: func() storagemocks.IdempotencyStorage {
+				return storagemocks.NewIdempotencyStorage()
+			},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := tt.storage()
+
+			handled := false
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				handled = true
+				if tt.handlerErr != nil {
+					return nil, tt.handlerErr
+				}
+				return resp, nil
+			}
+
+			i := New(Config{
+				Methods:    []string{method},
+				KeyTTL:     time.Hour,
+				PendingTTL: time.Minute,
+			}, storage, nulls.NullLogger{}, nulls.NullTracer{})
+
+			got, err := i.UnaryServerInterceptor()(tt.ctx, req, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("Interceptor.UnaryServerInterceptor() code = %v, want %v, err = %v", code, tt.wantCode, err)
+				return
+			}
+
+			if tt.wantCode == codes.OK && !proto.Equal(got.(proto.Message), tt.want) {
+				t.Errorf("Interceptor.UnaryServerInterceptor():\n got = %v\n want = %v", got, tt.want)
+			}
+
+			if handled != tt.wantHandled {
+				t.Errorf("Interceptor.UnaryServerInterceptor() handled = %v, want %v", handled, tt.wantHandled)
+			}
+
+			storage.AssertExpectations(t)
+		})
+	}
+}
+
+func TestInterceptor_UnaryServerInterceptor_callers(t *testing.T) {
+	const method = "/user.UserService/Create"
+	req := &pb.CreateUserRequest{User: &pb.User{Name: "name", Email: "name@test.test", Password: "password"}}
+
+	reqFingerprint, err := fingerprint(method, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := storagemocks.NewIdempotencyStorage()
+	for _, caller := range []string{"10.0.0.1", "10.0.0.2"} {
+		key := "peer:" + caller + ":" + method + ":key"
+		db.On("ReserveIdempotencyKey", mock.Anything, key, reqFingerprint, time.Minute).Return(storage.IdempotencyRecord{Fingerprint: reqFingerprint}, nil).Once()
+		db.On("SaveIdempotencyResponse", mock.Anything, key, mock.Anything, time.Hour).Return(nil).Once()
+	}
+
+	i := New(Config{
+		Methods:    []string{method},
+		KeyTTL:     time.Hour,
+		PendingTTL: time.Minute,
+	}, db, nulls.NullLogger{}, nulls.NullTracer{})
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &pb.CreateUserResponse{Id: "id"}, nil
+	}
+
+	for _, caller := range []string{"10.0.0.1", "10.0.0.2"} {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "key"))
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(caller), Port: 5000}})
+
+		if _, err := i.UnaryServerInterceptor()(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, handler); err != nil {
+			t.Errorf("Interceptor.UnaryServerInterceptor() error = %v", err)
+		}
+	}
+
+	db.AssertExpectations(t)
+}
+
+func TestInterceptor_UnaryServerInterceptor_gatewayUsers(t *testing.T) {
+	const method = "/user.UserService/Create"
+	req := &pb.CreateUserRequest{User: &pb.User{Name: "name", Email: "name@test.test", Password: "password"}}
+
+	reqFingerprint, err := fingerprint(method, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := storagemocks.NewIdempotencyStorage()
+	for _, user := range []string{"1", "2"} {
+		key := "user:" + user + ":" + method + ":key"
+		db.On("ReserveIdempotencyKey", mock.Anything, key, reqFingerprint, time.Minute).Return(storage.IdempotencyRecord{Fingerprint: reqFingerprint}, nil).Once()
+		db.On("SaveIdempotencyResponse", mock.Anything, key, mock.Anything, time.Hour).Return(nil).Once()
+	}
+
+	i := New(Config{
+		Methods:    []string{method},
+		KeyTTL:     time.Hour,
+		PendingTTL: time.Minute,
+		Caller:     ratelimit.UserKey("x-user-id", "gateway"),
+	}, db, nulls.NullLogger{}, nulls.NullTracer{})
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &pb.CreateUserResponse{Id: "id"}, nil
+	}
+
+	// Both users reach the service through the same gateway.
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "gateway"}}
+	gateway := &peer.Peer{
+		Addr:     &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000},
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}},
+	}
+
+	for _, user := range []string{"1", "2"} {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "key", "x-user-id", user))
+		ctx = peer.NewContext(ctx, gateway)
+
+		if _, err := i.UnaryServerInterceptor()(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, handler); err != nil {
+			t.Errorf("Interceptor.UnaryServerInterceptor() error = %v", err)
+		}
+	}
+
+	db.AssertExpectations(t)
+}
+
+func Test_fingerprint(t *testing.T) {
+	req := &pb.CreateUserRequest{User: &pb.User{Name: "name", Email: "name@test.test"}}
+
+	got, err := fingerprint("/user.UserService/Create", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	same, err := fingerprint("/user.UserService/Create", proto.Clone(req))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != string(same) {
+		t.Errorf("fingerprint() differs for equal requests")
+	}
+
+	other, err := fingerprint("/user.UserService/Create", &pb.CreateUserRequest{User: &pb.User{Name: "other", Email: "name@test.test"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) == string(other) {
+		t.Errorf("fingerprint() is equal for different requests")
+	}
+}
@@ -50,3 +50,28 @@ func (m UserClient) GetStream(ctx context.Context, in *pb.GetUsersRequest, opts
 	args := m.Called(ctx, in, opts)
 	return args.Get(0).(pb.UserService_GetStreamClient), args.Error(1)
 }
+
+func (m UserClient) SearchUsers(ctx context.Context, in *pb.SearchUsersRequest, opts ...grpc.CallOption) (*pb.SearchUsersResponse, error) {
+	args := m.Called(ctx, in, opts)
+	return args.Get(0).(*pb.SearchUsersResponse), args.Error(1)
+}
+
+func (m UserClient) WatchUsers(ctx context.Context, in *pb.WatchUsersRequest, opts ...grpc.CallOption) (pb.UserService_WatchUsersClient, error) {
+	args := m.Called(ctx, in, opts)
+	return args.Get(0).(pb.UserService_WatchUsersClient), args.Error(1)
+}
+
+func (m UserClient) ListAuditEntries(ctx context.Context, in *pb.ListAuditEntriesRequest, opts ...grpc.CallOption) (*pb.ListAuditEntriesResponse, error) {
+	args := m.Called(ctx, in, opts)
+	return args.Get(0).(*pb.ListAuditEntriesResponse), args.Error(1)
+}
+
+func (m UserClient) ExportUserData(ctx context.Context, in *pb.ExportUserDataRequest, opts ...grpc.CallOption) (*pb.ExportUserDataResponse, error) {
+	args := m.Called(ctx, in, opts)
+	return args.Get(0).(*pb.ExportUserDataResponse), args.Error(1)
+}
+
+func (m UserClient) EraseUser(ctx context.Context, in *pb.EraseUserRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	args := m.Called(ctx, in, opts)
+	return args.Get(0).(*emptypb.Empty), args.Error(1)
+}
@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limit allows Requests per Interval on average with bursts of up to Burst requests.
+type Limit struct {
+	Requests int
+	Interval time.Duration
+	Burst    int
+}
+
+// IsZero reports whether the limit is unset, which means unlimited.
+func (l Limit) IsZero() bool {
+	return l.Requests == 0
+}
+
+// Limiter keeps a token bucket for every key.
+// The in-memory MemoryLimiter limits every replica separately,
+// implementations backed by a shared store can limit them together.
+type Limiter interface {
+	// Allow takes a token from the key's bucket. If none is left it returns false
+	// along with the time after which the next token is available.
+	Allow(ctx context.Context, key string, limit Limit) (bool, time.Duration, error)
+}
+
+// sweepInterval is how often buckets which are full again are dropped.
+const sweepInterval = time.Minute
+
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// refill adds tokens accumulated since the last update.
+func (b *bucket) refill(now time.Time) {
+	perToken := b.limit.Interval / time.Duration(b.limit.Requests)
+	b.tokens += float64(now.Sub(b.updated)) / float64(perToken)
+	if b.tokens > float64(b.limit.Burst) {
+		b.tokens = float64(b.limit.Burst)
+	}
+	b.updated = now
+}
+
+// Allow implements Limiter. It never returns an error.
+func (l *MemoryLimiter) Allow(_ context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok || b.limit != limit {
+		b = &bucket{tokens: float64(limit.Burst), updated: now, limit: limit}
+		l.buckets[key] = b
+	}
+
+	b.refill(now)
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+
+	perToken := limit.Interval / time.Duration(limit.Requests)
+	wait := time.Duration((1 - b.tokens) * float64(perToken))
+	return false, wait, nil
+}
+
+// sweep drops buckets which are full again, since they are equal to new ones.
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Package ratelimit throttles clients sending too many requests
+// using token buckets kept per client and method.
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/logging"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+type Config struct {
+	// Default applies to methods without a limit of their own.
+	// A zero limit means unlimited.
+	Default Limit
+	// Methods holds limits keyed by full method names, eg. "/user.UserService/Create".
+	Methods map[string]Limit
+}
+
+// KeyFunc identifies the client sending the request.
+type KeyFunc func(ctx context.Context) string
+
+type Interceptor struct {
+	config  Config
+	limiter Limiter
+	key     KeyFunc
+	logger  logging.Logger
+}
+
+func New(config Config, limiter Limiter, key KeyFunc, logger logging.Logger) *Interceptor {
+	return &Interceptor{
+		config:  config,
+		limiter: limiter,
+		key:     key,
+		logger:  logger,
+	}
+}
+
+// UnaryServerInterceptor rejects requests of clients which exceeded the method's limit
+// with ResourceExhausted and RetryInfo details holding the time after which they may retry.
+func (i *Interceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := i.limit(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor works just like UnaryServerInterceptor,
+// taking a token when a stream is opened.
+func (i *Interceptor) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := i.limit(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func (i *Interceptor) limit(ctx context.Context, method string) error {
+	limit, ok := i.config.Methods[method]
+	if !ok {
+		limit = i.config.Default
+	}
+
+	if limit.IsZero() {
+		return nil
+	}
+
+	client := i.key(ctx)
+
+	allowed, wait, err := i.limiter.Allow(ctx, method+"|"+client, limit)
+	if err != nil {
+		// Let requests through rather than fail them all if the limiter is unavailable.
+		i.logger.Log(ctx, "Failed to check rate limit", "method", method, "client", client, "err", err)
+		return nil
+	}
+
+	if allowed {
+		return nil
+	}
+
+	return throttled(wait)
+}
+
+func throttled(wait time.Duration) error {
+	st := status.New(codes.ResourceExhausted, "Too many requests, retry later")
+
+	detailed, err := st.WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(wait),
+	})
+	if err != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
+
+// PeerKey identifies clients by the IP address they connect from.
+func PeerKey(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "peer:unknown"
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return "peer:" + p.Addr.String()
+	}
+
+	return "peer:" + host
+}
+
+// IdentityKey identifies clients by the common name or the first DNS name
+// of their verified mTLS certificate, falling back to PeerKey.
+func IdentityKey(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return PeerKey(ctx)
+	}
+
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) < 1 || len(tlsInfo.State.VerifiedChains[0]) < 1 {
+		return PeerKey(ctx)
+	}
+
+	cert := tlsInfo.State.VerifiedChains[0][0]
+	switch {
+	case cert.Subject.CommonName != "":
+		return "identity:" + cert.Subject.CommonName
+	case len(cert.DNSNames) > 0:
+		return "identity:" + cert.DNSNames[0]
+	default:
+		return PeerKey(ctx)
+	}
+}
+
+// UserKey identifies clients by the authenticated user's id passed in the given metadata header
+// by the gateway in front of the service, falling back to IdentityKey.
+// The header is trusted only from the gateway, ie. a client whose verified mTLS certificate
+// is named trustedGateway, since any other client could set it to impersonate users.
+func UserKey(header, trustedGateway string) KeyFunc {
+	return func(ctx context.Context) string {
+		identity := IdentityKey(ctx)
+		if identity != "identity:"+trustedGateway {
+			return identity
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		if values := md.Get(header); len(values) > 0 && values[0] != "" {
+			return "user:" + values[0]
+		}
+		return identity
+	}
+}
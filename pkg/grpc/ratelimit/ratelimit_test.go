@@ -0,0 +1,210 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/nulls"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestMemoryLimiter_Allow(t *testing.T) {
+	now := time.Now()
+	l := NewMemoryLimiter()
+	l.now = func() time.Time { return now }
+
+	limit := Limit{Requests: 2, Interval: time.Second, Burst: 2}
+
+	for n := 0; n < 2; n++ {
+		if allowed, _, _ := l.Allow(context.Background(), "key", limit); !allowed {
+			t.Fatalf("MemoryLimiter.Allow() rejected request %d within the burst", n+1)
+		}
+	}
+
+	allowed, wait, _ := l.Allow(context.Background(), "key", limit)
+	if allowed {
+		t.Fatalf("MemoryLimiter.Allow() allowed a request exceeding the burst")
+	}
+
+	if want := time.Millisecond * 500; wait != want {
+		t.Errorf("MemoryLimiter.Allow() wait:\n got = %v\n want = %v", wait, want)
+	}
+
+	if allowed, _, _ := l.Allow(context.Background(), "other", limit); !allowed {
+		t.Errorf("MemoryLimiter.Allow() limited a different key")
+	}
+
+	now = now.Add(wait)
+	if allowed, _, _ := l.Allow(context.Background(), "key", limit); !allowed {
+		t.Errorf("MemoryLimiter.Allow() did not refill the bucket")
+	}
+
+	now = now.Add(sweepInterval)
+	l.Allow(context.Background(), "new", limit)
+	if _, ok := l.buckets["key"]; ok {
+		t.Errorf("MemoryLimiter.Allow() did not sweep full buckets")
+	}
+}
+
+type fakeLimiter struct {
+	allowed bool
+	wait    time.Duration
+	err     error
+	keys    []string
+}
+
+func (l *fakeLimiter) Allow(_ context.Context, key string, _ Limit) (bool, time.Duration, error) {
+	l.keys = append(l.keys, key)
+	return l.allowed, l.wait, l.err
+}
+
+func TestInterceptor_UnaryServerInterceptor(t *testing.T) {
+	const create = "/user.UserService/Create"
+
+	config := Config{
+		Methods: map[string]Limit{
+			create: {Requests: 1, Interval: time.Second, Burst: 1},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		limiter     *fakeLimiter
+		wantCode    codes.Code
+		wantDelay   time.Duration
+		wantLimited bool
+	}{
+		{
+			name:        "Test if lets allowed requests through",
+			method:      create,
+			limiter:     &fakeLimiter{allowed: true},
+			wantLimited: true,
+		},
+		{
+			name:        "Test if rejects throttled requests with retry info",
+			method:      create,
+			limiter:     &fakeLimiter{wait: time.Second},
+			wantCode:    codes.ResourceExhausted,
+			wantDelay:   time.Second,
+			wantLimited: true,
+		},
+		{
+			name:    "Test if does not limit methods without limits",
+			method:  "/user.UserService/Get",
+			limiter: &fakeLimiter{},
+		},
+		{
+			name:        "Test if lets requests through if the limiter fails",
+			method:      create,
+			limiter:     &fakeLimiter{err: errors.New("test err")},
+			wantLimited: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := New(config, tt.limiter, func(context.Context) string { return "client" }, nulls.NullLogger{})
+
+			handled := false
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				handled = true
+				return nil, nil
+			}
+
+			_, err := i.UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			st := status.Convert(err)
+			if st.Code() != tt.wantCode {
+				t.Fatalf("Interceptor.UnaryServerInterceptor() code = %v, want %v", st.Code(), tt.wantCode)
+			}
+
+			if handled != (tt.wantCode == codes.OK) {
+				t.Errorf("Interceptor.UnaryServerInterceptor() handled = %v", handled)
+			}
+
+			if limited := len(tt.limiter.keys) > 0; limited != tt.wantLimited {
+				t.Errorf("Interceptor.UnaryServerInterceptor() limited = %v, want %v", limited, tt.wantLimited)
+			}
+
+			if tt.wantCode != codes.ResourceExhausted {
+				return
+			}
+
+			if len(st.Details()) != 1 {
+				t.Fatalf("Interceptor.UnaryServerInterceptor() details = %v, want RetryInfo", st.Details())
+			}
+
+			info, ok := st.Details()[0].(*errdetails.RetryInfo)
+			if !ok || info.GetRetryDelay().AsDuration() != tt.wantDelay {
+				t.Errorf("Interceptor.UnaryServerInterceptor() retry info:\n got = %v\n want = %v", st.Details()[0], tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestUserKey(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+	userMD := metadata.Pairs("x-user-id", "user-1")
+
+	verified := func(name string) context.Context {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: name}}
+		authInfo := credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}}
+		return peer.NewContext(context.Background(), &peer.Peer{Addr: addr, AuthInfo: authInfo})
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{
+			name: "Test if identifies users by the header of the trusted gateway",
+			ctx:  metadata.NewIncomingContext(verified("gateway"), userMD),
+			want: "user:user-1",
+		},
+		{
+			name: "Test if ignores the header of other verified clients",
+			ctx:  metadata.NewIncomingContext(verified("other"), userMD),
+			want: "identity:other",
+		},
+		{
+			name: "Test if ignores the header of unverified clients",
+			ctx:  metadata.NewIncomingContext(ctx, userMD),
+			want: "peer:10.0.0.1",
+		},
+		{
+			name: "Test if falls back to the identity of the gateway without the header",
+			ctx:  verified("gateway"),
+			want: "identity:gateway",
+		},
+		{
+			name: "Test if falls back to the peer address without the port",
+			ctx:  ctx,
+			want: "peer:10.0.0.1",
+		},
+		{
+			name: "Test if handles unknown peers",
+			ctx:  context.Background(),
+			want: "peer:unknown",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UserKey("x-user-id", "gateway")(tt.ctx); got != tt.want {
+				t.Errorf("UserKey():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+
+	"github.com/krixlion/dev_forum-user/pkg/domain"
+	"github.com/krixlion/dev_forum-user/pkg/entity"
+	pb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// auditInfo identifies the caller with the configured Actor func
+// and the request with the RequestIdMetadataKey header or the trace id.
+func (s UserServer) auditInfo(ctx context.Context) domain.AuditInfo {
+	info := domain.AuditInfo{
+		Actor: s.config.Actor(ctx),
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(RequestIdMetadataKey); len(values) > 0 && values[0] != "" {
+		info.RequestId = values[0]
+	} else if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		info.RequestId = spanContext.TraceID().String()
+	}
+
+	return info
+}
+
+func auditEntryToPB(v entity.AuditEntry) *pb.AuditEntry {
+	changes := make([]*pb.FieldChange, 0, len(v.Changes))
+	for _, change := range v.Changes {
+		changes = append(changes, &pb.FieldChange{
+			Field:    change.Field,
+			OldValue: change.Old,
+			NewValue: change.New,
+		})
+	}
+
+	return &pb.AuditEntry{
+		Id:         v.Id,
+		UserId:     v.UserId,
+		Actor:      v.Actor,
+		Action:     string(v.Action),
+		Changes:    changes,
+		RequestId:  v.RequestId,
+		CreateTime: timestamppb.New(v.CreatedAt),
+	}
+}
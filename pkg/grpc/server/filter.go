@@ -0,0 +1,169 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/filter"
+	pb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+)
+
+const (
+	maxFilterDepth      = 5
+	maxFilterConditions = 50
+)
+
+// filterable reports whether clients can filter on the field.
+// Only public fields are, otherwise filters could be used to guess values of private ones.
+func filterable(field string) bool {
+	return storage.VerifyField(field, storage.Filterable|storage.Public) == nil
+}
+
+// filterFromRequest returns a filter built from either the structured or the string filter.
+func filterFromRequest(req *pb.GetUsersRequest) (storage.FilterGroup, error) {
+	if req.GetStructuredFilter() != nil {
+		if req.GetFilter() != "" {
+			return storage.FilterGroup{}, errors.New("filter and structured_filter are mutually exclusive")
+		}
+
+		conditions := 0
+		return filterFromPB(req.GetStructuredFilter(), 1, &conditions)
+	}
+
+	params, err := storage.ParseFilter(req.GetFilter())
+	if err != nil {
+		return storage.FilterGroup{}, err
+	}
+
+	for _, param := range params {
+		if !filterable(param.Attribute) {
+			return storage.FilterGroup{}, fmt.Errorf("field %q cannot be filtered on", param.Attribute)
+		}
+	}
+
+	return storage.FilterGroup{Params: params}, nil
+}
+
+// filterFromPB converts pb.Filter into storage.FilterGroup.
+// Depth and conditions are used to limit the size of the filter.
+func filterFromPB(v *pb.Filter, depth int, conditions *int) (storage.FilterGroup, error) {
+	if depth > maxFilterDepth {
+		return storage.FilterGroup{}, fmt.Errorf("filter groups cannot be nested deeper than %d levels", maxFilterDepth)
+	}
+
+	*conditions += len(v.GetConditions())
+	if *conditions > maxFilterConditions {
+		return storage.FilterGroup{}, fmt.Errorf("filter cannot contain more than %d conditions", maxFilterConditions)
+	}
+
+	group := storage.FilterGroup{
+		Params: make(filter.Filter, 0, len(v.GetConditions())),
+		Groups: make([]storage.FilterGroup, 0, len(v.GetGroups())),
+	}
+
+	switch v.GetLogic() {
+	case pb.Filter_AND:
+		group.Logic = storage.And
+	case pb.Filter_OR:
+		group.Logic = storage.Or
+	default:
+		return storage.FilterGroup{}, fmt.Errorf("invalid logic %q", v.GetLogic())
+	}
+
+	for _, condition := range v.GetConditions() {
+		param, err := paramFromPB(condition)
+		if err != nil {
+			return storage.FilterGroup{}, err
+		}
+		group.Params = append(group.Params, param)
+	}
+
+	for _, subgroup := range v.GetGroups() {
+		g, err := filterFromPB(subgroup, depth+1, conditions)
+		if err != nil {
+			return storage.FilterGroup{}, err
+		}
+		group.Groups = append(group.Groups, g)
+	}
+
+	return group, nil
+}
+
+// paramFromPB converts pb.Condition into filter.Parameter.
+func paramFromPB(v *pb.Condition) (filter.Parameter, error) {
+	if !filterable(v.GetField()) {
+		return filter.Parameter{}, fmt.Errorf("field %q cannot be filtered on", v.GetField())
+	}
+
+	operator, err := operatorFromPB(v.GetOperator())
+	if err != nil {
+		return filter.Parameter{}, err
+	}
+
+	var value string
+	switch v.GetValue().(type) {
+	case *pb.Condition_StringValue:
+		value = v.GetStringValue()
+	case *pb.Condition_TimestampValue:
+		value = v.GetTimestampValue().AsTime().Format(time.RFC3339Nano)
+	case *pb.Condition_ListValue:
+		if operator != storage.In {
+			return filter.Parameter{}, fmt.Errorf("list value can only be used with %s operator", pb.Condition_IN)
+		}
+
+		values := v.GetListValue().GetValues()
+		if len(values) == 0 {
+			return filter.Parameter{}, errors.New("list value cannot be empty")
+		}
+
+		for _, value := range values {
+			if strings.Contains(value, storage.InValueSeparator) {
+				return filter.Parameter{}, fmt.Errorf("list values cannot contain %q", storage.InValueSeparator)
+			}
+		}
+		value = strings.Join(values, storage.InValueSeparator)
+	default:
+		return filter.Parameter{}, fmt.Errorf("value for field %q not provided", v.GetField())
+	}
+
+	if operator == storage.In && v.GetListValue() == nil {
+		return filter.Parameter{}, fmt.Errorf("%s operator requires a list value", pb.Condition_IN)
+	}
+
+	return filter.Parameter{
+		Attribute: v.GetField(),
+		Operator:  operator,
+		Value:     value,
+	}, nil
+}
+
+// operatorFromPB returns a filter.Operator corresponding to the pb.Condition_Operator.
+func operatorFromPB(v pb.Condition_Operator) (filter.Operator, error) {
+	switch v {
+	case pb.Condition_EQUAL:
+		return filter.Equal, nil
+	case pb.Condition_NOT_EQUAL:
+		return filter.NotEqual, nil
+	case pb.Condition_GREATER_THAN:
+		return filter.GreaterThan, nil
+	case pb.Condition_GREATER_THAN_OR_EQUAL:
+		return filter.GreaterThanOrEqual, nil
+	case pb.Condition_LESSER_THAN:
+		return filter.LesserThan, nil
+	case pb.Condition_LESSER_THAN_OR_EQUAL:
+		return filter.LesserThanOrEqual, nil
+	case pb.Condition_LIKE:
+		return storage.Like, nil
+	case pb.Condition_ILIKE:
+		return storage.ILike, nil
+	case pb.Condition_PREFIX:
+		return storage.Prefix, nil
+	case pb.Condition_IN:
+		return storage.In, nil
+	default:
+		return "", fmt.Errorf("invalid operator %q", v)
+	}
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/krixlion/dev_forum-lib/filter"
+	pb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func Test_filterFromRequest(t *testing.T) {
+	timestamp := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		req     *pb.GetUsersRequest
+		want    storage.FilterGroup
+		wantErr bool
+	}{
+		{
+			name: "Test if returns empty filter when none is provided",
+			req:  &pb.GetUsersRequest{},
+			want: storage.FilterGroup{},
+		},
+		{
+			name: "Test if parses string filter",
+			req: &pb.GetUsersRequest{
+				Filter: "name[$prefix]=kri&id[$in]=1,2",
+			},
+			want: storage.FilterGroup{
+				Params: filter.Filter{
+					{Attribute: "name", Operator: storage.Prefix, Value: "kri"},
+					{Attribute: "id", Operator: storage.In, Value: "1,2"},
+				},
+			},
+		},
+		{
+			name: "Test if fails on string filter using a private field",
+			req: &pb.GetUsersRequest{
+				Filter: "password[$gt]=$2a$",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if fails on string filter using a field filterable only by the service",
+			req: &pb.GetUsersRequest{
+				Filter: "email[$prefix]=bob",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if converts structured filter",
+			req: &pb.GetUsersRequest{
+				StructuredFilter: &pb.Filter{
+					Logic: pb.Filter_OR,
+					Conditions: []*pb.Condition{
+						{
+							Field:    "name",
+							Operator: pb.Condition_PREFIX,
+							Value:    &pb.Condition_StringValue{StringValue: "kri"},
+						},
+					},
+					Groups: []*pb.Filter{{
+						Conditions: []*pb.Condition{
+							{
+								Field:    "created_at",
+								Operator: pb.Condition_GREATER_THAN_OR_EQUAL,
+								Value:    &pb.Condition_TimestampValue{TimestampValue: timestamppb.New(timestamp)},
+							},
+							{
+								Field:    "id",
+								Operator: pb.Condition_IN,
+								Value:    &pb.Condition_ListValue{ListValue: &pb.StringList{Values: []string{"1", "2"}}},
+							},
+						},
+					}},
+				},
+			},
+			want: storage.FilterGroup{
+				Logic: storage.Or,
+				Params: filter.Filter{
+					{Attribute: "name", Operator: storage.Prefix, Value: "kri"},
+				},
+				Groups: []storage.FilterGroup{{
+					Logic: storage.And,
+					Params: filter.Filter{
+						{Attribute: "created_at", Operator: filter.GreaterThanOrEqual, Value: "2023-01-02T03:04:05Z"},
+						{Attribute: "id", Operator: storage.In, Value: "1,2"},
+					},
+				}},
+			},
+		},
+		{
+			name: "Test if fails when both filters are provided",
+			req: &pb.GetUsersRequest{
+				Filter:           "name[$eq]=kri",
+				StructuredFilter: &pb.Filter{},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if fails on structured filter using a private field",
+			req: &pb.GetUsersRequest{
+				StructuredFilter: &pb.Filter{
+					Conditions: []*pb.Condition{{
+						Field:    "password",
+						Operator: pb.Condition_GREATER_THAN,
+						Value:    &pb.Condition_StringValue{StringValue: "$2a$"},
+					}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if fails on unspecified operator",
+			req: &pb.GetUsersRequest{
+				StructuredFilter: &pb.Filter{
+					Conditions: []*pb.Condition{{
+						Field: "name",
+						Value: &pb.Condition_StringValue{StringValue: "kri"},
+					}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if fails on missing value",
+			req: &pb.GetUsersRequest{
+				StructuredFilter: &pb.Filter{
+					Conditions: []*pb.Condition{{
+						Field:    "name",
+						Operator: pb.Condition_EQUAL,
+					}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if fails on in operator without a list value",
+			req: &pb.GetUsersRequest{
+				StructuredFilter: &pb.Filter{
+					Conditions: []*pb.Condition{{
+						Field:    "id",
+						Operator: pb.Condition_IN,
+						Value:    &pb.Condition_StringValue{StringValue: "1,2"},
+					}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if fails on list value containing the separator",
+			req: &pb.GetUsersRequest{
+				StructuredFilter: &pb.Filter{
+					Conditions: []*pb.Condition{{
+						Field:    "id",
+						Operator: pb.Condition_IN,
+						Value:    &pb.Condition_ListValue{ListValue: &pb.StringList{Values: []string{"1,2"}}},
+					}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if fails on too deeply nested groups",
+			req: &pb.GetUsersRequest{
+				StructuredFilter: func() *pb.Filter {
+					f := &pb.Filter{}
+					for i := 0; i < maxFilterDepth; i++ {
+						f = &pb.Filter{Groups: []*pb.Filter{f}}
+					}
+					return f
+				}(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test if fails on too many conditions",
+			req: &pb.GetUsersRequest{
+				StructuredFilter: &pb.Filter{
+					Conditions: func() []*pb.Condition {
+						conditions := make([]*pb.Condition, 0, maxFilterConditions+1)
+						for i := 0; i <= maxFilterConditions; i++ {
+							conditions = append(conditions, &pb.Condition{
+								Field:    "name",
+								Operator: pb.Condition_EQUAL,
+								Value:    &pb.Condition_StringValue{StringValue: strings.Repeat("a", i)},
+							})
+						}
+						return conditions
+					}(),
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := filterFromRequest(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("filterFromRequest() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !cmp.Equal(got, tt.want, cmpopts.EquateEmpty()) {
+				t.Errorf("filterFromRequest():\n got = %+v\n want = %+v\n %v", got, tt.want, cmp.Diff(got, tt.want, cmpopts.EquateEmpty()))
+			}
+		})
+	}
+}
@@ -6,236 +6,200 @@ import (
 	"testing"
 	"time"
 
-	"github.com/google/go-cmp/cmp"
-	"github.com/google/go-cmp/cmp/cmpopts"
-	"github.com/krixlion/dev_forum-lib/event"
-	"github.com/krixlion/dev_forum-lib/event/dispatcher"
-	"github.com/krixlion/dev_forum-lib/mocks"
+	grpc_recovery "github.com/grpc-ecosystem/go-grpc-middleware/recovery"
 	"github.com/krixlion/dev_forum-lib/nulls"
-	"github.com/krixlion/dev_forum-user/internal/gentest"
-	"github.com/krixlion/dev_forum-user/pkg/entity"
-	pb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
-	"github.com/krixlion/dev_forum-user/pkg/storage"
-	"github.com/krixlion/dev_forum-user/pkg/storage/storagemocks"
-	"github.com/stretchr/testify/mock"
-	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-func setUpStubServer(db storage.Storage, broker event.Broker) UserServer {
-	s := MakeUserServer(Dependencies{
-		Storage:    db,
-		Logger:     nulls.NullLogger{},
-		Broker:     broker,
-		Tracer:     nulls.NullTracer{},
-		Dispatcher: dispatcher.NewDispatcher(0),
+func setUpStubServer() UserServer {
+	return MakeUserServer(Dependencies{
+		Logger: nulls.NullLogger{},
+		Tracer: nulls.NullTracer{},
 	})
+}
+
+// stubServerStream is a stream with no messages.
+type stubServerStream struct {
+	grpc.ServerStream
+}
+
+func (s stubServerStream) Context() context.Context {
+	return context.Background()
+}
+
+// recordingLogger records keyvals of every logged message.
+type recordingLogger struct {
+	logs []map[interface{}]interface{}
+}
+
+func (l *recordingLogger) Log(ctx context.Context, msg string, keyvals ...interface{}) {
+	log := map[interface{}]interface{}{"msg": msg}
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		log[keyvals[i]] = keyvals[i+1]
+	}
+	l.logs = append(l.logs, log)
+}
+
+// checkRPCLog fails the test unless the logger recorded exactly one RPC
+// of the method with the code, which took at least minDuration.
+func checkRPCLog(t *testing.T, logger *recordingLogger, method string, code codes.Code, minDuration time.Duration) {
+	t.Helper()
+
+	if len(logger.logs) != 1 {
+		t.Fatalf("logged %d messages, want 1: %v", len(logger.logs), logger.logs)
+	}
+	log := logger.logs[0]
+
+	if log["method"] != method {
+		t.Errorf("logged method = %v, want %v", log["method"], method)
+	}
 
-	return s
+	if log["code"] != code.String() {
+		t.Errorf("logged code = %v, want %v", log["code"], code)
+	}
+
+	duration, err := time.ParseDuration(log["duration"].(string))
+	if err != nil || duration < minDuration {
+		t.Errorf("logged duration = %v, want at least %v", log["duration"], minDuration)
+	}
 }
 
-func TestUserServer_validateCreate(t *testing.T) {
+func TestUserServer_LogRequestInterceptor(t *testing.T) {
+	const method = "/user.v1.UserService/Get"
+	const delay = 10 * time.Millisecond
+
 	tests := []struct {
-		name    string
-		handler mocks.UnaryHandler
-		storage storagemocks.Storage
-		broker  mocks.Broker
-		req     *pb.CreateUserRequest
-		want    *pb.CreateUserResponse
-		wantErr bool
+		name     string
+		err      error
+		wantCode codes.Code
 	}{
 		{
-			name: "Test if validation fails on invalid email",
-			storage: func() storagemocks.Storage {
-				m := storagemocks.NewStorage()
-				return m
-			}(),
-			handler: func() mocks.UnaryHandler {
-				m := mocks.NewUnaryHandler()
-				return m
-			}(),
-			broker: func() mocks.Broker {
-				m := mocks.NewBroker()
-				m.On("ResilientPublish", mock.AnythingOfType("event.Event")).Return(nil).Once()
-				return m
-			}(),
-			req: &pb.CreateUserRequest{
-				User: &pb.User{
-					Id:    "Id",
-					Name:  "Name",
-					Email: "invalid email",
-				},
-			},
-			wantErr: true,
+			name:     "Test if logs successful RPCs",
+			wantCode: codes.OK,
+		},
+		{
+			name:     "Test if logs the code of failed RPCs",
+			err:      status.Error(codes.NotFound, "not found"),
+			wantCode: codes.NotFound,
 		},
 		{
-			name: "Test if validation fails on password shorter than 8 chars",
-			storage: func() storagemocks.Storage {
-				m := storagemocks.NewStorage()
-				return m
-			}(),
-			handler: func() mocks.UnaryHandler {
-				m := mocks.NewUnaryHandler()
-				return m
-			}(),
-			broker: func() mocks.Broker {
-				m := mocks.NewBroker()
-				m.On("ResilientPublish", mock.AnythingOfType("event.Event")).Return(nil).Once()
-				return m
-			}(),
-			req: &pb.CreateUserRequest{
-				User: &pb.User{
-					Id:       "Id",
-					Name:     "Name",
-					Email:    "invalid email",
-					Password: "1234567",
-				},
-			},
-			wantErr: true,
+			name:     "Test if logs non-status errors as Unknown",
+			err:      errors.New("test error"),
+			wantCode: codes.Unknown,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-			defer cancel()
+			logger := &recordingLogger{}
+			s := MakeUserServer(Dependencies{Logger: logger, Tracer: nulls.NullTracer{}})
 
-			s := setUpStubServer(tt.storage, tt.broker)
-
-			got, err := s.validateCreate(ctx, tt.req, tt.handler.GetMock())
-			if (err != nil) != tt.wantErr {
-				t.Errorf("UserServer.validateCreate() error = %v, wantErr %v", err, tt.wantErr)
-				return
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				time.Sleep(delay)
+				return "resp", tt.err
 			}
 
-			if !cmp.Equal(got, tt.want, cmpopts.EquateApproxTime(time.Second), cmpopts.EquateEmpty()) && !tt.wantErr {
-				t.Errorf("UserServer.validateCreate():\n got = %v\n want = %v\n %v", got, tt.want, cmp.Diff(got, tt.want))
+			resp, err := s.LogRequestInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+			if resp != "resp" || !errors.Is(err, tt.err) {
+				t.Errorf("UserServer.LogRequestInterceptor() = %v, %v, want the handler's results", resp, err)
 			}
+
+			checkRPCLog(t, logger, method, tt.wantCode, delay)
 		})
 	}
 }
 
-func TestUserServer_validateUpdate(t *testing.T) {
+func TestUserServer_LogStreamInterceptor(t *testing.T) {
+	const method = "/user.v1.UserService/GetStream"
+	const delay = 10 * time.Millisecond
+
 	tests := []struct {
-		name    string
-		handler mocks.UnaryHandler
-		storage storagemocks.Storage
-		broker  mocks.Broker
-		req     *pb.UpdateUserRequest
-		want    *emptypb.Empty
-		wantErr bool
+		name     string
+		err      error
+		wantCode codes.Code
 	}{
 		{
-			name: "Test if validation fails on invalid email",
-			storage: func() storagemocks.Storage {
-				m := storagemocks.NewStorage()
-				return m
-			}(),
-			handler: func() mocks.UnaryHandler {
-				m := mocks.NewUnaryHandler()
-				return m
-			}(),
-			broker: func() mocks.Broker {
-				m := mocks.NewBroker()
-				m.On("ResilientPublish", mock.AnythingOfType("event.Event")).Return(nil).Once()
-				return m
-			}(),
-			req: &pb.UpdateUserRequest{
-				User: &pb.User{
-					Id:    "Id",
-					Name:  "Name",
-					Email: "Invalid email",
-				},
-			},
-			wantErr: true,
+			name:     "Test if logs successful RPCs",
+			wantCode: codes.OK,
 		},
 		{
-			name: "Test if validation fails on password shorter than 8 chars",
-			storage: func() storagemocks.Storage {
-				m := storagemocks.NewStorage()
-				return m
-			}(),
-			handler: func() mocks.UnaryHandler {
-				m := mocks.NewUnaryHandler()
-				return m
-			}(),
-			broker: func() mocks.Broker {
-				m := mocks.NewBroker()
-				m.On("ResilientPublish", mock.AnythingOfType("event.Event")).Return(nil).Once()
-				return m
-			}(),
-			req: &pb.UpdateUserRequest{
-				User: &pb.User{
-					Id:       "Id",
-					Name:     "Name",
-					Email:    "invalid email",
-					Password: "1234567",
-				},
-			},
-			wantErr: true,
+			name:     "Test if logs the code of failed RPCs",
+			err:      status.Error(codes.Canceled, "canceled"),
+			wantCode: codes.Canceled,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-			defer cancel()
+			logger := &recordingLogger{}
+			s := MakeUserServer(Dependencies{Logger: logger, Tracer: nulls.NullTracer{}})
 
-			s := setUpStubServer(tt.storage, tt.broker)
-
-			got, err := s.validateUpdate(ctx, tt.req, tt.handler.GetMock())
-			if (err != nil) != tt.wantErr {
-				t.Errorf("UserServer.validateUpdate() error = %v, wantErr %v", err, tt.wantErr)
-				return
+			handler := func(srv interface{}, stream grpc.ServerStream) error {
+				time.Sleep(delay)
+				return tt.err
 			}
-			if !cmp.Equal(got, tt.want, cmpopts.EquateApproxTime(time.Second), cmpopts.EquateEmpty()) && !tt.wantErr {
-				t.Errorf("UserServer.validateUpdate():\n got = %v\n want = %v\n %v", got, tt.want, cmp.Diff(got, tt.want))
+
+			err := s.LogStreamInterceptor()(nil, stubServerStream{}, &grpc.StreamServerInfo{FullMethod: method}, handler)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("UserServer.LogStreamInterceptor() error = %v, want %v", err, tt.err)
 			}
+
+			checkRPCLog(t, logger, method, tt.wantCode, delay)
 		})
 	}
 }
 
-func TestUserServer_validateDelete(t *testing.T) {
-	tests := []struct {
-		name    string
-		handler mocks.UnaryHandler
-		storage storagemocks.Storage
-		broker  mocks.Broker
-		req     *pb.DeleteUserRequest
-		wantErr bool
-	}{
-		{
-			name: "Test if returns OK regardless whether user exists or not",
-			broker: func() mocks.Broker {
-				m := mocks.NewBroker()
-				m.On("ResilientPublish", mock.AnythingOfType("event.Event")).Return(nil).Once()
-				return m
-			}(),
-			handler: func() mocks.UnaryHandler {
-				m := mocks.NewUnaryHandler()
-				return m
-			}(),
-			storage: func() storagemocks.Storage {
-				m := storagemocks.NewStorage()
-				m.On("Get", mock.Anything, mock.AnythingOfType("filter.Filter")).Return(entity.User{}, errors.New("not found")).Once()
-				return m
-			}(),
-
-			req: &pb.DeleteUserRequest{
-				Id: gentest.RandomString(10),
-			},
-			wantErr: false,
-		},
+func TestUserServer_LogStreamInterceptor_panic(t *testing.T) {
+	const method = "/user.v1.UserService/GetStream"
+
+	logger := &recordingLogger{}
+	s := MakeUserServer(Dependencies{Logger: logger, Tracer: nulls.NullTracer{}})
+	recovery := grpc_recovery.StreamServerInterceptor(grpc_recovery.WithRecoveryHandlerContext(s.RecoverPanic))
+
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		panic("test panic")
 	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-			defer cancel()
 
-			s := setUpStubServer(tt.storage, tt.broker)
+	// Chained like in main, where the logging interceptor wraps the recovery one.
+	info := &grpc.StreamServerInfo{FullMethod: method}
+	err := s.LogStreamInterceptor()(nil, stubServerStream{}, info, func(srv interface{}, stream grpc.ServerStream) error {
+		return recovery(srv, stream, info, handler)
+	})
+	if code := status.Code(err); code != codes.Internal {
+		t.Errorf("UserServer.LogStreamInterceptor() code = %v, want %v", code, codes.Internal)
+	}
 
-			_, err := s.validateDelete(ctx, tt.req, tt.handler.GetMock())
-			if (err != nil) != tt.wantErr {
-				t.Errorf("UserServer.validateDelete() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-		})
+	// The panic is logged by RecoverPanic before the RPC is.
+	if len(logger.logs) != 2 || logger.logs[0]["msg"] != "Recovered from panic" {
+		t.Fatalf("logged %v, want the panic and the RPC", logger.logs)
 	}
+	logger.logs = logger.logs[1:]
+	checkRPCLog(t, logger, method, codes.Internal, 0)
+}
+
+func TestUserServer_RecoverPanic(t *testing.T) {
+	s := setUpStubServer()
+	recovery := grpc_recovery.WithRecoveryHandlerContext(s.RecoverPanic)
+
+	t.Run("Test if recovers unary RPCs", func(t *testing.T) {
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			panic("test panic")
+		}
+
+		_, err := grpc_recovery.UnaryServerInterceptor(recovery)(context.Background(), nil, &grpc.UnaryServerInfo{}, handler)
+		if code := status.Code(err); code != codes.Internal {
+			t.Errorf("UserServer.RecoverPanic() code = %v, want %v", code, codes.Internal)
+		}
+	})
+
+	t.Run("Test if recovers streaming RPCs", func(t *testing.T) {
+		handler := func(srv interface{}, stream grpc.ServerStream) error {
+			panic("test panic")
+		}
+
+		err := grpc_recovery.StreamServerInterceptor(recovery)(nil, stubServerStream{}, &grpc.StreamServerInfo{}, handler)
+		if code := status.Code(err); code != codes.Internal {
+			t.Errorf("UserServer.RecoverPanic() code = %v, want %v", code, codes.Internal)
+		}
+	})
 }
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	// maxStreamLimit bounds the number of users sent by GetStream.
+	maxStreamLimit = 1000
+)
+
+// pageSize returns the requested page size bounded by maxPageSize
+// or defaultPageSize if none was requested.
+func pageSize(requested uint32) uint {
+	switch {
+	case requested == 0:
+		return defaultPageSize
+	case requested > maxPageSize:
+		return maxPageSize
+	default:
+		return uint(requested)
+	}
+}
+
+// encodePageToken returns an opaque token pointing to the given offset.
+func encodePageToken(offset uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(offset), 10)))
+}
+
+// decodePageToken returns the offset the token points to.
+// Empty token points to the first page.
+func decodePageToken(token string) (uint, error) {
+	if token == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+
+	offset, err := strconv.ParseUint(string(raw), 10, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint(offset), nil
+}
+
+// streamLimit returns the requested stream limit or maxStreamLimit if none was requested,
+// so that users cannot stream the whole table.
+func streamLimit(requested string) (string, error) {
+	if requested == "" {
+		return strconv.Itoa(maxStreamLimit), nil
+	}
+
+	limit, err := strconv.ParseUint(requested, 10, 0)
+	if err != nil || limit == 0 || limit > maxStreamLimit {
+		return "", fmt.Errorf("limit must be between 1 and %d", maxStreamLimit)
+	}
+
+	return requested, nil
+}
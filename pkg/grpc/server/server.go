@@ -2,76 +2,107 @@ package server
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/krixlion/dev_forum-lib/cert"
-	"github.com/krixlion/dev_forum-lib/event"
-	"github.com/krixlion/dev_forum-lib/event/dispatcher"
-	"github.com/krixlion/dev_forum-lib/filter"
 	"github.com/krixlion/dev_forum-lib/logging"
+	"github.com/krixlion/dev_forum-user/pkg/domain"
+	"github.com/krixlion/dev_forum-user/pkg/entity"
+	"github.com/krixlion/dev_forum-user/pkg/grpc/ratelimit"
 	pb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
-	"github.com/krixlion/dev_forum-user/pkg/storage"
+	"github.com/krixlion/dev_forum-user/pkg/watch"
 
 	fmask "github.com/mennanov/fieldmask-utils"
 	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
-	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type UserServer struct {
 	pb.UnimplementedUserServiceServer
-	storage    storage.Storage
-	dispatcher *dispatcher.Dispatcher
-	broker     event.Broker
-	logger     logging.Logger
-	tracer     trace.Tracer
-	config     Config
+	users   *domain.UserManager
+	watches *watch.Hub
+	logger  logging.Logger
+	tracer  trace.Tracer
+	config  Config
 }
 
+// Config zero values fall back to defaults.
 type Config struct {
 	VerifyClientCert bool
+	// RequestTimeout limits the duration of unary RPCs.
+	RequestTimeout time.Duration
+	// StreamTimeout limits the duration of streaming RPCs.
+	StreamTimeout time.Duration
+	// Actor identifies callers in the audit log. Defaults to ratelimit.IdentityKey.
+	Actor func(context.Context) string
+	// AdminClient is the name the client certificate has to hold to call admin RPCs, such as ListAuditEntries.
+	AdminClient string
+}
+
+const (
+	defaultRequestTimeout = time.Second * 5
+	defaultStreamTimeout  = time.Second * 10
+	defaultAdminClient    = "admin-service"
+)
+
+// RequestIdMetadataKey is the metadata header identifying requests in the audit log.
+// The trace id is recorded for requests without it.
+const RequestIdMetadataKey = "x-request-id"
+
+func (c Config) withDefaults() Config {
+	if c.RequestTimeout == 0 {
+		c.RequestTimeout = defaultRequestTimeout
+	}
+
+	if c.StreamTimeout == 0 {
+		c.StreamTimeout = defaultStreamTimeout
+	}
+
+	if c.Actor == nil {
+		c.Actor = ratelimit.IdentityKey
+	}
+
+	if c.AdminClient == "" {
+		c.AdminClient = defaultAdminClient
+	}
+
+	return c
 }
 
 type Dependencies struct {
-	Storage    storage.Storage
-	Broker     event.Broker
-	Dispatcher *dispatcher.Dispatcher
-	Logger     logging.Logger
-	Tracer     trace.Tracer
-	Config     Config
+	Users *domain.UserManager
+	// Watches serves WatchUsers, which is unimplemented if nil.
+	Watches *watch.Hub
+	Logger  logging.Logger
+	Tracer  trace.Tracer
+	Config  Config
 }
 
+// MakeUserServer returns a gRPC adapter of the user manager.
 func MakeUserServer(d Dependencies) UserServer {
 	return UserServer{
-		storage:    d.Storage,
-		broker:     d.Broker,
-		dispatcher: d.Dispatcher,
-		tracer:     d.Tracer,
-		logger:     d.Logger,
-		config:     d.Config,
+		users:   d.Users,
+		watches: d.Watches,
+		tracer:  d.Tracer,
+		logger:  d.Logger,
+		config:  d.Config.withDefaults(),
 	}
 }
 
-func (s UserServer) Close() error {
-	return s.storage.Close()
-}
-
 func (s UserServer) Create(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
-	user := userFromPB(req.GetUser())
+	ctx, cancel := context.WithTimeout(ctx, s.config.RequestTimeout)
+	defer cancel()
 
-	if err := s.storage.Create(ctx, user); err != nil {
-		return nil, status.Errorf(codes.Internal, err.Error())
-	}
+	ctx = domain.WithAuditInfo(ctx, s.auditInfo(ctx))
 
-	event, err := event.MakeEvent(event.UserAggregate, event.UserCreated, user)
+	user, err := s.users.Create(ctx, userFromPB(req.GetUser()))
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, err.Error())
-	}
-
-	if err := s.broker.ResilientPublish(event); err != nil {
-		return nil, status.Errorf(codes.Internal, err.Error())
+		return nil, toStatus(err)
 	}
 
 	return &pb.CreateUserResponse{
@@ -80,29 +111,20 @@ func (s UserServer) Create(ctx context.Context, req *pb.CreateUserRequest) (*pb.
 }
 
 func (s UserServer) Delete(ctx context.Context, req *pb.DeleteUserRequest) (*emptypb.Empty, error) {
-	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	ctx, cancel := context.WithTimeout(ctx, s.config.RequestTimeout)
 	defer cancel()
 
-	id := req.GetId()
+	ctx = domain.WithAuditInfo(ctx, s.auditInfo(ctx))
 
-	if err := s.storage.Delete(ctx, id); err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, err.Error())
-	}
-
-	event, err := event.MakeEvent(event.UserAggregate, event.UserDeleted, id)
-	if err != nil {
-		return nil, status.Errorf(codes.Internal, err.Error())
-	}
-
-	if err := s.broker.ResilientPublish(event); err != nil {
-		return nil, status.Errorf(codes.Internal, err.Error())
+	if err := s.users.Delete(ctx, req.GetId()); err != nil {
+		return nil, toStatus(err)
 	}
 
 	return &emptypb.Empty{}, nil
 }
 
 func (s UserServer) Update(ctx context.Context, req *pb.UpdateUserRequest) (*emptypb.Empty, error) {
-	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	ctx, cancel := context.WithTimeout(ctx, s.config.RequestTimeout)
 	defer cancel()
 
 	mask, err := fmask.MaskFromPaths(req.GetFieldMask().GetPaths(), mapUserFields)
@@ -110,53 +132,46 @@ func (s UserServer) Update(ctx context.Context, req *pb.UpdateUserRequest) (*emp
 		return nil, status.Errorf(codes.Internal, err.Error())
 	}
 
-	if err := fmask.StructToStruct(mask, req.GetUser(), req.User); err != nil {
-		return nil, status.Errorf(codes.Internal, err.Error())
+	// Only the masked fields are updated, so the rest are left out,
+	// as they are not validated either. Without a mask all fields are updated.
+	user := req.GetUser()
+	if len(req.GetFieldMask().GetPaths()) > 0 {
+		user = &pb.User{Id: req.GetUser().GetId()}
+		if err := fmask.StructToStruct(mask, req.GetUser(), user); err != nil {
+			return nil, status.Errorf(codes.Internal, err.Error())
+		}
 	}
 
-	user := userFromPB(req.GetUser())
-	user.UpdatedAt = time.Now()
+	ctx = domain.WithAuditInfo(ctx, s.auditInfo(ctx))
 
-	if err := s.storage.Update(ctx, user); err != nil {
-		return nil, status.Errorf(codes.Internal, err.Error())
-	}
-
-	event, err := event.MakeEvent(event.UserAggregate, event.UserUpdated, user)
-	if err != nil {
-		return nil, status.Errorf(codes.Internal, err.Error())
+	if err := s.users.Update(ctx, userFromPB(user)); err != nil {
+		return nil, toStatus(err)
 	}
 
-	if err := s.broker.ResilientPublish(event); err != nil {
-		return nil, status.Errorf(codes.Internal, err.Error())
-	}
 	return &emptypb.Empty{}, nil
 }
 
 func (s UserServer) Get(ctx context.Context, req *pb.GetUserRequest) (*pb.GetUserResponse, error) {
-	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	ctx, cancel := context.WithTimeout(ctx, s.config.RequestTimeout)
 	defer cancel()
 
-	query := filter.Filter{{
-		Attribute: "id",
-		Operator:  filter.Equal,
-		Value:     req.GetId(),
-	}}
+	fields, err := readFields(req.GetReadMask(), publicUserFields, defaultPublicUserFields)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 
-	user, err := s.storage.Get(ctx, query)
+	user, err := s.users.Get(ctx, req.GetId(), fields)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Failed to get user: %v", err)
+		return nil, toStatus(err)
 	}
 
 	return &pb.GetUserResponse{
-		User: &pb.User{
-			Id:   user.Id,
-			Name: user.Name,
-		},
+		User: userToPB(user, fields),
 	}, nil
 }
 
 func (s UserServer) GetSecret(ctx context.Context, req *pb.GetUserSecretRequest) (*pb.GetUserSecretResponse, error) {
-	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	ctx, cancel := context.WithTimeout(ctx, s.config.RequestTimeout)
 	defer cancel()
 
 	if s.config.VerifyClientCert {
@@ -165,52 +180,51 @@ func (s UserServer) GetSecret(ctx context.Context, req *pb.GetUserSecretRequest)
 		}
 	}
 
-	query := filter.Filter{}
+	fields, err := readFields(req.GetReadMask(), secretUserFields, nil)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 
+	var user entity.User
 	switch req.GetQuery().(type) {
 	case *pb.GetUserSecretRequest_Email:
-		query = append(query, filter.Parameter{
-			Attribute: "email",
-			Operator:  filter.Equal,
-			Value:     req.GetEmail(),
-		})
+		user, err = s.users.GetByEmail(ctx, req.GetEmail(), fields)
 	case *pb.GetUserSecretRequest_Id:
-		query = append(query, filter.Parameter{
-			Attribute: "id",
-			Operator:  filter.Equal,
-			Value:     req.GetId(),
-		})
+		user, err = s.users.Get(ctx, req.GetId(), fields)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "Id or email is required")
 	}
-
-	user, err := s.storage.Get(ctx, query)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Failed to get user: %v", err)
+		return nil, toStatus(err)
 	}
 
 	return &pb.GetUserSecretResponse{
-		User: &pb.User{
-			Id:        user.Id,
-			Name:      user.Name,
-			Password:  user.Password,
-			Email:     user.Email,
-			CreatedAt: timestamppb.New(user.CreatedAt),
-			UpdatedAt: timestamppb.New(user.UpdatedAt),
-		},
+		User: userToPB(user, fields),
 	}, nil
 }
 
 func (s UserServer) GetStream(req *pb.GetUsersRequest, stream pb.UserService_GetStreamServer) error {
-	ctx, cancel := context.WithTimeout(stream.Context(), time.Second*10)
+	ctx, cancel := context.WithTimeout(stream.Context(), s.config.StreamTimeout)
 	defer cancel()
 
-	query, err := filter.Parse(req.GetFilter())
+	limit, err := streamLimit(req.GetLimit())
 	if err != nil {
-		return err
+		return status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	users, err := s.storage.GetMultiple(ctx, req.GetOffset(), req.GetLimit(), query)
+	query, err := filterFromRequest(req)
 	if err != nil {
-		return err
+		return status.Errorf(codes.InvalidArgument, "Invalid filter: %v", err)
+	}
+
+	fields, err := readFields(req.GetReadMask(), publicUserFields, defaultPublicUserFields)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	users, err := s.users.List(ctx, req.GetOffset(), limit, query, fields)
+	if err != nil {
+		return toStatus(err)
 	}
 
 	for _, v := range users {
@@ -218,15 +232,222 @@ func (s UserServer) GetStream(req *pb.GetUsersRequest, stream pb.UserService_Get
 		case <-ctx.Done():
 			return nil
 		default:
-			user := pb.User{
-				Id:   v.Id,
-				Name: v.Name,
-			}
-
-			if err := stream.Send(&user); err != nil {
+			if err := stream.Send(userToPB(v, fields)); err != nil {
 				return err
 			}
 		}
 	}
 	return nil
 }
+
+func (s UserServer) SearchUsers(ctx context.Context, req *pb.SearchUsersRequest) (*pb.SearchUsersResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.RequestTimeout)
+	defer cancel()
+
+	offset, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid page token")
+	}
+	limit := pageSize(req.GetPageSize())
+
+	// Fetch one extra user to find out whether there is a next page.
+	users, err := s.users.Search(ctx, req.GetQuery(), offset, limit+1)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	nextPageToken := ""
+	if uint(len(users)) > limit {
+		users = users[:limit]
+		nextPageToken = encodePageToken(offset + limit)
+	}
+
+	pbUsers := make([]*pb.User, 0, len(users))
+	for _, v := range users {
+		pbUsers = append(pbUsers, &pb.User{
+			Id:   v.Id,
+			Name: v.Name,
+		})
+	}
+
+	return &pb.SearchUsersResponse{
+		Users:         pbUsers,
+		NextPageToken: nextPageToken,
+	}, nil
+}
+
+// WatchUsers is not limited by the StreamTimeout, since clients are expected
+// to keep watching as long as they are connected.
+func (s UserServer) WatchUsers(req *pb.WatchUsersRequest, stream pb.UserService_WatchUsersServer) error {
+	if s.watches == nil {
+		return status.Error(codes.Unimplemented, "Watching users is disabled")
+	}
+
+	filter, err := watchFilterFromPB(req)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = s.watches.Watch(stream.Context(), req.GetSince(), filter, func(n watch.Notification) error {
+		return stream.Send(notificationToPB(n))
+	})
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, watch.ErrInvalidToken):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, watch.ErrTokenExpired):
+		return status.Error(codes.OutOfRange, err.Error())
+	case errors.Is(err, watch.ErrSlowConsumer):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, watch.ErrClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		// Errors of sending are returned as they are.
+		return err
+	}
+}
+
+func (s UserServer) ListAuditEntries(ctx context.Context, req *pb.ListAuditEntriesRequest) (*pb.ListAuditEntriesResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.RequestTimeout)
+	defer cancel()
+
+	if err := s.verifyAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	offset, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid page token")
+	}
+	limit := pageSize(req.GetPageSize())
+
+	var from, to time.Time
+	if req.GetStartTime() != nil {
+		from = req.GetStartTime().AsTime()
+	}
+	if req.GetEndTime() != nil {
+		to = req.GetEndTime().AsTime()
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return nil, status.Error(codes.InvalidArgument, "start_time must be before end_time")
+	}
+
+	// Fetch one extra entry to find out whether there is a next page.
+	entries, err := s.users.ListAuditEntries(ctx, req.GetUserId(), from, to, offset, limit+1)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	nextPageToken := ""
+	if uint(len(entries)) > limit {
+		entries = entries[:limit]
+		nextPageToken = encodePageToken(offset + limit)
+	}
+
+	pbEntries := make([]*pb.AuditEntry, 0, len(entries))
+	for _, v := range entries {
+		pbEntries = append(pbEntries, auditEntryToPB(v))
+	}
+
+	return &pb.ListAuditEntriesResponse{
+		Entries:       pbEntries,
+		NextPageToken: nextPageToken,
+	}, nil
+}
+
+func (s UserServer) ExportUserData(ctx context.Context, req *pb.ExportUserDataRequest) (*pb.ExportUserDataResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.RequestTimeout)
+	defer cancel()
+
+	if err := s.verifyAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	export, err := s.users.Export(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	document, err := json.Marshal(export)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.ExportUserDataResponse{
+		Document: document,
+	}, nil
+}
+
+func (s UserServer) EraseUser(ctx context.Context, req *pb.EraseUserRequest) (*emptypb.Empty, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.RequestTimeout)
+	defer cancel()
+
+	if err := s.verifyAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	ctx = domain.WithAuditInfo(ctx, s.auditInfo(ctx))
+
+	if err := s.users.Erase(ctx, req.GetId()); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// verifyAdmin fails with PermissionDenied unless the client certificate was issued to the admin client.
+func (s UserServer) verifyAdmin(ctx context.Context) error {
+	if !s.config.VerifyClientCert {
+		return nil
+	}
+
+	if err := cert.VerifyClientTLS(ctx, s.config.AdminClient); err != nil {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	return nil
+}
+
+// toStatus maps errors returned by the user manager to gRPC statuses.
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return status.Error(codes.NotFound, "User not found")
+	case errors.Is(err, domain.ErrInvalidQuery):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrInvalidName):
+		return invalidField("user.name", err)
+	case errors.Is(err, domain.ErrNameReserved):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrNameTaken):
+		return status.Error(codes.AlreadyExists, "Name already taken")
+	case errors.Is(err, domain.ErrInvalidEmail), errors.Is(err, domain.ErrEmailNotAllowed):
+		return invalidField("user.email", err)
+	case errors.Is(err, domain.ErrPasswordTooLong):
+		return invalidField("user.password", err)
+	case errors.Is(err, domain.ErrEmailTaken):
+		return status.Error(codes.AlreadyExists, "Email already taken")
+	case errors.Is(err, domain.ErrInvalidCredentials):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// invalidField returns an InvalidArgument status detailing the violation of the field,
+// like the ones returned by the validation interceptor.
+func invalidField(field string, err error) error {
+	st := status.New(codes.InvalidArgument, err.Error())
+	detailed, detailErr := st.WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: field, Description: err.Error()}},
+	})
+	if detailErr != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
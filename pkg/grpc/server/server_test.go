@@ -2,29 +2,43 @@ package server_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
-	"github.com/krixlion/dev_forum-lib/event/dispatcher"
+	"github.com/krixlion/dev_forum-lib/event"
 	"github.com/krixlion/dev_forum-lib/mocks"
 	"github.com/krixlion/dev_forum-lib/nulls"
 	"github.com/krixlion/dev_forum-user/internal/gentest"
+	"github.com/krixlion/dev_forum-user/pkg/domain"
+	"github.com/krixlion/dev_forum-user/pkg/email"
 	"github.com/krixlion/dev_forum-user/pkg/entity"
+	"github.com/krixlion/dev_forum-user/pkg/grpc/ratelimit"
 	"github.com/krixlion/dev_forum-user/pkg/grpc/server"
 	pb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-user/pkg/grpc/validation"
 	"github.com/krixlion/dev_forum-user/pkg/storage"
 	"github.com/krixlion/dev_forum-user/pkg/storage/storagemocks"
+	"github.com/krixlion/dev_forum-user/pkg/watch"
 	"github.com/stretchr/testify/mock"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
 )
 
@@ -33,6 +47,19 @@ import (
 // Returns a client to interact with the server.
 // The server is shutdown when ctx.Done() receives.
 func setUpServer(ctx context.Context, db storage.Storage, broker mocks.Broker) pb.UserServiceClient {
+	return serve(ctx, server.Dependencies{
+		Users: domain.NewUserManager(domain.Config{}, domain.Dependencies{
+			Storage: db,
+			Broker:  broker,
+			Tracer:  nulls.NullTracer{},
+		}),
+		Logger: nulls.NullLogger{},
+		Tracer: nulls.NullTracer{},
+	})
+}
+
+// serve runs the server with the given dependencies and options like setUpServer.
+func serve(ctx context.Context, d server.Dependencies, opts ...grpc.ServerOption) pb.UserServiceClient {
 	// bufconn allows the server to call itself
 	// great for testing across whole infrastructure
 	lis := bufconn.Listen(1024 * 1024)
@@ -40,15 +67,8 @@ func setUpServer(ctx context.Context, db storage.Storage, broker mocks.Broker) p
 		return lis.Dial()
 	}
 
-	s := grpc.NewServer()
-	server := server.MakeUserServer(server.Dependencies{
-		Storage:    db,
-		Logger:     nulls.NullLogger{},
-		Tracer:     nulls.NullTracer{},
-		Broker:     broker,
-		Dispatcher: dispatcher.NewDispatcher(0),
-	})
-	pb.RegisterUserServiceServer(s, server)
+	s := grpc.NewServer(opts...)
+	pb.RegisterUserServiceServer(s, server.MakeUserServer(d))
 	go func() {
 		if err := s.Serve(lis); err != nil {
 			log.Fatalf("Server exited with an error: %v", err)
@@ -94,7 +114,7 @@ func TestUserServer_Get(t *testing.T) {
 			},
 			storage: func() storagemocks.Storage {
 				m := storagemocks.NewStorage()
-				m.On("Get", mock.Anything, mock.AnythingOfType("filter.Filter")).Return(v, nil).Once()
+				m.On("Get", mock.Anything, mock.AnythingOfType("filter.Filter"), mock.Anything).Return(v, nil).Once()
 				return m
 			}(),
 			broker: func() mocks.Broker {
@@ -103,6 +123,17 @@ func TestUserServer_Get(t *testing.T) {
 				return m
 			}(),
 		},
+		{
+			desc: "Test if error is returned on private field in read mask",
+			arg: &pb.GetUserRequest{
+				Id:       user.Id,
+				ReadMask: &fieldmaskpb.FieldMask{Paths: []string{"password"}},
+			},
+			want:    nil,
+			wantErr: true,
+			storage: storagemocks.NewStorage(),
+			broker:  mocks.NewBroker(),
+		},
 		{
 			desc: "Test if error is returned properly on storage error",
 			arg: &pb.GetUserRequest{
@@ -112,7 +143,7 @@ func TestUserServer_Get(t *testing.T) {
 			wantErr: true,
 			storage: func() storagemocks.Storage {
 				m := storagemocks.NewStorage()
-				m.On("Get", mock.Anything, mock.AnythingOfType("filter.Filter")).Return(entity.User{}, errors.New("test err")).Once()
+				m.On("Get", mock.Anything, mock.AnythingOfType("filter.Filter"), mock.Anything).Return(entity.User{}, errors.New("test err")).Once()
 				return m
 			}(),
 			broker: func() mocks.Broker {
@@ -151,12 +182,12 @@ func TestUserServer_Get(t *testing.T) {
 }
 
 func TestUserServer_Create(t *testing.T) {
-	v := gentest.RandomUser(2, 5, 5)
+	v := gentest.RandomUser(5, 5, 5)
 	User := &pb.User{
 		Id:       v.Id,
 		Name:     v.Name,
 		Password: v.Password,
-		Email:    v.Email,
+		Email:    v.Email + "@example.com",
 	}
 
 	tests := []struct {
@@ -177,7 +208,8 @@ func TestUserServer_Create(t *testing.T) {
 			},
 			storage: func() storagemocks.Storage {
 				m := storagemocks.NewStorage()
-				m.On("Create", mock.Anything, mock.AnythingOfType("entity.User")).Return(nil).Once()
+				m.On("EmailTaken", mock.Anything, User.Email, "", mock.AnythingOfType("string")).Return(false, nil).Once()
+				m.On("Create", mock.Anything, mock.AnythingOfType("entity.User"), mock.AnythingOfType("storage.AuditInfo")).Return(nil).Once()
 				return m
 			}(),
 			broker: func() mocks.Broker {
@@ -195,7 +227,8 @@ func TestUserServer_Create(t *testing.T) {
 			wantErr:  true,
 			storage: func() storagemocks.Storage {
 				m := storagemocks.NewStorage()
-				m.On("Create", mock.Anything, mock.AnythingOfType("entity.User")).Return(errors.New("test err")).Once()
+				m.On("EmailTaken", mock.Anything, User.Email, "", mock.AnythingOfType("string")).Return(false, nil).Once()
+				m.On("Create", mock.Anything, mock.AnythingOfType("entity.User"), mock.AnythingOfType("storage.AuditInfo")).Return(errors.New("test err")).Once()
 				return m
 			}(),
 			broker: func() mocks.Broker {
@@ -236,13 +269,122 @@ func TestUserServer_Create(t *testing.T) {
 	}
 }
 
+func TestUserServer_Create_displayName(t *testing.T) {
+	ctx, shutdown := context.WithCancel(context.Background())
+	defer shutdown()
+
+	db := storagemocks.NewStorage()
+	db.On("EmailTaken", mock.Anything, "bob@example.com", "", mock.AnythingOfType("string")).Return(false, nil).Once()
+	db.On("Create", mock.Anything, mock.MatchedBy(func(user entity.User) bool {
+		return user.Email == "bob@example.com"
+	}), mock.AnythingOfType("storage.AuditInfo")).Return(nil).Once()
+
+	broker := mocks.NewBroker()
+	broker.On("ResilientPublish", mock.AnythingOfType("event.Event")).Return(nil).Once()
+
+	// Requests pass the validation interceptor before the domain canonicalizes their emails.
+	client := serve(ctx, server.Dependencies{
+		Users: domain.NewUserManager(domain.Config{}, domain.Dependencies{
+			Storage: db,
+			Broker:  broker,
+			Tracer:  nulls.NullTracer{},
+		}),
+		Logger: nulls.NullLogger{},
+		Tracer: nulls.NullTracer{},
+	}, grpc.UnaryInterceptor(validation.UnaryServerInterceptor()))
+
+	user := &pb.User{Name: "bob", Email: `"Bob" <bob@Example.com>`, Password: "12345678"}
+	if _, err := client.Create(ctx, &pb.CreateUserRequest{User: user}); err != nil {
+		t.Fatalf("UserServer.Create() error = %v", err)
+	}
+
+	db.AssertExpectations(t)
+}
+
+func TestUserServer_Create_invalidFields(t *testing.T) {
+	v := gentest.RandomUser(5, 5, 8)
+
+	tests := []struct {
+		desc      string
+		user      *pb.User
+		wantField string
+	}{
+		{
+			desc:      "Test if rejects invalid names",
+			user:      &pb.User{Name: "<b>" + v.Name + "</b>", Email: v.Email + "@example.com", Password: v.Password},
+			wantField: "user.name",
+		},
+		{
+			desc:      "Test if rejects invalid emails",
+			user:      &pb.User{Name: v.Name, Email: v.Email + "@localhost", Password: v.Password},
+			wantField: "user.email",
+		},
+		{
+			desc:      "Test if rejects emails of denied domains",
+			user:      &pb.User{Name: v.Name, Email: v.Email + "@mailinator.com", Password: v.Password},
+			wantField: "user.email",
+		},
+		{
+			desc:      "Test if rejects passwords longer than 72 bytes",
+			user:      &pb.User{Name: v.Name, Email: v.Email + "@example.com", Password: strings.Repeat("ż", 37)},
+			wantField: "user.password",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			ctx, shutdown := context.WithCancel(context.Background())
+			defer shutdown()
+
+			db := storagemocks.NewStorage()
+			broker := mocks.NewBroker()
+			policy, err := email.NewPolicy(nil, []string{"mailinator.com"})
+			if err != nil {
+				t.Fatalf("Failed to create email policy: %v", err)
+			}
+
+			client := serve(ctx, server.Dependencies{
+				Users: domain.NewUserManager(domain.Config{EmailPolicy: policy}, domain.Dependencies{
+					Storage: db,
+					Broker:  broker,
+					Tracer:  nulls.NullTracer{},
+				}),
+				Logger: nulls.NullLogger{},
+				Tracer: nulls.NullTracer{},
+			})
+
+			_, err = client.Create(ctx, &pb.CreateUserRequest{User: tt.user})
+
+			st := status.Convert(err)
+			if st.Code() != codes.InvalidArgument {
+				t.Fatalf("UserServer.Create() error code = %v, want %v", st.Code(), codes.InvalidArgument)
+			}
+
+			var fields []string
+			for _, detail := range st.Details() {
+				if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+					for _, violation := range badRequest.GetFieldViolations() {
+						fields = append(fields, violation.GetField())
+					}
+				}
+			}
+
+			if want := []string{tt.wantField}; !cmp.Equal(fields, want) {
+				t.Errorf("UserServer.Create() violated fields = %v, want %v", fields, want)
+			}
+
+			db.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+			broker.AssertNotCalled(t, "ResilientPublish", mock.Anything)
+		})
+	}
+}
+
 func TestUserServer_Update(t *testing.T) {
-	v := gentest.RandomUser(2, 5, 5)
+	v := gentest.RandomUser(5, 5, 5)
 	User := &pb.User{
 		Id:       v.Id,
-		Name:     v.Id,
+		Name:     v.Name,
 		Password: v.Password,
-		Email:    v.Email,
+		Email:    v.Email + "@example.com",
 	}
 
 	tests := []struct {
@@ -261,7 +403,31 @@ func TestUserServer_Update(t *testing.T) {
 			want: &emptypb.Empty{},
 			storage: func() storagemocks.Storage {
 				m := storagemocks.NewStorage()
-				m.On("Update", mock.Anything, mock.AnythingOfType("entity.User")).Return(nil).Once()
+				m.On("Get", mock.Anything, mock.AnythingOfType("filter.Filter"), []string{"password"}).Return(entity.User{Id: User.Id}, nil).Once()
+				m.On("Get", mock.Anything, mock.AnythingOfType("filter.Filter"), []string{"name"}).Return(entity.User{Id: User.Id, Name: User.Name}, nil).Once()
+				m.On("EmailTaken", mock.Anything, User.Email, "", User.Id).Return(false, nil).Once()
+				m.On("Update", mock.Anything, mock.AnythingOfType("entity.User"), mock.AnythingOfType("storage.AuditInfo")).Return(nil).Once()
+				return m
+			}(),
+			broker: func() mocks.Broker {
+				m := mocks.NewBroker()
+				m.On("ResilientPublish", mock.AnythingOfType("event.Event")).Return(nil).Once()
+				return m
+			}(),
+		},
+		{
+			desc: "Test if updates only masked fields",
+			arg: &pb.UpdateUserRequest{
+				User:      User,
+				FieldMask: &fieldmaskpb.FieldMask{Paths: []string{"name"}},
+			},
+			want: &emptypb.Empty{},
+			storage: func() storagemocks.Storage {
+				m := storagemocks.NewStorage()
+				m.On("Get", mock.Anything, mock.AnythingOfType("filter.Filter"), []string{"name"}).Return(entity.User{Id: User.Id, Name: User.Name}, nil).Once()
+				m.On("Update", mock.Anything, mock.MatchedBy(func(user entity.User) bool {
+					return user.Id == User.Id && user.Name == User.Name && user.Email == "" && user.Password == ""
+				}), mock.AnythingOfType("storage.AuditInfo")).Return(nil).Once()
 				return m
 			}(),
 			broker: func() mocks.Broker {
@@ -279,7 +445,10 @@ func TestUserServer_Update(t *testing.T) {
 			wantErr: true,
 			storage: func() storagemocks.Storage {
 				m := storagemocks.NewStorage()
-				m.On("Update", mock.Anything, mock.AnythingOfType("entity.User")).Return(errors.New("test err")).Once()
+				m.On("Get", mock.Anything, mock.AnythingOfType("filter.Filter"), []string{"password"}).Return(entity.User{Id: User.Id}, nil).Once()
+				m.On("Get", mock.Anything, mock.AnythingOfType("filter.Filter"), []string{"name"}).Return(entity.User{Id: User.Id, Name: User.Name}, nil).Once()
+				m.On("EmailTaken", mock.Anything, User.Email, "", User.Id).Return(false, nil).Once()
+				m.On("Update", mock.Anything, mock.AnythingOfType("entity.User"), mock.AnythingOfType("storage.AuditInfo")).Return(errors.New("test err")).Once()
 				return m
 			}(),
 			broker: func() mocks.Broker {
@@ -319,6 +488,28 @@ func TestUserServer_Update(t *testing.T) {
 	}
 }
 
+func TestUserServer_Update_notFound(t *testing.T) {
+	ctx, shutdown := context.WithCancel(context.Background())
+	defer shutdown()
+
+	storage := storagemocks.NewStorage()
+	storage.On("Update", mock.Anything, mock.AnythingOfType("entity.User"), mock.AnythingOfType("storage.AuditInfo")).Return(domain.ErrNotFound).Once()
+	broker := mocks.NewBroker()
+
+	client := setUpServer(ctx, storage, broker)
+
+	_, err := client.Update(ctx, &pb.UpdateUserRequest{
+		User:      &pb.User{Id: "missing", Name: "krixlion"},
+		FieldMask: &fieldmaskpb.FieldMask{Paths: []string{"id"}},
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("UserServer.Update() error code = %v, want %v", status.Code(err), codes.NotFound)
+	}
+
+	storage.AssertExpectations(t)
+	broker.AssertNumberOfCalls(t, "ResilientPublish", 0)
+}
+
 func TestUserServer_Delete(t *testing.T) {
 	v := gentest.RandomUser(2, 5, 5)
 	User := &pb.User{
@@ -329,22 +520,25 @@ func TestUserServer_Delete(t *testing.T) {
 	}
 
 	tests := []struct {
-		desc    string
-		arg     *pb.DeleteUserRequest
-		want    *emptypb.Empty
-		wantErr bool
-		storage storagemocks.Storage
-		broker  mocks.Broker
+		desc        string
+		arg         *pb.DeleteUserRequest
+		want        *emptypb.Empty
+		wantErr     bool
+		wantDeletes int
+		storage     storagemocks.Storage
+		broker      mocks.Broker
 	}{
 		{
 			desc: "Test if response is returned properly on simple request",
 			arg: &pb.DeleteUserRequest{
 				Id: User.Id,
 			},
-			want: &emptypb.Empty{},
+			want:        &emptypb.Empty{},
+			wantDeletes: 1,
 			storage: func() storagemocks.Storage {
 				m := storagemocks.NewStorage()
-				m.On("Delete", mock.Anything, mock.AnythingOfType("string")).Return(nil).Once()
+				m.On("Get", mock.Anything, mock.AnythingOfType("filter.Filter"), mock.Anything).Return(entity.User{Id: User.Id}, nil).Once()
+				m.On("Delete", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("storage.AuditInfo")).Return(nil).Once()
 				return m
 			}(),
 			broker: func() mocks.Broker {
@@ -358,11 +552,31 @@ func TestUserServer_Delete(t *testing.T) {
 			arg: &pb.DeleteUserRequest{
 				Id: User.Id,
 			},
-			want:    nil,
-			wantErr: true,
+			want:        nil,
+			wantErr:     true,
+			wantDeletes: 1,
+			storage: func() storagemocks.Storage {
+				m := storagemocks.NewStorage()
+				m.On("Get", mock.Anything, mock.AnythingOfType("filter.Filter"), mock.Anything).Return(entity.User{Id: User.Id}, nil).Once()
+				m.On("Delete", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("storage.AuditInfo")).Return(errors.New("test err")).Once()
+				return m
+			}(),
+			broker: func() mocks.Broker {
+				m := mocks.NewBroker()
+				m.On("ResilientPublish", mock.AnythingOfType("event.Event")).Return(nil).Once()
+				return m
+			}(),
+		},
+		{
+			desc: "Test if returns OK regardless whether user exists or not",
+			arg: &pb.DeleteUserRequest{
+				Id: User.Id,
+			},
+			want:        &emptypb.Empty{},
+			wantDeletes: 0,
 			storage: func() storagemocks.Storage {
 				m := storagemocks.NewStorage()
-				m.On("Delete", mock.Anything, mock.AnythingOfType("string")).Return(errors.New("test err")).Once()
+				m.On("Get", mock.Anything, mock.AnythingOfType("filter.Filter"), mock.Anything).Return(entity.User{}, storage.ErrNotFound).Once()
 				return m
 			}(),
 			broker: func() mocks.Broker {
@@ -388,9 +602,9 @@ func TestUserServer_Delete(t *testing.T) {
 					return
 				}
 			} else {
-				tt.broker.AssertNumberOfCalls(t, "ResilientPublish", 1)
+				tt.broker.AssertNumberOfCalls(t, "ResilientPublish", tt.wantDeletes)
 			}
-			tt.storage.AssertNumberOfCalls(t, "Delete", 1)
+			tt.storage.AssertNumberOfCalls(t, "Delete", tt.wantDeletes)
 
 			if !cmp.Equal(got, tt.want, cmpopts.IgnoreUnexported(emptypb.Empty{})) {
 				t.Errorf("Wrong response:\n got = %+v\n want = %+v\n", got, tt.want)
@@ -433,7 +647,7 @@ func TestUserServer_GetStream(t *testing.T) {
 			want: pbUsers,
 			storage: func() storagemocks.Storage {
 				m := storagemocks.NewStorage()
-				m.On("GetMultiple", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("filter.Filter")).Return(Users, nil).Once()
+				m.On("GetMultiple", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("storage.FilterGroup"), mock.Anything).Return(Users, nil).Once()
 				return m
 			}(),
 			broker: func() mocks.Broker {
@@ -449,7 +663,7 @@ func TestUserServer_GetStream(t *testing.T) {
 			wantErr: true,
 			storage: func() storagemocks.Storage {
 				m := storagemocks.NewStorage()
-				m.On("GetMultiple", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("filter.Filter")).Return([]entity.User{}, errors.New("test err")).Once()
+				m.On("GetMultiple", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("storage.FilterGroup"), mock.Anything).Return([]entity.User{}, errors.New("test err")).Once()
 				return m
 			}(),
 			broker: func() mocks.Broker {
@@ -488,3 +702,476 @@ func TestUserServer_GetStream(t *testing.T) {
 		})
 	}
 }
+
+func TestUserServer_SearchUsers(t *testing.T) {
+	var users []entity.User
+	var pbUsers []*pb.User
+	for i := 0; i < 3; i++ {
+		v := gentest.RandomUser(2, 5, 5)
+		users = append(users, v)
+		pbUsers = append(pbUsers, &pb.User{
+			Id:   v.Id,
+			Name: v.Name,
+		})
+	}
+
+	tests := []struct {
+		desc    string
+		arg     *pb.SearchUsersRequest
+		want    *pb.SearchUsersResponse
+		wantErr bool
+		storage storagemocks.Storage
+	}{
+		{
+			desc: "Test if returns the last page without next page token",
+			arg: &pb.SearchUsersRequest{
+				Query:    "kri",
+				PageSize: 3,
+			},
+			want: &pb.SearchUsersResponse{
+				Users: pbUsers,
+			},
+			storage: func() storagemocks.Storage {
+				m := storagemocks.NewStorage()
+				m.On("Search", mock.Anything, "kri", uint(0), uint(4)).Return(users, nil).Once()
+				return m
+			}(),
+		},
+		{
+			desc: "Test if returns next page token when there are more results",
+			arg: &pb.SearchUsersRequest{
+				Query:    "kri",
+				PageSize: 2,
+			},
+			want: &pb.SearchUsersResponse{
+				Users:         pbUsers[:2],
+				NextPageToken: "Mg",
+			},
+			storage: func() storagemocks.Storage {
+				m := storagemocks.NewStorage()
+				m.On("Search", mock.Anything, "kri", uint(0), uint(3)).Return(users, nil).Once()
+				return m
+			}(),
+		},
+		{
+			desc: "Test if continues from the offset pointed by page token",
+			arg: &pb.SearchUsersRequest{
+				Query:     "kri",
+				PageSize:  2,
+				PageToken: "Mg",
+			},
+			want: &pb.SearchUsersResponse{
+				Users: pbUsers[2:],
+			},
+			storage: func() storagemocks.Storage {
+				m := storagemocks.NewStorage()
+				m.On("Search", mock.Anything, "kri", uint(2), uint(3)).Return(users[2:], nil).Once()
+				return m
+			}(),
+		},
+		{
+			desc: "Test if fails on invalid page token",
+			arg: &pb.SearchUsersRequest{
+				Query:     "kri",
+				PageToken: "!invalid!",
+			},
+			wantErr: true,
+			storage: storagemocks.NewStorage(),
+		},
+		{
+			desc: "Test if error is returned properly on storage error",
+			arg: &pb.SearchUsersRequest{
+				Query: "kri",
+			},
+			wantErr: true,
+			storage: func() storagemocks.Storage {
+				m := storagemocks.NewStorage()
+				m.On("Search", mock.Anything, "kri", uint(0), uint(21)).Return([]entity.User{}, errors.New("test err")).Once()
+				return m
+			}(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			ctx, shutdown := context.WithCancel(context.Background())
+			defer shutdown()
+			client := setUpServer(ctx, tt.storage, mocks.NewBroker())
+
+			got, err := client.SearchUsers(ctx, tt.arg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("UserServer.SearchUsers() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !cmp.Equal(got, tt.want, cmpopts.IgnoreUnexported(pb.SearchUsersResponse{}, pb.User{})) {
+				t.Errorf("UserServer.SearchUsers():\n got = %+v\n want = %+v\n", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestUserServer_GetStream_InvalidQuery(t *testing.T) {
+	ctx, shutdown := context.WithCancel(context.Background())
+	defer shutdown()
+
+	db := storagemocks.NewStorage()
+	db.On("GetMultiple", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("storage.FilterGroup"), mock.Anything).Return([]entity.User{}, fmt.Errorf("%w: test err", storage.ErrInvalidQuery)).Once()
+
+	client := setUpServer(ctx, db, mocks.NewBroker())
+
+	stream, err := client.GetStream(ctx, &pb.GetUsersRequest{})
+	if err != nil {
+		t.Errorf("Failed to Get stream, err: %v", err)
+		return
+	}
+
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("UserServer.GetStream() error code = %v, want %v", status.Code(err), codes.InvalidArgument)
+	}
+}
+
+func TestUserServer_WatchUsers(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      *pb.WatchUsersRequest
+		want     *pb.UserChange
+		wantCode codes.Code
+	}{
+		{
+			name: "Test if sends changes of watched users",
+			req:  &pb.WatchUsersRequest{UserIds: []string{"id"}, Types: []pb.UserChange_Type{pb.UserChange_DELETED}},
+			want: &pb.UserChange{Type: pb.UserChange_DELETED, User: &pb.User{Id: "id"}},
+		},
+		{
+			name:     "Test if fails on invalid change types",
+			req:      &pb.WatchUsersRequest{Types: []pb.UserChange_Type{pb.UserChange_TYPE_UNSPECIFIED}},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "Test if fails on malformed tokens",
+			req:      &pb.WatchUsersRequest{Since: "not a token"},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			watches := watch.New(watch.Config{BufferSize: 10, HistorySize: 10, HeartbeatInterval: time.Hour}, watch.Dependencies{Logger: nulls.NullLogger{}})
+			client := serve(ctx, server.Dependencies{
+				Watches: watches,
+				Logger:  nulls.NullLogger{},
+				Tracer:  nulls.NullTracer{},
+			})
+
+			stream, err := client.WatchUsers(ctx, tt.req)
+			if err != nil {
+				t.Fatalf("Failed to call WatchUsers(): %v", err)
+			}
+
+			if tt.want != nil {
+				// Keep publishing, since changes made before the server subscribes are not sent.
+				go func() {
+					for ctx.Err() == nil {
+						e, err := event.MakeEvent(event.UserAggregate, event.UserDeleted, "id")
+						if err != nil {
+							return
+						}
+						watches.Handle(e)
+						time.Sleep(time.Millisecond * 10)
+					}
+				}()
+			}
+
+			resp, err := stream.Recv()
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("WatchUsers() code = %v, want %v, err = %v", code, tt.wantCode, err)
+			}
+
+			if tt.want == nil {
+				return
+			}
+
+			got := resp.GetChange()
+			got.Time = nil
+			if !cmp.Equal(got, tt.want, cmpopts.IgnoreUnexported(pb.UserChange{}, pb.User{})) {
+				t.Errorf("WatchUsers() change:\n got = %v\n want = %v", got, tt.want)
+			}
+
+			if resp.GetToken() == "" {
+				t.Errorf("WatchUsers() token is empty")
+			}
+		})
+	}
+}
+
+func TestUserServer_ListAuditEntries(t *testing.T) {
+	createdAt := time.Date(2026, 10, 19, 14, 0, 0, 0, time.UTC)
+	id := uuid.Must(uuid.NewV4()).String()
+
+	var entries []entity.AuditEntry
+	var pbEntries []*pb.AuditEntry
+	for _, action := range []entity.AuditAction{entity.AuditCreate, entity.AuditUpdate, entity.AuditDelete} {
+		entries = append(entries, entity.AuditEntry{
+			Id:        string(action),
+			UserId:    id,
+			Actor:     "user:1",
+			Action:    action,
+			Changes:   []entity.FieldChange{{Field: "password", Old: storage.Redacted, New: storage.Redacted}},
+			RequestId: "request",
+			CreatedAt: createdAt,
+		})
+		pbEntries = append(pbEntries, &pb.AuditEntry{
+			Id:         string(action),
+			UserId:     id,
+			Actor:      "user:1",
+			Action:     string(action),
+			Changes:    []*pb.FieldChange{{Field: "password", OldValue: storage.Redacted, NewValue: storage.Redacted}},
+			RequestId:  "request",
+			CreateTime: timestamppb.New(createdAt),
+		})
+	}
+
+	tests := []struct {
+		desc    string
+		arg     *pb.ListAuditEntriesRequest
+		want    *pb.ListAuditEntriesResponse
+		wantErr codes.Code
+		storage storagemocks.Storage
+	}{
+		{
+			desc: "Test if returns entries within the time range",
+			arg: &pb.ListAuditEntriesRequest{
+				UserId:    id,
+				StartTime: timestamppb.New(createdAt.Add(-time.Hour)),
+				EndTime:   timestamppb.New(createdAt.Add(time.Hour)),
+				PageSize:  3,
+			},
+			want: &pb.ListAuditEntriesResponse{
+				Entries: pbEntries,
+			},
+			storage: func() storagemocks.Storage {
+				m := storagemocks.NewStorage()
+				m.On("ListAuditEntries", mock.Anything, id, createdAt.Add(-time.Hour), createdAt.Add(time.Hour), uint(0), uint(4)).Return(entries, nil).Once()
+				return m
+			}(),
+		},
+		{
+			desc: "Test if returns next page token when there are more entries",
+			arg: &pb.ListAuditEntriesRequest{
+				UserId:   id,
+				PageSize: 2,
+			},
+			want: &pb.ListAuditEntriesResponse{
+				Entries:       pbEntries[:2],
+				NextPageToken: "Mg",
+			},
+			storage: func() storagemocks.Storage {
+				m := storagemocks.NewStorage()
+				m.On("ListAuditEntries", mock.Anything, id, time.Time{}, time.Time{}, uint(0), uint(3)).Return(entries, nil).Once()
+				return m
+			}(),
+		},
+		{
+			desc: "Test if fails when start time is not before end time",
+			arg: &pb.ListAuditEntriesRequest{
+				UserId:    id,
+				StartTime: timestamppb.New(createdAt),
+				EndTime:   timestamppb.New(createdAt),
+			},
+			wantErr: codes.InvalidArgument,
+			storage: storagemocks.NewStorage(),
+		},
+		{
+			desc: "Test if fails on invalid page token",
+			arg: &pb.ListAuditEntriesRequest{
+				UserId:    id,
+				PageToken: "!invalid!",
+			},
+			wantErr: codes.InvalidArgument,
+			storage: storagemocks.NewStorage(),
+		},
+		{
+			desc: "Test if error is returned properly on storage error",
+			arg: &pb.ListAuditEntriesRequest{
+				UserId: id,
+			},
+			wantErr: codes.Internal,
+			storage: func() storagemocks.Storage {
+				m := storagemocks.NewStorage()
+				m.On("ListAuditEntries", mock.Anything, id, time.Time{}, time.Time{}, uint(0), uint(21)).Return([]entity.AuditEntry{}, errors.New("test err")).Once()
+				return m
+			}(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			ctx, shutdown := context.WithCancel(context.Background())
+			defer shutdown()
+			client := setUpServer(ctx, tt.storage, mocks.NewBroker())
+
+			got, err := client.ListAuditEntries(ctx, tt.arg)
+			if status.Code(err) != tt.wantErr {
+				t.Errorf("UserServer.ListAuditEntries() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !cmp.Equal(got, tt.want, cmpopts.IgnoreUnexported(pb.ListAuditEntriesResponse{}, pb.AuditEntry{}, pb.FieldChange{}, timestamppb.Timestamp{})) {
+				t.Errorf("UserServer.ListAuditEntries():\n got = %+v\n want = %+v\n", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestUserServer_Delete_auditInfo(t *testing.T) {
+	ctx, shutdown := context.WithCancel(context.Background())
+	defer shutdown()
+
+	id := uuid.Must(uuid.NewV4()).String()
+	db := storagemocks.NewStorage()
+	db.On("Get", mock.Anything, mock.AnythingOfType("filter.Filter"), mock.Anything).Return(entity.User{Id: id}, nil).Once()
+	db.On("Delete", mock.Anything, id, mock.MatchedBy(func(audit storage.AuditInfo) bool {
+		return audit.Actor == "user:1" && audit.RequestId == "request"
+	})).Return(nil).Once()
+
+	broker := mocks.NewBroker()
+	broker.On("ResilientPublish", mock.AnythingOfType("event.Event")).Return(nil)
+
+	client := serve(ctx, server.Dependencies{
+		Users: domain.NewUserManager(domain.Config{}, domain.Dependencies{
+			Storage: db,
+			Broker:  broker,
+			Tracer:  nulls.NullTracer{},
+		}),
+		Logger: nulls.NullLogger{},
+		Tracer: nulls.NullTracer{},
+		Config: server.Config{
+			Actor: func(context.Context) string { return "user:1" },
+		},
+	})
+
+	ctx = metadata.AppendToOutgoingContext(ctx, server.RequestIdMetadataKey, "request")
+	if _, err := client.Delete(ctx, &pb.DeleteUserRequest{Id: id}); err != nil {
+		t.Fatalf("UserServer.Delete() error = %v", err)
+	}
+
+	db.AssertExpectations(t)
+}
+
+func TestUserServer_Delete_untrustedActor(t *testing.T) {
+	ctx, shutdown := context.WithCancel(context.Background())
+	defer shutdown()
+
+	id := uuid.Must(uuid.NewV4()).String()
+	db := storagemocks.NewStorage()
+	db.On("Get", mock.Anything, mock.AnythingOfType("filter.Filter"), mock.Anything).Return(entity.User{Id: id}, nil).Once()
+	db.On("Delete", mock.Anything, id, mock.MatchedBy(func(audit storage.AuditInfo) bool {
+		return audit.Actor == "peer:bufconn"
+	})).Return(nil).Once()
+
+	broker := mocks.NewBroker()
+	broker.On("ResilientPublish", mock.AnythingOfType("event.Event")).Return(nil)
+
+	client := serve(ctx, server.Dependencies{
+		Users: domain.NewUserManager(domain.Config{}, domain.Dependencies{
+			Storage: db,
+			Broker:  broker,
+			Tracer:  nulls.NullTracer{},
+		}),
+		Logger: nulls.NullLogger{},
+		Tracer: nulls.NullTracer{},
+		Config: server.Config{
+			Actor: ratelimit.UserKey("x-user-id", "gateway"),
+		},
+	})
+
+	// Clients other than the trusted gateway cannot act as users.
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-user-id", "1")
+	if _, err := client.Delete(ctx, &pb.DeleteUserRequest{Id: id}); err != nil {
+		t.Fatalf("UserServer.Delete() error = %v", err)
+	}
+
+	db.AssertExpectations(t)
+}
+
+func TestUserServer_ExportUserData(t *testing.T) {
+	ctx, shutdown := context.WithCancel(context.Background())
+	defer shutdown()
+
+	v := gentest.RandomUser(2, 5, 5)
+	db := storagemocks.NewStorage()
+	db.On("Get", mock.Anything, mock.AnythingOfType("filter.Filter"), []string(nil)).Return(v, nil).Once()
+	db.On("ListArticleIds", mock.Anything, v.Id).Return([]string{"article"}, nil).Once()
+	db.On("ListAuditEntries", mock.Anything, v.Id, time.Time{}, time.Time{}, uint(0), mock.AnythingOfType("uint")).Return([]entity.AuditEntry{{UserId: v.Id, Action: entity.AuditCreate}}, nil).Once()
+
+	client := setUpServer(ctx, db, mocks.NewBroker())
+
+	got, err := client.ExportUserData(ctx, &pb.ExportUserDataRequest{Id: v.Id})
+	if err != nil {
+		t.Fatalf("UserServer.ExportUserData() error = %v", err)
+	}
+
+	var export entity.UserExport
+	if err := json.Unmarshal(got.GetDocument(), &export); err != nil {
+		t.Fatalf("UserServer.ExportUserData() returned invalid JSON: %v", err)
+	}
+
+	if export.User.Id != v.Id || export.User.Email != v.Email || export.User.Password != "" {
+		t.Errorf("UserServer.ExportUserData() user = %+v, want %+v without the password", export.User, v)
+	}
+
+	if len(export.ArticleIds) != 1 || len(export.AuditEntries) != 1 {
+		t.Errorf("UserServer.ExportUserData() = %+v, want the article and the audit entry", export)
+	}
+}
+
+func TestUserServer_EraseUser(t *testing.T) {
+	id := uuid.Must(uuid.NewV4()).String()
+
+	tests := []struct {
+		desc        string
+		wantErr     codes.Code
+		wantPublish int
+		storage     storagemocks.Storage
+	}{
+		{
+			desc:        "Test if erases the user",
+			wantPublish: 1,
+			storage: func() storagemocks.Storage {
+				m := storagemocks.NewStorage()
+				m.On("Erase", mock.Anything, id, domain.Tombstone(id), mock.AnythingOfType("storage.AuditInfo")).Return(nil).Once()
+				return m
+			}(),
+		},
+		{
+			desc:    "Test if fails on users which do not exist",
+			wantErr: codes.NotFound,
+			storage: func() storagemocks.Storage {
+				m := storagemocks.NewStorage()
+				m.On("Erase", mock.Anything, id, domain.Tombstone(id), mock.AnythingOfType("storage.AuditInfo")).Return(storage.ErrNotFound).Once()
+				return m
+			}(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			ctx, shutdown := context.WithCancel(context.Background())
+			defer shutdown()
+
+			broker := mocks.NewBroker()
+			broker.On("ResilientPublish", mock.AnythingOfType("event.Event")).Return(nil)
+			client := setUpServer(ctx, tt.storage, broker)
+
+			_, err := client.EraseUser(ctx, &pb.EraseUserRequest{Id: id})
+			if status.Code(err) != tt.wantErr {
+				t.Errorf("UserServer.EraseUser() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			broker.AssertNumberOfCalls(t, "ResilientPublish", tt.wantPublish)
+		})
+	}
+}
@@ -1,8 +1,12 @@
 package server
 
 import (
+	"fmt"
+
 	"github.com/krixlion/dev_forum-user/pkg/entity"
 	pb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func userFromPB(v *pb.User) entity.User {
@@ -44,3 +48,86 @@ func mapUserFields(s string) string {
 		return s
 	}
 }
+
+// publicUserFields lists fields which can be read without any authorization.
+var publicUserFields = map[string]bool{
+	"id":         true,
+	"name":       true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// secretUserFields lists fields which can be read by authorized services.
+var secretUserFields = map[string]bool{
+	"id":         true,
+	"name":       true,
+	"email":      true,
+	"password":   true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// defaultPublicUserFields are returned when no read mask is provided.
+var defaultPublicUserFields = []string{"id", "name"}
+
+// readFields returns fields listed in the mask or defaults if the mask is empty.
+// Returns a non-nil error if the mask contains a field which is not allowed.
+func readFields(mask *fieldmaskpb.FieldMask, allowed map[string]bool, defaults []string) ([]string, error) {
+	paths := mask.GetPaths()
+	if len(paths) == 0 {
+		return defaults, nil
+	}
+
+	fields := make([]string, 0, len(paths))
+	seen := make(map[string]bool, len(paths))
+
+	for _, path := range paths {
+		if !allowed[path] {
+			return nil, fmt.Errorf("field %q cannot be read", path)
+		}
+
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		fields = append(fields, path)
+	}
+
+	return fields, nil
+}
+
+// userToPB returns pb.User with only the given fields set
+// or with all of them set if no fields are given.
+func userToPB(v entity.User, fields []string) *pb.User {
+	if len(fields) == 0 {
+		return &pb.User{
+			Id:        v.Id,
+			Name:      v.Name,
+			Password:  v.Password,
+			Email:     v.Email,
+			CreatedAt: timestamppb.New(v.CreatedAt),
+			UpdatedAt: timestamppb.New(v.UpdatedAt),
+		}
+	}
+
+	user := &pb.User{}
+	for _, field := range fields {
+		switch field {
+		case "id":
+			user.Id = v.Id
+		case "name":
+			user.Name = v.Name
+		case "password":
+			user.Password = v.Password
+		case "email":
+			user.Email = v.Email
+		case "created_at":
+			user.CreatedAt = timestamppb.New(v.CreatedAt)
+		case "updated_at":
+			user.UpdatedAt = timestamppb.New(v.UpdatedAt)
+		}
+	}
+
+	return user
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/krixlion/dev_forum-user/pkg/entity"
 	pb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -45,3 +47,97 @@ func Test_userFromPB(t *testing.T) {
 		})
 	}
 }
+
+func Test_readFields(t *testing.T) {
+	tests := []struct {
+		desc    string
+		mask    *fieldmaskpb.FieldMask
+		allowed map[string]bool
+		want    []string
+		wantErr bool
+	}{
+		{
+			desc:    "Test if returns defaults on empty mask",
+			mask:    nil,
+			allowed: publicUserFields,
+			want:    defaultPublicUserFields,
+		},
+		{
+			desc:    "Test if returns deduplicated fields from the mask",
+			mask:    &fieldmaskpb.FieldMask{Paths: []string{"id", "created_at", "id"}},
+			allowed: publicUserFields,
+			want:    []string{"id", "created_at"},
+		},
+		{
+			desc:    "Test if fails on a field which is not allowed",
+			mask:    &fieldmaskpb.FieldMask{Paths: []string{"id", "password"}},
+			allowed: publicUserFields,
+			wantErr: true,
+		},
+		{
+			desc:    "Test if fails on a nested field",
+			mask:    &fieldmaskpb.FieldMask{Paths: []string{"created_at.seconds"}},
+			allowed: secretUserFields,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := readFields(tt.mask, tt.allowed, defaultPublicUserFields)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("readFields() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !cmp.Equal(got, tt.want) {
+				t.Errorf("readFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_userToPB(t *testing.T) {
+	now := time.Now()
+	user := entity.User{
+		Id:        "erofjigjbefkdw",
+		Name:      "fwqavds",
+		Email:     "ad@asda.pl",
+		Password:  "poekmfvwes!234",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	tests := []struct {
+		desc   string
+		fields []string
+		want   *pb.User
+	}{
+		{
+			desc:   "Test if sets all fields when none are given",
+			fields: nil,
+			want: &pb.User{
+				Id:        user.Id,
+				Name:      user.Name,
+				Email:     user.Email,
+				Password:  user.Password,
+				CreatedAt: timestamppb.New(now),
+				UpdatedAt: timestamppb.New(now),
+			},
+		},
+		{
+			desc:   "Test if sets only the given fields",
+			fields: []string{"id", "created_at"},
+			want: &pb.User{
+				Id:        user.Id,
+				CreatedAt: timestamppb.New(now),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := userToPB(user, tt.fields); !proto.Equal(got, tt.want) {
+				t.Errorf("userToPB() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
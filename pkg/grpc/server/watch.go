@@ -0,0 +1,74 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-user/pkg/domain"
+	pb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
+	"github.com/krixlion/dev_forum-user/pkg/watch"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var changeTypes = map[pb.UserChange_Type]event.EventType{
+	pb.UserChange_CREATED: event.UserCreated,
+	pb.UserChange_UPDATED: event.UserUpdated,
+	pb.UserChange_DELETED: event.UserDeleted,
+	pb.UserChange_ERASED:  domain.UserErased,
+}
+
+func watchFilterFromPB(req *pb.WatchUsersRequest) (watch.Filter, error) {
+	filter := watch.Filter{
+		UserIds: req.GetUserIds(),
+	}
+
+	for _, v := range req.GetTypes() {
+		eType, ok := changeTypes[v]
+		if !ok {
+			return watch.Filter{}, fmt.Errorf("invalid change type %q", v)
+		}
+		filter.Types = append(filter.Types, eType)
+	}
+
+	return filter, nil
+}
+
+func notificationToPB(n watch.Notification) *pb.WatchUsersResponse {
+	if n.Change == nil {
+		return &pb.WatchUsersResponse{
+			Token: n.Token,
+			Message: &pb.WatchUsersResponse_Heartbeat{
+				Heartbeat: &pb.Heartbeat{Time: timestamppb.New(n.Time)},
+			},
+		}
+	}
+
+	user := &pb.User{
+		Id:   n.Change.User.Id,
+		Name: n.Change.User.Name,
+	}
+
+	if !n.Change.User.CreatedAt.IsZero() {
+		user.CreatedAt = timestamppb.New(n.Change.User.CreatedAt)
+	}
+
+	if !n.Change.User.UpdatedAt.IsZero() {
+		user.UpdatedAt = timestamppb.New(n.Change.User.UpdatedAt)
+	}
+
+	change := &pb.UserChange{
+		User: user,
+		Time: timestamppb.New(n.Change.Time),
+	}
+
+	for pbType, eType := range changeTypes {
+		if eType == n.Change.Type {
+			change.Type = pbType
+		}
+	}
+
+	return &pb.WatchUsersResponse{
+		Token:   n.Token,
+		Message: &pb.WatchUsersResponse_Change{Change: change},
+	}
+}
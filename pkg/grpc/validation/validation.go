@@ -0,0 +1,242 @@
+// Package validation enforces the validation rules declared
+// with the (user.rules) field option in the service's proto files.
+package validation
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/gofrs/uuid"
+	pb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// UnaryServerInterceptor rejects requests violating their rules with InvalidArgument
+// and BadRequest details listing all violations.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := validateRequest(req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor validates every message received from the client
+// just like UnaryServerInterceptor before the handler gets it.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, validatingStream{ServerStream: ss})
+	}
+}
+
+type validatingStream struct {
+	grpc.ServerStream
+}
+
+func (s validatingStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return validateRequest(m)
+}
+
+func validateRequest(req interface{}) error {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return nil
+	}
+
+	violations := Validate(msg)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	st := status.New(codes.InvalidArgument, fmt.Sprintf("Invalid %s: %s", violations[0].GetField(), violations[0].GetDescription()))
+	detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if err != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
+
+// Validate returns violations of the rules declared on the message's fields
+// and on the fields of all messages set within it.
+func Validate(msg proto.Message) []*errdetails.BadRequest_FieldViolation {
+	v := validator{}
+	v.message("", msg.ProtoReflect(), nil)
+	return v.violations
+}
+
+type validator struct {
+	violations []*errdetails.BadRequest_FieldViolation
+}
+
+func (v *validator) violate(path, format string, args ...interface{}) {
+	v.violations = append(v.violations, &errdetails.BadRequest_FieldViolation{
+		Field:       path,
+		Description: fmt.Sprintf(format, args...),
+	})
+}
+
+// message validates fields of the message, only those named in masked unless it's empty.
+func (v *validator) message(prefix string, msg protoreflect.Message, masked map[string]bool) {
+	fields := msg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		path := prefix + string(field.Name())
+
+		if len(masked) > 0 && !masked[string(field.Name())] {
+			continue
+		}
+
+		// Members of unset oneofs are not validated.
+		if oneof := field.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() && msg.WhichOneof(oneof) != field {
+			continue
+		}
+
+		rules, _ := proto.GetExtension(field.Options(), pb.E_Rules).(*pb.FieldRules)
+		v.field(path, field, msg, rules)
+	}
+}
+
+func (v *validator) field(path string, field protoreflect.FieldDescriptor, msg protoreflect.Message, rules *pb.FieldRules) {
+	value := msg.Get(field)
+
+	switch {
+	case field.IsList():
+		list := value.List()
+		if max := rules.GetRepeated().GetMaxItems(); max > 0 && uint64(list.Len()) > max {
+			v.violate(path, "must contain at most %d items", max)
+		}
+
+		if field.Kind() == protoreflect.MessageKind || field.Kind() == protoreflect.GroupKind {
+			for i := 0; i < list.Len(); i++ {
+				v.message(fmt.Sprintf("%s[%d].", path, i), list.Get(i).Message(), nil)
+			}
+		}
+
+	case field.IsMap():
+		// Maps have no rules.
+
+	case field.Kind() == protoreflect.MessageKind || field.Kind() == protoreflect.GroupKind:
+		if !msg.Has(field) {
+			if rules.GetRequired() {
+				v.violate(path, "is required")
+			}
+			return
+		}
+		v.message(path+".", value.Message(), maskedFields(msg, rules.GetMask()))
+
+	case field.Kind() == protoreflect.StringKind:
+		v.string(path, value.String(), rules)
+	}
+}
+
+func (v *validator) string(path, value string, rules *pb.FieldRules) {
+	if rules == nil {
+		return
+	}
+
+	if value == "" && rules.GetRequired() {
+		v.violate(path, "is required")
+		return
+	}
+
+	s := rules.GetString_()
+	if s == nil {
+		return
+	}
+
+	length := uint64(utf8.RuneCountInString(value))
+	if s.GetMinLen() > 0 && length < s.GetMinLen() {
+		v.violate(path, "must be at least %d characters long", s.GetMinLen())
+	}
+
+	if s.GetMaxLen() > 0 && length > s.GetMaxLen() {
+		v.violate(path, "must be at most %d characters long", s.GetMaxLen())
+	}
+
+	if s.GetEmail() && !isEmail(value) {
+		v.violate(path, "must be a valid email address")
+	}
+
+	if s.GetUuid() && !isUUID(value) {
+		v.violate(path, "must be a valid UUID")
+	}
+
+	if s.GetPattern() != "" {
+		re, err := compile(s.GetPattern())
+		if err != nil {
+			v.violate(path, "has an invalid pattern: %v", err)
+		} else if !re.MatchString(value) {
+			v.violate(path, "must match %q", s.GetPattern())
+		}
+	}
+}
+
+// maskedFields returns names of the fields whose paths are listed in the msg's FieldMask field
+// of the given name, eg. name of "name" or "name.first", or nil if there's no such mask.
+func maskedFields(msg protoreflect.Message, name string) map[string]bool {
+	if name == "" {
+		return nil
+	}
+
+	field := msg.Descriptor().Fields().ByName(protoreflect.Name(name))
+	if field == nil || field.Message() == nil || field.Message().FullName() != "google.protobuf.FieldMask" {
+		return nil
+	}
+
+	mask, ok := msg.Get(field).Message().Interface().(*fieldmaskpb.FieldMask)
+	if !ok {
+		return nil
+	}
+
+	masked := make(map[string]bool, len(mask.GetPaths()))
+	for _, path := range mask.GetPaths() {
+		name, _, _ := strings.Cut(path, ".")
+		masked[name] = true
+	}
+	return masked
+}
+
+// isEmail reports whether the value is an email address, possibly with a display name,
+// which the domain strips when canonicalizing it.
+func isEmail(value string) bool {
+	_, err := mail.ParseAddress(value)
+	return err == nil
+}
+
+func isUUID(value string) bool {
+	id, err := uuid.FromString(value)
+	return err == nil && id.String() == value
+}
+
+var patterns sync.Map
+
+// compile caches compiled patterns since rules are static.
+func compile(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns.Store(pattern, re)
+	return re, nil
+}
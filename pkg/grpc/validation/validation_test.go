@@ -0,0 +1,226 @@
+package validation
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	pb "github.com/krixlion/dev_forum-user/pkg/grpc/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+const testId = "b7c8e7bb-6a3f-4a4a-bd3a-0f0e6fe5f6f4"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  proto.Message
+		want []string
+	}{
+		{
+			name: "Test if accepts a valid user",
+			msg: &pb.CreateUserRequest{
+				User: &pb.User{Name: "krixlion", Email: "krixlion@example.com", Password: "12345678"},
+			},
+		},
+		{
+			name: "Test if requires the user",
+			msg:  &pb.CreateUserRequest{},
+			want: []string{"user"},
+		},
+		{
+			name: "Test if validates nested messages",
+			msg: &pb.CreateUserRequest{
+				User: &pb.User{Name: "", Email: "invalid email", Password: "1234567"},
+			},
+			want: []string{"user.name", "user.email", "user.password"},
+		},
+		{
+			name: "Test if accepts emails with a display name",
+			msg: &pb.CreateUserRequest{
+				User: &pb.User{Name: "krixlion", Email: "Krix <krixlion@example.com>", Password: "12345678"},
+			},
+		},
+		{
+			name: "Test if counts characters instead of bytes",
+			msg: &pb.CreateUserRequest{
+				User: &pb.User{Name: strings.Repeat("ż", 64), Email: "krixlion@example.com", Password: "12345678"},
+			},
+		},
+		{
+			name: "Test if validates only masked fields",
+			msg: &pb.UpdateUserRequest{
+				User:      &pb.User{Id: testId, Email: "invalid email"},
+				FieldMask: &fieldmaskpb.FieldMask{Paths: []string{"email"}},
+			},
+			want: []string{"user.email"},
+		},
+		{
+			name: "Test if validates all fields without a mask",
+			msg: &pb.UpdateUserRequest{
+				User: &pb.User{Id: testId, Email: "krixlion@example.com"},
+			},
+			want: []string{"user.name", "user.password"},
+		},
+		{
+			name: "Test if rejects ids which are not UUIDs",
+			msg:  &pb.DeleteUserRequest{Id: "1"},
+			want: []string{"id"},
+		},
+		{
+			name: "Test if accepts UUIDs",
+			msg:  &pb.DeleteUserRequest{Id: testId},
+		},
+		{
+			name: "Test if validates only the set oneof member",
+			msg:  &pb.GetUserSecretRequest{Query: &pb.GetUserSecretRequest_Id{Id: testId}},
+		},
+		{
+			name: "Test if validates the set oneof member",
+			msg:  &pb.GetUserSecretRequest{Query: &pb.GetUserSecretRequest_Email{Email: "invalid email"}},
+			want: []string{"email"},
+		},
+		{
+			name: "Test if matches patterns",
+			msg:  &pb.GetUsersRequest{Offset: "-1", Limit: "ten"},
+			want: []string{"offset", "limit"},
+		},
+		{
+			name: "Test if rejects blank search queries",
+			msg:  &pb.SearchUsersRequest{Query: "  "},
+			want: []string{"query"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, violation := range Validate(tt.msg) {
+				got = append(got, violation.GetField())
+			}
+
+			if !cmp.Equal(got, tt.want) {
+				t.Errorf("Validate():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         interface{}
+		wantHandled bool
+		wantCode    codes.Code
+		wantFields  []string
+	}{
+		{
+			name:        "Test if passes valid requests to the handler",
+			req:         &pb.DeleteUserRequest{Id: testId},
+			wantHandled: true,
+		},
+		{
+			name:       "Test if rejects invalid requests with field violations",
+			req:        &pb.DeleteUserRequest{Id: "1"},
+			wantCode:   codes.InvalidArgument,
+			wantFields: []string{"id"},
+		},
+		{
+			name:        "Test if passes through requests which are not proto messages",
+			req:         "request",
+			wantHandled: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handled := false
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				handled = true
+				return nil, nil
+			}
+
+			_, err := UnaryServerInterceptor()(context.Background(), tt.req, &grpc.UnaryServerInfo{}, handler)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("UnaryServerInterceptor() code = %v, want %v", code, tt.wantCode)
+			}
+
+			if handled != tt.wantHandled {
+				t.Errorf("UnaryServerInterceptor() handled = %v, want %v", handled, tt.wantHandled)
+			}
+
+			var gotFields []string
+			for _, detail := range status.Convert(err).Details() {
+				if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+					for _, violation := range badRequest.GetFieldViolations() {
+						gotFields = append(gotFields, violation.GetField())
+					}
+				}
+			}
+
+			if !cmp.Equal(gotFields, tt.wantFields) {
+				t.Errorf("UnaryServerInterceptor() violations:\n got = %v\n want = %v", gotFields, tt.wantFields)
+			}
+		})
+	}
+}
+
+// stubServerStream receives the request once.
+type stubServerStream struct {
+	grpc.ServerStream
+	req proto.Message
+}
+
+func (s stubServerStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s stubServerStream) RecvMsg(m interface{}) error {
+	proto.Merge(m.(proto.Message), s.req)
+	return nil
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         *pb.GetUsersRequest
+		wantHandled bool
+		wantCode    codes.Code
+	}{
+		{
+			name:        "Test if passes valid requests to the handler",
+			req:         &pb.GetUsersRequest{Limit: "10"},
+			wantHandled: true,
+		},
+		{
+			name:     "Test if rejects invalid requests",
+			req:      &pb.GetUsersRequest{Limit: "invalid"},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handled := false
+			handler := func(srv interface{}, stream grpc.ServerStream) error {
+				if err := stream.RecvMsg(&pb.GetUsersRequest{}); err != nil {
+					return err
+				}
+				handled = true
+				return nil
+			}
+
+			err := StreamServerInterceptor()(nil, stubServerStream{req: tt.req}, &grpc.StreamServerInfo{}, handler)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("StreamServerInterceptor() code = %v, want %v", code, tt.wantCode)
+			}
+
+			if handled != tt.wantHandled {
+				t.Errorf("StreamServerInterceptor() handled = %v, want %v", handled, tt.wantHandled)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/logging"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// Check returns a non-nil error if the dependency it probes is unavailable.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	service string
+	name    string
+	check   Check
+}
+
+// Checker periodically runs registered checks and reports their results
+// through the standard gRPC health service and HTTP probe endpoints.
+// Every service is SERVING only if all of its checks pass.
+// The overall status, reported for an empty service name, is SERVING only if all services are.
+type Checker struct {
+	server   *health.Server
+	interval time.Duration
+	timeout  time.Duration
+	logger   logging.Logger
+
+	mu       sync.RWMutex
+	checks   []namedCheck
+	results  map[string]error // Latest result of each check keyed by the check name.
+	services map[string]struct{}
+}
+
+// NewChecker returns a Checker running checks every interval, each limited by the given timeout.
+func NewChecker(interval, timeout time.Duration, logger logging.Logger) *Checker {
+	server := health.NewServer()
+	// Report NOT_SERVING until the first round of checks completes.
+	server.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	return &Checker{
+		server:   server,
+		interval: interval,
+		timeout:  timeout,
+		logger:   logger,
+		results:  make(map[string]error),
+		services: make(map[string]struct{}),
+	}
+}
+
+// Server returns the gRPC health server to be registered with
+// healthpb.RegisterHealthServer.
+func (c *Checker) Server() healthpb.HealthServer {
+	return c.server
+}
+
+// AddCheck registers a check affecting the status of the given service.
+// Check names should be unique across all services.
+func (c *Checker) AddCheck(service, name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks = append(c.checks, namedCheck{service: service, name: name, check: check})
+
+	if _, ok := c.services[service]; !ok {
+		c.services[service] = struct{}{}
+		c.server.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+}
+
+// Run runs all checks immediately and then once every interval.
+// It blocks until the context is cancelled.
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.runChecks(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Shutdown sets all services to NOT_SERVING and ignores any further check results.
+// It should be called before the servers are gracefully stopped so that
+// clients and load balancers stop sending new requests.
+func (c *Checker) Shutdown() {
+	c.server.Shutdown()
+}
+
+func (c *Checker) runChecks(ctx context.Context) {
+	c.mu.RLock()
+	checks := make([]namedCheck, len(c.checks))
+	copy(checks, c.checks)
+	c.mu.RUnlock()
+
+	failing := make(map[string]bool)
+	results := make(map[string]error, len(checks))
+
+	for _, check := range checks {
+		err := c.runCheck(ctx, check.check)
+		results[check.name] = err
+
+		if err != nil {
+			failing[check.service] = true
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name, err := range results {
+		prevErr := c.results[name]
+
+		switch {
+		case err != nil && prevErr == nil:
+			c.logger.Log(ctx, "Health check failing", "check", name, "err", err)
+		case err == nil && prevErr != nil:
+			c.logger.Log(ctx, "Health check recovered", "check", name)
+		}
+	}
+	c.results = results
+
+	overall := healthpb.HealthCheckResponse_SERVING
+	for service := range c.services {
+		status := healthpb.HealthCheckResponse_SERVING
+		if failing[service] {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+			overall = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		c.server.SetServingStatus(service, status)
+	}
+	c.server.SetServingStatus("", overall)
+}
+
+func (c *Checker) runCheck(ctx context.Context, check Check) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	return check(ctx)
+}
+
+// LivenessHandler responds with 200 as long as the process is able to serve HTTP.
+// It does not depend on any checks since restarting the process
+// would not fix an unavailable dependency.
+func (c *Checker) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+}
+
+type readinessResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// ReadinessHandler responds with 200 if the service given in the "service"
+// query param is SERVING and with 503 otherwise. The overall status is
+// reported if the param is empty. Unknown services result in 404.
+// Checks are reported only as "ok" or "failing", their errors are logged by Run.
+func (c *Checker) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := c.server.Check(r.Context(), &healthpb.HealthCheckRequest{Service: r.URL.Query().Get("service")})
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				http.Error(w, "unknown service", http.StatusNotFound)
+				return
+			}
+			c.logger.Log(r.Context(), "Failed to check health", "err", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		body := readinessResponse{
+			Status: resp.GetStatus().String(),
+			Checks: make(map[string]string),
+		}
+
+		c.mu.RLock()
+		for name, err := range c.results {
+			body.Checks[name] = "ok"
+			if err != nil {
+				body.Checks[name] = "failing"
+			}
+		}
+		c.mu.RUnlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(body)
+	})
+}
+
+// Handler returns a mux serving LivenessHandler on /livez and ReadinessHandler on /readyz.
+func (c *Checker) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/livez", c.LivenessHandler())
+	mux.Handle("/readyz", c.ReadinessHandler())
+	return mux
+}
+
+// AMQPCheck returns a Check verifying that an AMQP connection to the broker
+// at the given URL is open. The connection is kept open between checks
+// and redialed only once the broker closes it, so that the check reflects
+// whether the broker accepts clients rather than just TCP connections.
+func AMQPCheck(url string) Check {
+	var mu sync.Mutex
+	var conn *amqp.Connection
+
+	return func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if conn != nil && !conn.IsClosed() {
+			return nil
+		}
+
+		c, err := amqp.DialConfig(url, amqp.Config{
+			Dial: func(network, address string) (net.Conn, error) {
+				var dialer net.Dialer
+				netConn, err := dialer.DialContext(ctx, network, address)
+				if err != nil {
+					return nil, err
+				}
+
+				// Limit the handshake, the deadline is cleared once it's done.
+				if deadline, ok := ctx.Deadline(); ok {
+					if err := netConn.SetDeadline(deadline); err != nil {
+						netConn.Close()
+						return nil, err
+					}
+				}
+				return netConn, nil
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		conn = c
+		return nil
+	}
+}
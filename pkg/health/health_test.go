@@ -0,0 +1,224 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func passing(context.Context) error { return nil }
+func failing(context.Context) error { return errors.New("test err") }
+
+func setUpChecker(checks map[string]map[string]Check) *Checker {
+	checker := NewChecker(time.Minute, time.Second, nulls.NullLogger{})
+	for service, named := range checks {
+		for name, check := range named {
+			checker.AddCheck(service, name, check)
+		}
+	}
+	return checker
+}
+
+func TestChecker_runChecks(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks map[string]map[string]Check
+		want   map[string]healthpb.HealthCheckResponse_ServingStatus
+	}{
+		{
+			name: "Test if reports SERVING when all checks pass",
+			checks: map[string]map[string]Check{
+				"user": {"database": passing, "broker": passing},
+			},
+			want: map[string]healthpb.HealthCheckResponse_ServingStatus{
+				"":     healthpb.HealthCheckResponse_SERVING,
+				"user": healthpb.HealthCheckResponse_SERVING,
+			},
+		},
+		{
+			name: "Test if reports NOT_SERVING when any check fails",
+			checks: map[string]map[string]Check{
+				"user": {"database": passing, "broker": failing},
+			},
+			want: map[string]healthpb.HealthCheckResponse_ServingStatus{
+				"":     healthpb.HealthCheckResponse_NOT_SERVING,
+				"user": healthpb.HealthCheckResponse_NOT_SERVING,
+			},
+		},
+		{
+			name: "Test if reports status per service",
+			checks: map[string]map[string]Check{
+				"user":  {"database": passing},
+				"other": {"broker": failing},
+			},
+			want: map[string]healthpb.HealthCheckResponse_ServingStatus{
+				"":      healthpb.HealthCheckResponse_NOT_SERVING,
+				"user":  healthpb.HealthCheckResponse_SERVING,
+				"other": healthpb.HealthCheckResponse_NOT_SERVING,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			checker := setUpChecker(tt.checks)
+			checker.runChecks(ctx)
+
+			for service, want := range tt.want {
+				resp, err := checker.Server().Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+				if err != nil {
+					t.Errorf("Checker.Server().Check() service = %q, err = %v", service, err)
+					return
+				}
+
+				if got := resp.GetStatus(); got != want {
+					t.Errorf("Checker.runChecks() service = %q:\n got = %v\n want = %v", service, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestChecker_Shutdown(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	checker := setUpChecker(map[string]map[string]Check{"user": {"database": passing}})
+	checker.runChecks(ctx)
+	checker.Shutdown()
+	// Results after the shutdown should be ignored.
+	checker.runChecks(ctx)
+
+	for _, service := range []string{"", "user"} {
+		resp, err := checker.Server().Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Errorf("Checker.Server().Check() service = %q, err = %v", service, err)
+			return
+		}
+
+		if got := resp.GetStatus(); got != healthpb.HealthCheckResponse_NOT_SERVING {
+			t.Errorf("Checker.Shutdown() service = %q:\n got = %v\n want = %v", service, got, healthpb.HealthCheckResponse_NOT_SERVING)
+		}
+	}
+}
+
+func TestChecker_Handler(t *testing.T) {
+	tests := []struct {
+		name       string
+		checks     map[string]map[string]Check
+		target     string
+		wantCode   int
+		wantChecks map[string]string
+	}{
+		{
+			name:     "Test if liveness does not depend on checks",
+			checks:   map[string]map[string]Check{"user": {"database": failing}},
+			target:   "/livez",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:       "Test if readiness succeeds when all checks pass",
+			checks:     map[string]map[string]Check{"user": {"database": passing}},
+			target:     "/readyz",
+			wantCode:   http.StatusOK,
+			wantChecks: map[string]string{"database": "ok"},
+		},
+		{
+			name:       "Test if readiness fails and reports failing checks",
+			checks:     map[string]map[string]Check{"user": {"database": passing, "broker": failing}},
+			target:     "/readyz",
+			wantCode:   http.StatusServiceUnavailable,
+			wantChecks: map[string]string{"database": "ok", "broker": "failing"},
+		},
+		{
+			name:       "Test if readiness reports the given service",
+			checks:     map[string]map[string]Check{"user": {"database": passing}, "other": {"broker": failing}},
+			target:     "/readyz?service=user",
+			wantCode:   http.StatusOK,
+			wantChecks: map[string]string{"database": "ok", "broker": "failing"},
+		},
+		{
+			name:     "Test if readiness fails on unknown service",
+			checks:   map[string]map[string]Check{"user": {"database": passing}},
+			target:   "/readyz?service=unknown",
+			wantCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			checker := setUpChecker(tt.checks)
+			checker.runChecks(ctx)
+
+			rec := httptest.NewRecorder()
+			checker.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("Checker.Handler() code:\n got = %v\n want = %v", rec.Code, tt.wantCode)
+				return
+			}
+
+			if tt.wantChecks == nil {
+				return
+			}
+
+			var got readinessResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Errorf("Failed to decode readiness response: %v", err)
+				return
+			}
+
+			if !cmp.Equal(got.Checks, tt.wantChecks) {
+				t.Errorf("Checker.Handler() checks:\n got = %v\n want = %v\n %v", got.Checks, tt.wantChecks, cmp.Diff(got.Checks, tt.wantChecks))
+			}
+		})
+	}
+}
+
+func TestAMQPCheck(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	url := "amqp://guest:guest@" + lis.Addr().String() + "/"
+
+	// Accept connections without ever speaking AMQP.
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+
+	if err := AMQPCheck(url)(ctx); err == nil {
+		t.Errorf("AMQPCheck() on a listener not speaking AMQP err = nil, want non-nil")
+	}
+
+	lis.Close()
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := AMQPCheck(url)(ctx); err == nil {
+		t.Errorf("AMQPCheck() on closed listener err = nil, want non-nil")
+	}
+}
@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const namespace = "user_service"
+
+// Metrics holds the service's Prometheus collectors.
+type Metrics struct {
+	registerer           prometheus.Registerer
+	requests             *prometheus.CounterVec
+	requestDuration      *prometheus.HistogramVec
+	publishes            *prometheus.CounterVec
+	validationRejections *prometheus.CounterVec
+}
+
+// New creates the service's collectors and registers them with the given registerer.
+// Exposing them is left to whoever serves the registry.
+func New(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		registerer: registerer,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "grpc_requests_total",
+			Help:      "Total number of handled gRPC requests.",
+		}, []string{"grpc_service", "grpc_method", "grpc_code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "grpc_request_duration_seconds",
+			Help:      "Latency of handled gRPC requests.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"grpc_service", "grpc_method"}),
+		publishes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "broker_publishes_total",
+			Help:      "Total number of events published to the broker.",
+		}, []string{"event_type", "result"}),
+		validationRejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "validation_rejections_total",
+			Help:      "Total number of requests rejected before reaching the handler.",
+		}, []string{"grpc_service", "grpc_method", "grpc_code"}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.requests, m.requestDuration, m.publishes, m.validationRejections} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// RegisterDB registers a collector exporting the connection pool stats of the given database.
+func (m *Metrics) RegisterDB(db *sql.DB, name string) error {
+	return m.registerer.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// UnaryServerInterceptor records the count and latency of unary RPCs.
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.observe(info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records the count and latency of streaming RPCs.
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		m.observe(info.FullMethod, start, err)
+		return err
+	}
+}
+
+func (m *Metrics) observe(fullMethod string, start time.Time, err error) {
+	service, method := splitMethodName(fullMethod)
+	m.requests.WithLabelValues(service, method, status.Code(err).String()).Inc()
+	m.requestDuration.WithLabelValues(service, method).Observe(time.Since(start).Seconds())
+}
+
+// CountRejections wraps a validating interceptor and counts requests
+// it rejected with an error without passing them to the handler.
+func (m *Metrics) CountRejections(interceptor grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		handled := false
+		resp, err := interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			handled = true
+			return handler(ctx, req)
+		})
+
+		if err != nil && !handled {
+			service, method := splitMethodName(info.FullMethod)
+			m.validationRejections.WithLabelValues(service, method, status.Code(err).String()).Inc()
+		}
+
+		return resp, err
+	}
+}
+
+// InstrumentBroker returns a broker counting publish successes and failures
+// of the given one.
+func (m *Metrics) InstrumentBroker(broker event.Broker) event.Broker {
+	return instrumentedBroker{
+		Broker:  broker,
+		metrics: m,
+	}
+}
+
+type instrumentedBroker struct {
+	event.Broker
+	metrics *Metrics
+}
+
+func (b instrumentedBroker) Publish(ctx context.Context, e event.Event) error {
+	err := b.Broker.Publish(ctx, e)
+	b.metrics.observePublish(e, err)
+	return err
+}
+
+func (b instrumentedBroker) ResilientPublish(e event.Event) error {
+	err := b.Broker.ResilientPublish(e)
+	b.metrics.observePublish(e, err)
+	return err
+}
+
+func (m *Metrics) observePublish(e event.Event, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.publishes.WithLabelValues(string(e.Type), result).Inc()
+}
+
+// splitMethodName splits "/package.Service/Method" into service and method names.
+func splitMethodName(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.Index(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "unknown", "unknown"
+}
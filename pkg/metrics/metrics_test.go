@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/mocks"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func setUpMetrics(t *testing.T) *Metrics {
+	registry := prometheus.NewRegistry()
+	m, err := New(registry)
+	if err != nil {
+		t.Fatalf("Failed to create metrics: %v", err)
+	}
+	return m
+}
+
+func TestMetrics_UnaryServerInterceptor(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  grpc.UnaryHandler
+		wantCode string
+	}{
+		{
+			name: "Test if records successful requests",
+			handler: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			},
+			wantCode: codes.OK.String(),
+		},
+		{
+			name: "Test if records the code of failed requests",
+			handler: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, status.Error(codes.NotFound, "")
+			},
+			wantCode: codes.NotFound.String(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := setUpMetrics(t)
+			info := &grpc.UnaryServerInfo{FullMethod: "/user.UserService/Get"}
+
+			m.UnaryServerInterceptor()(context.Background(), nil, info, tt.handler)
+
+			if got := testutil.ToFloat64(m.requests.WithLabelValues("user.UserService", "Get", tt.wantCode)); got != 1 {
+				t.Errorf("Metrics.UnaryServerInterceptor() requests:\n got = %v\n want = %v", got, 1)
+			}
+
+			if got := testutil.CollectAndCount(m.requestDuration); got != 1 {
+				t.Errorf("Metrics.UnaryServerInterceptor() durations:\n got = %v\n want = %v", got, 1)
+			}
+		})
+	}
+}
+
+func TestMetrics_CountRejections(t *testing.T) {
+	tests := []struct {
+		name        string
+		interceptor grpc.UnaryServerInterceptor
+		want        float64
+	}{
+		{
+			name: "Test if counts requests rejected before reaching the handler",
+			interceptor: func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				return nil, status.Error(codes.InvalidArgument, "")
+			},
+			want: 1,
+		},
+		{
+			name: "Test if does not count handler errors",
+			interceptor: func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				return handler(ctx, req)
+			},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := setUpMetrics(t)
+			info := &grpc.UnaryServerInfo{FullMethod: "/user.UserService/Create"}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, status.Error(codes.InvalidArgument, "")
+			}
+
+			m.CountRejections(tt.interceptor)(context.Background(), nil, info, handler)
+
+			if got := testutil.ToFloat64(m.validationRejections.WithLabelValues("user.UserService", "Create", codes.InvalidArgument.String())); got != tt.want {
+				t.Errorf("Metrics.CountRejections():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetrics_InstrumentBroker(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantResult string
+	}{
+		{
+			name:       "Test if counts successful publishes",
+			wantResult: "success",
+		},
+		{
+			name:       "Test if counts failed publishes",
+			err:        errors.New("test err"),
+			wantResult: "failure",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := setUpMetrics(t)
+
+			broker := mocks.NewBroker()
+			broker.On("ResilientPublish", mock.AnythingOfType("event.Event")).Return(tt.err).Once()
+			broker.On("Publish", mock.Anything, mock.AnythingOfType("event.Event")).Return(tt.err).Once()
+
+			instrumented := m.InstrumentBroker(broker)
+			instrumented.ResilientPublish(event.Event{Type: event.UserCreated})
+			instrumented.Publish(context.Background(), event.Event{Type: event.UserCreated})
+
+			if got := testutil.ToFloat64(m.publishes.WithLabelValues(string(event.UserCreated), tt.wantResult)); got != 2 {
+				t.Errorf("Metrics.InstrumentBroker():\n got = %v\n want = %v", got, 2)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, err := New(registry)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	m.observe("/user.UserService/Get", time.Now(), nil)
+
+	count, err := testutil.GatherAndCount(registry, "user_service_grpc_requests_total")
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+
+	if count != 1 {
+		t.Errorf("New() registered request counts:\n got = %v\n want = %v", count, 1)
+	}
+}
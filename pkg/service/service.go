@@ -2,42 +2,129 @@ package service
 
 import (
 	"context"
-
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
 
 	"github.com/krixlion/dev_forum-lib/event"
 	"github.com/krixlion/dev_forum-lib/event/dispatcher"
 	"github.com/krixlion/dev_forum-lib/logging"
+	"github.com/krixlion/dev_forum-user/pkg/health"
+	"github.com/krixlion/dev_forum-user/pkg/shutdown"
 	"google.golang.org/grpc"
 )
 
+// ErrDispatcherNotRunning is reported by the dispatcher health check.
+var ErrDispatcherNotRunning = errors.New("dispatcher is not running")
+
 type UserService struct {
-	grpcPort   int
-	grpcServer *grpc.Server
-	broker     event.Broker
-	dispatcher *dispatcher.Dispatcher
-	logger     logging.Logger
-	shutdown   func() error
+	config            Config
+	grpcServer        *grpc.Server
+	gatewayServer     *http.Server
+	healthServer      *http.Server
+	health            *health.Checker
+	broker            event.Broker
+	dispatcher        *dispatcher.Dispatcher
+	dispatcherRunning *atomic.Bool
+	// The dispatcher outlives the context passed to Run
+	// so that it's stopped only after in-flight RPCs are drained.
+	dispatcherCtx  context.Context
+	stopDispatcher context.CancelFunc
+	dispatcherDone chan struct{}
+	logger         logging.Logger
+	shutdown       *shutdown.Manager
+}
+
+type Config struct {
+	GRPCPort    int
+	GatewayPort int
+	HealthPort  int
+	// HealthService is the name of the service whose health depends on the dispatcher running.
+	HealthService string
+	// ShutdownTimeout limits the whole shutdown and DrainTimeout
+	// the part of it spent waiting for in-flight RPCs to finish.
+	ShutdownTimeout time.Duration
+	DrainTimeout    time.Duration
 }
 
 type Dependencies struct {
-	Logger       logging.Logger
-	Broker       event.Broker
-	Dispatcher   *dispatcher.Dispatcher
-	GRPCServer   *grpc.Server
+	Logger     logging.Logger
+	Broker     event.Broker
+	Dispatcher *dispatcher.Dispatcher
+	GRPCServer *grpc.Server
+	Health     *health.Checker
+	Storage    io.Closer
+	// Gateway serves the HTTP/JSON API. The gateway is disabled if nil.
+	Gateway http.Handler
+	// Watches are closed on shutdown, so that watching clients do not delay draining. Optional.
+	Watches io.Closer
+	// GatewayTLS is used to serve the gateway over HTTPS. Plain HTTP is served if nil.
+	GatewayTLS *tls.Config
+	// ShutdownFunc is called as the last step of the shutdown, eg. to flush traces.
 	ShutdownFunc func() error
 }
 
-func NewUserService(grpcPort int, d Dependencies) UserService {
-	return UserService{
-		grpcPort:   grpcPort,
-		grpcServer: d.GRPCServer,
-		dispatcher: d.Dispatcher,
-		broker:     d.Broker,
-		logger:     d.Logger,
-		shutdown:   d.ShutdownFunc,
+// NewUserService registers a health check verifying that the dispatcher
+// is running under the configured HealthService name.
+func NewUserService(config Config, d Dependencies) UserService {
+	dispatcherCtx, stopDispatcher := context.WithCancel(context.Background())
+
+	s := UserService{
+		config:            config,
+		grpcServer:        d.GRPCServer,
+		health:            d.Health,
+		dispatcher:        d.Dispatcher,
+		dispatcherRunning: new(atomic.Bool),
+		dispatcherCtx:     dispatcherCtx,
+		stopDispatcher:    stopDispatcher,
+		dispatcherDone:    make(chan struct{}),
+		broker:            d.Broker,
+		logger:            d.Logger,
+	}
+
+	s.healthServer = &http.Server{
+		Addr:              fmt.Sprintf("0.0.0.0:%d", config.HealthPort),
+		Handler:           d.Health.Handler(),
+		ReadHeaderTimeout: time.Second * 5,
+	}
+
+	if d.Gateway != nil {
+		s.gatewayServer = &http.Server{
+			Addr:              fmt.Sprintf("0.0.0.0:%d", config.GatewayPort),
+			Handler:           d.Gateway,
+			TLSConfig:         d.GatewayTLS,
+			ReadHeaderTimeout: time.Second * 5,
+		}
 	}
+
+	d.Health.AddCheck(config.HealthService, "dispatcher", s.checkDispatcher)
+
+	// Stop routing new requests first and release the resources
+	// in-flight requests depend on only after they finish.
+	s.shutdown = shutdown.NewManager(config.ShutdownTimeout, d.Logger)
+	s.shutdown.Add("health", func(context.Context) error {
+		d.Health.Shutdown()
+		return nil
+	})
+	if d.Watches != nil {
+		s.shutdown.Add("watch", shutdown.Closer(d.Watches.Close))
+	}
+	if s.gatewayServer != nil {
+		s.shutdown.Add("gateway", shutdown.DrainHTTP(s.gatewayServer, config.DrainTimeout))
+	}
+	s.shutdown.Add("grpc", shutdown.GracefulStop(d.GRPCServer, config.DrainTimeout))
+	s.shutdown.Add("broker", shutdown.Closer(d.Broker.Close))
+	s.shutdown.Add("dispatcher", s.waitForDispatcher)
+	s.shutdown.Add("storage", shutdown.Closer(d.Storage.Close))
+	s.shutdown.Add("http", s.healthServer.Shutdown)
+	s.shutdown.Add("tracing", shutdown.Closer(d.ShutdownFunc))
+
+	return s
 }
 
 func (s *UserService) Run(ctx context.Context) {
@@ -45,21 +132,75 @@ func (s *UserService) Run(ctx context.Context) {
 		return
 	}
 
-	lis, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%d", s.grpcPort))
+	lis, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%d", s.config.GRPCPort))
 	if err != nil {
 		s.logger.Log(ctx, "failed to create a listener", "transport", "grpc", "err", err)
 		return
 	}
 
-	go s.dispatcher.Run(ctx)
+	go s.runDispatcher(s.dispatcherCtx)
+	go s.health.Run(ctx)
+	go s.serveHTTP(ctx, s.healthServer, s.config.HealthPort)
+	if s.gatewayServer != nil {
+		go s.serveHTTP(ctx, s.gatewayServer, s.config.GatewayPort)
+	}
 
-	s.logger.Log(ctx, "listening", "transport", "grpc", "port", s.grpcPort)
+	s.logger.Log(ctx, "listening", "transport", "grpc", "port", s.config.GRPCPort)
 
 	if err := s.grpcServer.Serve(lis); err != nil {
 		s.logger.Log(ctx, "failed to serve", "transport", "grpc", "err", err)
 	}
 }
 
+func (s *UserService) runDispatcher(ctx context.Context) {
+	defer close(s.dispatcherDone)
+
+	s.dispatcherRunning.Store(true)
+	defer s.dispatcherRunning.Store(false)
+
+	s.dispatcher.Run(ctx)
+}
+
+// waitForDispatcher stops the dispatcher and waits until it returns, if it was started.
+func (s *UserService) waitForDispatcher(ctx context.Context) error {
+	s.stopDispatcher()
+
+	if !s.dispatcherRunning.Load() {
+		return nil
+	}
+
+	select {
+	case <-s.dispatcherDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *UserService) checkDispatcher(context.Context) error {
+	if !s.dispatcherRunning.Load() {
+		return ErrDispatcherNotRunning
+	}
+	return nil
+}
+
+func (s *UserService) serveHTTP(ctx context.Context, server *http.Server, port int) {
+	s.logger.Log(ctx, "listening", "transport", "http", "port", port)
+
+	listen := server.ListenAndServe
+	if server.TLSConfig != nil {
+		// Certificates are provided by the TLS config.
+		listen = func() error { return server.ListenAndServeTLS("", "") }
+	}
+
+	if err := listen(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		s.logger.Log(ctx, "failed to serve", "transport", "http", "port", port, "err", err)
+	}
+}
+
+// Close reports NOT_SERVING to health checks, drains in-flight RPCs
+// and then shuts down the dependencies in order within the ShutdownTimeout.
+// Errors of all failed steps are logged and returned joined.
 func (s *UserService) Close() error {
-	return s.shutdown()
+	return s.shutdown.Shutdown(context.Background())
 }
@@ -0,0 +1,131 @@
+// Package shutdown runs cleanup steps in a fixed order within an overall deadline.
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/logging"
+)
+
+// Step releases a single resource. It should return once the context is done.
+type Step func(ctx context.Context) error
+
+type namedStep struct {
+	name string
+	step Step
+}
+
+// Manager runs registered steps in the order they were added.
+type Manager struct {
+	timeout time.Duration
+	logger  logging.Logger
+	steps   []namedStep
+}
+
+// NewManager returns a Manager which gives all steps combined the given timeout.
+func NewManager(timeout time.Duration, logger logging.Logger) *Manager {
+	return &Manager{
+		timeout: timeout,
+		logger:  logger,
+	}
+}
+
+// Add appends a step to be run on Shutdown.
+func (m *Manager) Add(name string, step Step) {
+	m.steps = append(m.steps, namedStep{name: name, step: step})
+}
+
+// Shutdown runs every step even if the previous ones failed or the deadline passed,
+// so that no resource is left open. Steps run after the deadline get a done context.
+// Failures are logged and returned joined into a single error.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	var errs []error
+	for _, s := range m.steps {
+		start := time.Now()
+
+		if err := s.step(ctx); err != nil {
+			m.logger.Log(ctx, "shutdown step failed", "step", s.name, "duration", time.Since(start), "err", err)
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+			continue
+		}
+
+		m.logger.Log(ctx, "shutdown step completed", "step", s.name, "duration", time.Since(start))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Closer adapts a Close func, eg. of an io.Closer, to a Step.
+func Closer(close func() error) Step {
+	return func(context.Context) error {
+		return close()
+	}
+}
+
+// GRPCServer is implemented by *grpc.Server.
+type GRPCServer interface {
+	GracefulStop()
+	Stop()
+}
+
+// ErrForcedStop is returned when in-flight RPCs did not finish before the deadline.
+var ErrForcedStop = errors.New("server stopped before in-flight RPCs finished")
+
+// GracefulStop returns a Step which stops the server from accepting new connections
+// and waits up to drainTimeout for in-flight RPCs, including streams, to finish.
+// Then the server is stopped forcefully, cancelling the remaining RPCs.
+func GracefulStop(server GRPCServer, drainTimeout time.Duration) Step {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, drainTimeout)
+		defer cancel()
+
+		stopped := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+			return nil
+		case <-ctx.Done():
+			server.Stop()
+			<-stopped
+			return ErrForcedStop
+		}
+	}
+}
+
+// HTTPServer is implemented by *http.Server.
+type HTTPServer interface {
+	Shutdown(ctx context.Context) error
+	Close() error
+}
+
+// DrainHTTP returns a Step which stops the server from accepting new connections
+// and waits up to drainTimeout for in-flight requests to finish.
+// Then the remaining connections are closed.
+func DrainHTTP(server HTTPServer, drainTimeout time.Duration) Step {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, drainTimeout)
+		defer cancel()
+
+		if err := server.Shutdown(ctx); err != nil {
+			if closeErr := server.Close(); closeErr != nil {
+				return errors.Join(err, closeErr)
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				return ErrForcedStop
+			}
+			return err
+		}
+
+		return nil
+	}
+}
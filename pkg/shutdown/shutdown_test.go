@@ -0,0 +1,170 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-lib/nulls"
+)
+
+func TestManager_Shutdown(t *testing.T) {
+	var got []string
+	record := func(name string, err error) Step {
+		return func(context.Context) error {
+			got = append(got, name)
+			return err
+		}
+	}
+
+	m := NewManager(time.Second, nulls.NullLogger{})
+	m.Add("health", record("health", nil))
+	m.Add("grpc", record("grpc", errors.New("grpc err")))
+	m.Add("broker", record("broker", nil))
+	m.Add("storage", record("storage", errors.New("storage err")))
+
+	err := m.Shutdown(context.Background())
+
+	want := []string{"health", "grpc", "broker", "storage"}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Manager.Shutdown() order:\n got = %v\n want = %v", got, want)
+	}
+
+	for _, want := range []string{"grpc: grpc err", "storage: storage err"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Manager.Shutdown() error = %v, does not mention %q", err, want)
+		}
+	}
+}
+
+func TestManager_Shutdown_deadline(t *testing.T) {
+	m := NewManager(time.Millisecond*10, nulls.NullLogger{})
+	m.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	ran := false
+	m.Add("storage", func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+
+	err := m.Shutdown(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Manager.Shutdown() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if !ran {
+		t.Errorf("Manager.Shutdown() skipped steps after the deadline")
+	}
+}
+
+type fakeServer struct {
+	drain   chan struct{}
+	stopped atomic.Bool
+}
+
+func (s *fakeServer) GracefulStop() {
+	<-s.drain
+}
+
+func (s *fakeServer) Stop() {
+	s.stopped.Store(true)
+	close(s.drain)
+}
+
+func TestGracefulStop(t *testing.T) {
+	tests := []struct {
+		name        string
+		drainAfter  time.Duration
+		wantErr     error
+		wantStopped bool
+	}{
+		{
+			name:       "Test if waits for in-flight RPCs",
+			drainAfter: time.Millisecond,
+		},
+		{
+			name:        "Test if stops forcefully after drain timeout",
+			drainAfter:  time.Hour,
+			wantErr:     ErrForcedStop,
+			wantStopped: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeServer{drain: make(chan struct{})}
+			timer := time.AfterFunc(tt.drainAfter, func() { close(server.drain) })
+			defer timer.Stop()
+
+			err := GracefulStop(server, time.Millisecond*50)(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("GracefulStop() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := server.stopped.Load(); got != tt.wantStopped {
+				t.Errorf("GracefulStop() stopped = %v, want %v", got, tt.wantStopped)
+			}
+		})
+	}
+}
+
+type fakeHTTPServer struct {
+	drain  chan struct{}
+	closed atomic.Bool
+}
+
+func (s *fakeHTTPServer) Shutdown(ctx context.Context) error {
+	select {
+	case <-s.drain:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *fakeHTTPServer) Close() error {
+	s.closed.Store(true)
+	return nil
+}
+
+func TestDrainHTTP(t *testing.T) {
+	tests := []struct {
+		name       string
+		drainAfter time.Duration
+		wantErr    error
+		wantClosed bool
+	}{
+		{
+			name:       "Test if waits for in-flight requests",
+			drainAfter: time.Millisecond,
+		},
+		{
+			name:       "Test if closes connections after drain timeout",
+			drainAfter: time.Hour,
+			wantErr:    ErrForcedStop,
+			wantClosed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeHTTPServer{drain: make(chan struct{})}
+			timer := time.AfterFunc(tt.drainAfter, func() { close(server.drain) })
+			defer timer.Stop()
+
+			err := DrainHTTP(server, time.Millisecond*50)(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("DrainHTTP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := server.closed.Load(); got != tt.wantClosed {
+				t.Errorf("DrainHTTP() closed = %v, want %v", got, tt.wantClosed)
+			}
+		})
+	}
+}
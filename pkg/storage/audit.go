@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/krixlion/dev_forum-user/pkg/entity"
+)
+
+// Redacted replaces values of sensitive fields in audit entries.
+const Redacted = "[REDACTED]"
+
+// AuditInfo identifies who requested a change and in which request.
+// It's recorded along with every change of a user.
+type AuditInfo struct {
+	Actor     string
+	RequestId string
+}
+
+// AuditStorage reads the entries recorded by the Writer in the same transaction as the changes.
+type AuditStorage interface {
+	// ListAuditEntries returns entries of the user created in the [from, to) range, oldest first.
+	// Zero times leave the range unbounded.
+	ListAuditEntries(ctx context.Context, userId string, from, to time.Time, offset, limit uint) ([]entity.AuditEntry, error)
+}
+
+type auditedField struct {
+	name      string
+	value     func(entity.User) string
+	sensitive bool
+}
+
+var auditedFields = []auditedField{
+	{name: "name", value: func(v entity.User) string { return v.Name }},
+	{name: "email", value: func(v entity.User) string { return v.Email }},
+	{name: "password", value: func(v entity.User) string { return v.Password }, sensitive: true},
+}
+
+func (f auditedField) change(old, new string) entity.FieldChange {
+	if f.sensitive {
+		old, new = redact(old), redact(new)
+	}
+	return entity.FieldChange{Field: f.name, Old: old, New: new}
+}
+
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return Redacted
+}
+
+// Diff returns changes of the audited fields from the old to the new user.
+// Empty fields of the new user are considered unchanged, since they are not saved.
+func Diff(old, new entity.User) []entity.FieldChange {
+	changes := []entity.FieldChange{}
+	for _, f := range auditedFields {
+		oldValue, newValue := f.value(old), f.value(new)
+		if newValue == "" || newValue == oldValue {
+			continue
+		}
+		changes = append(changes, f.change(oldValue, newValue))
+	}
+	return changes
+}
+
+// Removal returns changes clearing all audited fields of the deleted user.
+func Removal(old entity.User) []entity.FieldChange {
+	changes := []entity.FieldChange{}
+	for _, f := range auditedFields {
+		if value := f.value(old); value != "" {
+			changes = append(changes, f.change(value, ""))
+		}
+	}
+	return changes
+}
+
+// Anonymize redacts all values of the changes, so that they hold no personal data of an erased user.
+func Anonymize(changes []entity.FieldChange) []entity.FieldChange {
+	anonymized := make([]entity.FieldChange, 0, len(changes))
+	for _, change := range changes {
+		anonymized = append(anonymized, entity.FieldChange{
+			Field: change.Field,
+			Old:   redact(change.Old),
+			New:   redact(change.New),
+		})
+	}
+	return anonymized
+}
@@ -0,0 +1,87 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-user/pkg/entity"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+)
+
+func TestDiff(t *testing.T) {
+	old := entity.User{Id: "1", Name: "krixlion", Email: "krixlion@example.com", Password: "hash"}
+
+	tests := []struct {
+		name string
+		old  entity.User
+		new  entity.User
+		want []entity.FieldChange
+	}{
+		{
+			name: "Test if returns all set fields of created users",
+			new:  old,
+			want: []entity.FieldChange{
+				{Field: "name", New: "krixlion"},
+				{Field: "email", New: "krixlion@example.com"},
+				{Field: "password", New: storage.Redacted},
+			},
+		},
+		{
+			name: "Test if skips unchanged and empty fields",
+			old:  old,
+			new:  entity.User{Id: "1", Name: "krixlion", Email: "new@example.com"},
+			want: []entity.FieldChange{
+				{Field: "email", Old: "krixlion@example.com", New: "new@example.com"},
+			},
+		},
+		{
+			name: "Test if redacts passwords",
+			old:  old,
+			new:  entity.User{Id: "1", Password: "other hash"},
+			want: []entity.FieldChange{
+				{Field: "password", Old: storage.Redacted, New: storage.Redacted},
+			},
+		},
+		{
+			name: "Test if returns no changes",
+			old:  old,
+			new:  old,
+			want: []entity.FieldChange{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := storage.Diff(tt.old, tt.new)
+			if !cmp.Equal(got, tt.want) {
+				t.Errorf("Diff():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoval(t *testing.T) {
+	got := storage.Removal(entity.User{Id: "1", Name: "krixlion", Password: "hash"})
+	want := []entity.FieldChange{
+		{Field: "name", Old: "krixlion"},
+		{Field: "password", Old: storage.Redacted},
+	}
+
+	if !cmp.Equal(got, want) {
+		t.Errorf("Removal():\n got = %v\n want = %v", got, want)
+	}
+}
+
+func TestAnonymize(t *testing.T) {
+	got := storage.Anonymize([]entity.FieldChange{
+		{Field: "name", Old: "krixlion", New: "krixlion2"},
+		{Field: "email", New: "krixlion@example.com"},
+	})
+	want := []entity.FieldChange{
+		{Field: "name", Old: storage.Redacted, New: storage.Redacted},
+		{Field: "email", New: storage.Redacted},
+	}
+
+	if !cmp.Equal(got, want) {
+		t.Errorf("Anonymize():\n got = %v\n want = %v", got, want)
+	}
+}
@@ -0,0 +1,99 @@
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/cockroachdb/cockroach-go/crdb"
+	"github.com/doug-martin/goqu/v9"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	"github.com/krixlion/dev_forum-user/pkg/entity"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+)
+
+const auditTable = "user_audit"
+
+type auditDataset struct {
+	Id        string    `db:"id"`
+	UserId    string    `db:"user_id"`
+	Actor     string    `db:"actor"`
+	Action    string    `db:"action"`
+	Changes   []byte    `db:"changes"`
+	RequestId string    `db:"request_id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (v auditDataset) AuditEntry() (entity.AuditEntry, error) {
+	var changes []entity.FieldChange
+	if err := json.Unmarshal(v.Changes, &changes); err != nil {
+		return entity.AuditEntry{}, err
+	}
+
+	return entity.AuditEntry{
+		Id:        v.Id,
+		UserId:    v.UserId,
+		Actor:     v.Actor,
+		Action:    entity.AuditAction(v.Action),
+		Changes:   changes,
+		RequestId: v.RequestId,
+		CreatedAt: v.CreatedAt,
+	}, nil
+}
+
+func (db CockroachDB) ListAuditEntries(ctx context.Context, userId string, from, to time.Time, offset, limit uint) ([]entity.AuditEntry, error) {
+	ctx, span := db.tracer.Start(ctx, "db.ListAuditEntries")
+	defer span.End()
+
+	where := []goqu.Expression{goqu.C("user_id").Eq(userId)}
+	if !from.IsZero() {
+		where = append(where, goqu.C("created_at").Gte(from.UTC()))
+	}
+	if !to.IsZero() {
+		where = append(where, goqu.C("created_at").Lt(to.UTC()))
+	}
+
+	query, args, err := db.queryBuilder.From(auditTable).Where(where...).Order(goqu.C("created_at").Asc(), goqu.C("id").Asc()).Limit(limit).Offset(offset).Prepared(true).ToSQL()
+	if err != nil {
+		tracing.SetSpanErr(span, err)
+		return nil, err
+	}
+
+	datasets := []auditDataset{}
+	if err := crdb.Execute(func() error { return db.conn.SelectContext(ctx, &datasets, query, args...) }); err != nil {
+		tracing.SetSpanErr(span, err)
+		return nil, err
+	}
+
+	entries := make([]entity.AuditEntry, 0, len(datasets))
+	for _, v := range datasets {
+		entry, err := v.AuditEntry()
+		if err != nil {
+			tracing.SetSpanErr(span, err)
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// lockAudited returns the audited fields of the user and locks its row until the transaction ends.
+// Returns sql.ErrNoRows if the user does not exist.
+func lockAudited(ctx context.Context, tx *sql.Tx, id string) (entity.User, error) {
+	user := entity.User{Id: id}
+	err := tx.QueryRowContext(ctx, `SELECT name, email, password FROM "users" WHERE id = $1 FOR UPDATE`, id).Scan(&user.Name, &user.Email, &user.Password)
+	return user, err
+}
+
+func recordAudit(ctx context.Context, tx *sql.Tx, userId string, action entity.AuditAction, changes []entity.FieldChange, audit storage.AuditInfo) error {
+	body, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO "user_audit" (user_id, actor, action, changes, request_id) VALUES ($1, $2, $3, $4, $5)`,
+		userId, audit.Actor, string(action), body, audit.RequestId)
+	return err
+}
@@ -0,0 +1,100 @@
+package cockroach
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-user/pkg/entity"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+)
+
+func TestDB_ListAuditEntries(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping db.ListAuditEntries integration test.")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	db := setUpDB()
+	start := time.Now().Add(-time.Minute)
+	audit := storage.AuditInfo{Actor: "user:1", RequestId: "request"}
+
+	user := entity.User{Id: "audited", Name: "audited", Email: "audited@example.com", Password: "hash", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := db.Create(ctx, user, audit); err != nil {
+		t.Fatalf("DB.Create() error = %v", err)
+	}
+
+	if err := db.Update(ctx, entity.User{Id: user.Id, Email: "changed@example.com", UpdatedAt: time.Now()}, audit); err != nil {
+		t.Fatalf("DB.Update() error = %v", err)
+	}
+
+	if err := db.Delete(ctx, user.Id, audit); err != nil {
+		t.Fatalf("DB.Delete() error = %v", err)
+	}
+
+	// Changes of users which do not exist fail without being recorded.
+	if err := db.Update(ctx, entity.User{Id: user.Id, Email: "missing@example.com", UpdatedAt: time.Now()}, audit); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("DB.Update() on deleted user error = %v, want %v", err, storage.ErrNotFound)
+	}
+
+	if err := db.Delete(ctx, user.Id, audit); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("DB.Delete() on deleted user error = %v, want %v", err, storage.ErrNotFound)
+	}
+
+	tests := []struct {
+		name        string
+		from        time.Time
+		to          time.Time
+		offset      uint
+		limit       uint
+		wantActions []entity.AuditAction
+	}{
+		{
+			name:        "Test if lists all entries oldest first",
+			limit:       10,
+			wantActions: []entity.AuditAction{entity.AuditCreate, entity.AuditUpdate, entity.AuditDelete},
+		},
+		{
+			name:        "Test if pages through entries",
+			offset:      1,
+			limit:       1,
+			wantActions: []entity.AuditAction{entity.AuditUpdate},
+		},
+		{
+			name:        "Test if lists entries within the time range",
+			from:        start,
+			to:          time.Now().Add(time.Minute),
+			limit:       10,
+			wantActions: []entity.AuditAction{entity.AuditCreate, entity.AuditUpdate, entity.AuditDelete},
+		},
+		{
+			name:  "Test if excludes entries outside of the time range",
+			to:    start,
+			limit: 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := db.ListAuditEntries(ctx, user.Id, tt.from, tt.to, tt.offset, tt.limit)
+			if err != nil {
+				t.Fatalf("DB.ListAuditEntries() error = %v", err)
+			}
+
+			var actions []entity.AuditAction
+			for _, entry := range got {
+				if entry.Actor != audit.Actor || entry.RequestId != audit.RequestId {
+					t.Errorf("DB.ListAuditEntries() entry = %+v, want actor and request id of %+v", entry, audit)
+				}
+				actions = append(actions, entry.Action)
+			}
+
+			if !cmp.Equal(actions, tt.wantActions) {
+				t.Errorf("DB.ListAuditEntries() actions = %v, want %v", actions, tt.wantActions)
+			}
+		})
+	}
+}
@@ -1,11 +1,14 @@
 package cockroach
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/doug-martin/goqu/v9"
 	_ "github.com/doug-martin/goqu/v9/dialect/postgres"
+	"github.com/krixlion/dev_forum-user/pkg/config"
 	"github.com/krixlion/dev_forum-user/pkg/storage"
 	"go.nhat.io/otelsql"
 	"go.opentelemetry.io/otel/trace"
@@ -18,10 +21,6 @@ const Driver = "postgres"
 
 var _ storage.Storage = (*CockroachDB)(nil)
 
-func formatConnString(host, port, user, password, dbname string) string {
-	return fmt.Sprintf("postgresql://%s:%s@%s:%s/%s?sslmode=disable", user, password, host, port, dbname)
-}
-
 type CockroachDB struct {
 	conn         *sqlx.DB
 	queryBuilder goqu.DialectWrapper
@@ -32,7 +31,16 @@ func (db CockroachDB) Conn() *sql.DB {
 	return db.conn.DB
 }
 
-func Make(host, port, user, password, dbName string, tracer trace.Tracer) (CockroachDB, error) {
+const (
+	initialPingInterval = time.Millisecond * 100
+	maxPingInterval     = time.Second * 5
+)
+
+// Make opens a connection pool using the given config and pings the database
+// with an exponential backoff until it responds, the config's ConnectTimeout
+// passes or the context is cancelled.
+// TLS is used according to the configured sslmode and certificates.
+func Make(ctx context.Context, config config.DB, tracer trace.Tracer) (CockroachDB, error) {
 	driverName, err := otelsql.Register(Driver,
 		otelsql.AllowRoot(),
 		otelsql.TraceQueryWithoutArgs(),
@@ -44,10 +52,26 @@ func Make(host, port, user, password, dbName string, tracer trace.Tracer) (Cockr
 		return CockroachDB{}, err
 	}
 
-	db, err := sql.Open(driverName, formatConnString(host, port, user, password, dbName))
+	db, err := sql.Open(driverName, config.ConnString())
 	if err != nil {
 		return CockroachDB{}, err
 	}
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+
+	if config.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.ConnectTimeout)
+		defer cancel()
+	}
+
+	if err := pingWithBackoff(ctx, db.PingContext, initialPingInterval, maxPingInterval); err != nil {
+		db.Close()
+		return CockroachDB{}, err
+	}
+
 	queryBuilder := goqu.Dialect(Driver)
 
 	return CockroachDB{
@@ -57,6 +81,33 @@ func Make(host, port, user, password, dbName string, tracer trace.Tracer) (Cockr
 	}, nil
 }
 
+// pingWithBackoff calls ping until it succeeds, doubling the interval
+// between attempts up to max. Returns the last ping error
+// if the context is done before a ping succeeds.
+func pingWithBackoff(ctx context.Context, ping func(context.Context) error, interval, max time.Duration) error {
+	for {
+		err := ping(ctx)
+		if err == nil {
+			return nil
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("failed to connect to the database: %w", err)
+		case <-timer.C:
+		}
+
+		interval = min(interval*2, max)
+	}
+}
+
+// Ping verifies the connection to the database is alive.
+func (db CockroachDB) Ping(ctx context.Context) error {
+	return db.conn.PingContext(ctx)
+}
+
 func (db CockroachDB) Close() error {
 	return db.conn.Close()
 }
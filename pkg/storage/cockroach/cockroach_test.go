@@ -0,0 +1,69 @@
+package cockroach
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_pingWithBackoff(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		timeout      time.Duration
+		wantAttempts int
+		wantErr      bool
+	}{
+		{
+			name:         "Test if returns immediately on successful ping",
+			timeout:      time.Second,
+			wantAttempts: 1,
+		},
+		{
+			name:         "Test if retries until ping succeeds",
+			failures:     3,
+			timeout:      time.Second,
+			wantAttempts: 4,
+		},
+		{
+			name:     "Test if gives up when context is done",
+			failures: 1000,
+			timeout:  time.Millisecond * 50,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			pingErr := errors.New("connection refused")
+			attempts := 0
+			ping := func(context.Context) error {
+				attempts++
+				if attempts <= tt.failures {
+					return pingErr
+				}
+				return nil
+			}
+
+			err := pingWithBackoff(ctx, ping, time.Millisecond, time.Millisecond*10)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("pingWithBackoff() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				if !errors.Is(err, pingErr) {
+					t.Errorf("pingWithBackoff() error = %v, want wrapped %v", err, pingErr)
+				}
+				return
+			}
+
+			if attempts != tt.wantAttempts {
+				t.Errorf("pingWithBackoff() attempts:\n got = %v\n want = %v", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package cockroach
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 
 	"github.com/cockroachdb/cockroach-go/crdb"
 	"github.com/doug-martin/goqu/v9"
@@ -10,20 +13,28 @@ import (
 	"github.com/krixlion/dev_forum-lib/str"
 	"github.com/krixlion/dev_forum-lib/tracing"
 	"github.com/krixlion/dev_forum-user/pkg/entity"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
 )
 
 const usersTable = "users"
 
-func (db CockroachDB) Get(ctx context.Context, params filter.Filter) (entity.User, error) {
+func (db CockroachDB) Get(ctx context.Context, params filter.Filter, fields []string) (entity.User, error) {
 	ctx, span := db.tracer.Start(ctx, "db.Get")
 	defer span.End()
 
 	exps, err := filterToSqlExp(params)
 	if err != nil {
+		tracing.SetSpanErr(span, err)
 		return entity.User{}, err
 	}
 
-	query, args, err := db.queryBuilder.From(usersTable).Where(exps...).Prepared(true).ToSQL()
+	columns, err := columnsToSqlExp(fields)
+	if err != nil {
+		tracing.SetSpanErr(span, err)
+		return entity.User{}, err
+	}
+
+	query, args, err := db.queryBuilder.From(usersTable).Select(columns...).Where(exps...).Prepared(true).ToSQL()
 	if err != nil {
 		tracing.SetSpanErr(span, err)
 		return entity.User{}, err
@@ -31,6 +42,10 @@ func (db CockroachDB) Get(ctx context.Context, params filter.Filter) (entity.Use
 
 	var dataset userDataset
 	if err := db.conn.GetContext(ctx, &dataset, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.User{}, fmt.Errorf("%w: %w", storage.ErrNotFound, err)
+		}
+		tracing.SetSpanErr(span, err)
 		return entity.User{}, err
 	}
 
@@ -42,7 +57,7 @@ func (db CockroachDB) Get(ctx context.Context, params filter.Filter) (entity.Use
 	return user, nil
 }
 
-func (db CockroachDB) GetMultiple(ctx context.Context, offset, limit string, params filter.Filter) ([]entity.User, error) {
+func (db CockroachDB) GetMultiple(ctx context.Context, offset, limit string, params storage.FilterGroup, fields []string) ([]entity.User, error) {
 	ctx, span := db.tracer.Start(ctx, "db.GetMultiple")
 	defer span.End()
 
@@ -58,15 +73,25 @@ func (db CockroachDB) GetMultiple(ctx context.Context, offset, limit string, par
 		return nil, err
 	}
 
-	exps, err := filterToSqlExp(params)
+	where, err := groupToSqlExp(params)
 	if err != nil {
 		tracing.SetSpanErr(span, err)
 		return nil, err
 	}
 
-	colExp := exp.NewColumnListExpression("name")
-	orderExp := exp.NewOrderedExpression(colExp, exp.DescSortDir, exp.NullsLastSortType)
-	mainExp := db.queryBuilder.From(usersTable).Order(orderExp).Limit(uint(l)).Offset(uint(o)).Where(exps...).Prepared(true)
+	orderExp, err := sortToSqlExp("name", exp.DescSortDir)
+	if err != nil {
+		tracing.SetSpanErr(span, err)
+		return nil, err
+	}
+
+	columns, err := columnsToSqlExp(fields)
+	if err != nil {
+		tracing.SetSpanErr(span, err)
+		return nil, err
+	}
+
+	mainExp := db.queryBuilder.From(usersTable).Select(columns...).Order(orderExp).Limit(uint(l)).Offset(uint(o)).Where(where).Prepared(true)
 	query, args, err := mainExp.ToSQL()
 	if err != nil {
 		tracing.SetSpanErr(span, err)
@@ -88,7 +113,41 @@ func (db CockroachDB) GetMultiple(ctx context.Context, offset, limit string, par
 	return users, nil
 }
 
-func (db CockroachDB) Create(ctx context.Context, user entity.User) error {
+// Search returns users whose names either start with or are trigram-similar to the given phrase.
+// Results are ordered by the similarity score, most relevant first.
+func (db CockroachDB) Search(ctx context.Context, phrase string, offset, limit uint) ([]entity.User, error) {
+	ctx, span := db.tracer.Start(ctx, "db.Search")
+	defer span.End()
+
+	name := goqu.C("name")
+	matches := goqu.Or(
+		goqu.L("? % ?", name, phrase),
+		name.ILike(likeEscaper.Replace(phrase)+"%"),
+	)
+	similarity := goqu.Func("similarity", name, phrase)
+
+	query, args, err := db.queryBuilder.From(usersTable).Where(matches).Order(similarity.Desc(), name.Asc()).Limit(limit).Offset(offset).Prepared(true).ToSQL()
+	if err != nil {
+		tracing.SetSpanErr(span, err)
+		return nil, err
+	}
+
+	datasets := []userDataset{}
+	if err := crdb.Execute(func() error { return db.conn.SelectContext(ctx, &datasets, query, args...) }); err != nil {
+		tracing.SetSpanErr(span, err)
+		return nil, err
+	}
+
+	users, err := usersFromDatasets(datasets)
+	if err != nil {
+		tracing.SetSpanErr(span, err)
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (db CockroachDB) Create(ctx context.Context, user entity.User, audit storage.AuditInfo) error {
 	ctx, span := db.tracer.Start(ctx, "db.Create")
 	defer span.End()
 
@@ -99,19 +158,24 @@ func (db CockroachDB) Create(ctx context.Context, user entity.User) error {
 		tracing.SetSpanErr(span, err)
 		return err
 	}
-	err = crdb.Execute(func() error {
-		_, err := db.conn.ExecContext(ctx, query, args...)
-		return err
+
+	err = crdb.ExecuteTx(ctx, db.conn.DB, nil, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, user.Id, entity.AuditCreate, storage.Diff(entity.User{}, user), audit)
 	})
 	if err != nil {
 		tracing.SetSpanErr(span, err)
-		return err
+		return takenErr(err)
 	}
 
 	return nil
 }
 
-func (db CockroachDB) Update(ctx context.Context, user entity.User) error {
+// Update returns storage.ErrNotFound if the user does not exist.
+func (db CockroachDB) Update(ctx context.Context, user entity.User, audit storage.AuditInfo) error {
 	ctx, span := db.tracer.Start(ctx, "db.Update")
 	defer span.End()
 
@@ -123,32 +187,65 @@ func (db CockroachDB) Update(ctx context.Context, user entity.User) error {
 		return err
 	}
 
-	err = crdb.Execute(func() error {
-		_, err := db.conn.ExecContext(ctx, query, args...)
-		return err
+	err = crdb.ExecuteTx(ctx, db.conn.DB, nil, func(tx *sql.Tx) error {
+		old, err := lockAudited(ctx, tx, user.Id)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+
+		if user.Name != "" && user.Name != old.Name {
+			if err := releaseName(ctx, tx, user.Id, old.Name); err != nil {
+				return err
+			}
+		}
+
+		return recordAudit(ctx, tx, user.Id, entity.AuditUpdate, storage.Diff(old, user), audit)
 	})
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %w", storage.ErrNotFound, err)
+		}
 		tracing.SetSpanErr(span, err)
-		return err
+		return takenErr(err)
 	}
 	return nil
 }
 
-func (db CockroachDB) Delete(ctx context.Context, id string) error {
+// Delete returns storage.ErrNotFound if the user does not exist.
+func (db CockroachDB) Delete(ctx context.Context, id string, audit storage.AuditInfo) error {
 	ctx, span := db.tracer.Start(ctx, "db.Delete")
 	defer span.End()
 
-	query, _, err := db.queryBuilder.Delete(usersTable).Where(goqu.C("id").Eq(id)).Prepared(true).ToSQL()
+	query, args, err := db.queryBuilder.Delete(usersTable).Where(goqu.C("id").Eq(id)).Prepared(true).ToSQL()
 	if err != nil {
 		tracing.SetSpanErr(span, err)
 		return err
 	}
 
-	err = crdb.Execute(func() error {
-		_, err := db.conn.ExecContext(ctx, query, id)
-		return err
+	err = crdb.ExecuteTx(ctx, db.conn.DB, nil, func(tx *sql.Tx) error {
+		old, err := lockAudited(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+
+		if err := releaseName(ctx, tx, id, old.Name); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, id, entity.AuditDelete, storage.Removal(old), audit)
 	})
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %w", storage.ErrNotFound, err)
+		}
 		tracing.SetSpanErr(span, err)
 		return err
 	}
@@ -2,9 +2,7 @@ package cockroach
 
 import (
 	"context"
-	"database/sql"
 	"errors"
-	"os"
 	"testing"
 	"time"
 
@@ -14,19 +12,21 @@ import (
 	"github.com/krixlion/dev_forum-lib/filter"
 	"github.com/krixlion/dev_forum-lib/nulls"
 	"github.com/krixlion/dev_forum-user/internal/gentest"
+	"github.com/krixlion/dev_forum-user/pkg/config"
 	"github.com/krixlion/dev_forum-user/pkg/entity"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
 	"github.com/krixlion/dev_forum-user/pkg/storage/cockroach/testdata"
 )
 
 func setUpDB() CockroachDB {
 	env.Load("app")
 
-	db_port := os.Getenv("DB_PORT")
-	db_host := os.Getenv("DB_HOST")
-	db_user := os.Getenv("DB_USER")
-	db_pass := os.Getenv("DB_PASS")
-	db_name := os.Getenv("DB_NAME")
-	storage, err := Make(db_host, db_port, db_user, db_pass, db_name, nulls.NullTracer{})
+	config, err := config.LoadDB(nil)
+	if err != nil {
+		panic(err)
+	}
+
+	storage, err := Make(context.Background(), config, nulls.NullTracer{})
 	if err != nil {
 		panic(err)
 	}
@@ -45,6 +45,7 @@ func TestDB_Get(t *testing.T) {
 	tests := []struct {
 		name    string
 		filter  filter.Filter
+		fields  []string
 		want    entity.User
 		wantErr bool
 	}{
@@ -57,6 +58,29 @@ func TestDB_Get(t *testing.T) {
 			}},
 			want: testdata.Users["1"],
 		},
+		{
+			name: "Test if selects only the given fields",
+			filter: filter.Filter{{
+				Attribute: "id",
+				Operator:  filter.Equal,
+				Value:     "1",
+			}},
+			fields: []string{"id", "email"},
+			want: entity.User{
+				Id:    testdata.Users["1"].Id,
+				Email: testdata.Users["1"].Email,
+			},
+		},
+		{
+			name: "Test if fails on unknown field",
+			filter: filter.Filter{{
+				Attribute: "id",
+				Operator:  filter.Equal,
+				Value:     "1",
+			}},
+			fields:  []string{"unknown"},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -65,7 +89,7 @@ func TestDB_Get(t *testing.T) {
 
 			db := setUpDB()
 
-			got, err := db.Get(ctx, tt.filter)
+			got, err := db.Get(ctx, tt.filter, tt.fields)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("DB.Get() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -86,7 +110,8 @@ func TestDB_GetMultiple(t *testing.T) {
 	type args struct {
 		offset string
 		limit  string
-		filter filter.Filter
+		filter storage.FilterGroup
+		fields []string
 	}
 	tests := []struct {
 		name    string
@@ -126,6 +151,39 @@ func TestDB_GetMultiple(t *testing.T) {
 				testdata.Users["2"],
 			},
 		},
+		{
+			name: "Test if selects only the given fields",
+			args: args{
+				limit:  "1",
+				fields: []string{"id", "name"},
+			},
+			want: []entity.User{{
+				Id:   testdata.Users["3"].Id,
+				Name: testdata.Users["3"].Name,
+			}},
+		},
+		{
+			name: "Test if correctly applies filter groups",
+			args: args{
+				limit: "3",
+				filter: storage.FilterGroup{
+					Logic: storage.Or,
+					Params: filter.Filter{
+						{Attribute: "id", Operator: filter.Equal, Value: "1"},
+					},
+					Groups: []storage.FilterGroup{{
+						Params: filter.Filter{
+							{Attribute: "name", Operator: storage.Prefix, Value: "name-"},
+							{Attribute: "id", Operator: filter.Equal, Value: "3"},
+						},
+					}},
+				},
+			},
+			want: []entity.User{
+				testdata.Users["3"],
+				testdata.Users["1"],
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -134,7 +192,7 @@ func TestDB_GetMultiple(t *testing.T) {
 
 			db := setUpDB()
 
-			got, err := db.GetMultiple(ctx, tt.args.offset, tt.args.limit, tt.args.filter)
+			got, err := db.GetMultiple(ctx, tt.args.offset, tt.args.limit, tt.args.filter, tt.args.fields)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("DB.GetMultiple() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -147,6 +205,77 @@ func TestDB_GetMultiple(t *testing.T) {
 	}
 }
 
+func TestDB_Search(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping db.Search integration test.")
+	}
+
+	type args struct {
+		phrase string
+		offset uint
+		limit  uint
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []entity.User
+		wantErr bool
+	}{
+		{
+			name: "Test if finds users by name prefix",
+			args: args{
+				phrase: "name-",
+				limit:  3,
+			},
+			want: []entity.User{
+				testdata.Users["1"],
+				testdata.Users["2"],
+				testdata.Users["3"],
+			},
+		},
+		{
+			name: "Test if ranks the most similar name first",
+			args: args{
+				phrase: "name-2",
+				limit:  1,
+			},
+			want: []entity.User{
+				testdata.Users["2"],
+			},
+		},
+		{
+			name: "Test if correctly applies offset",
+			args: args{
+				phrase: "name-",
+				offset: 2,
+				limit:  3,
+			},
+			want: []entity.User{
+				testdata.Users["3"],
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+			defer cancel()
+
+			db := setUpDB()
+
+			got, err := db.Search(ctx, tt.args.phrase, tt.args.offset, tt.args.limit)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DB.Search() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !cmp.Equal(got, tt.want, cmpopts.EquateApproxTime(time.Minute)) {
+				t.Errorf("DB.Search():\n got = %v\n want = %v\n %v\n", got, tt.want, cmp.Diff(got, tt.want))
+				return
+			}
+		})
+	}
+}
+
 func TestDB_Create(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping db.Create integration test.")
@@ -173,7 +302,7 @@ func TestDB_Create(t *testing.T) {
 
 			db := setUpDB()
 
-			if err := db.Create(ctx, tt.user); (err != nil) != tt.wantErr {
+			if err := db.Create(ctx, tt.user, storage.AuditInfo{Actor: "test"}); (err != nil) != tt.wantErr {
 				t.Errorf("DB.Create() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
@@ -185,7 +314,7 @@ func TestDB_Create(t *testing.T) {
 				Value:     want.Id,
 			}}
 
-			got, err := db.Get(ctx, filter)
+			got, err := db.Get(ctx, filter, nil)
 			if err != nil {
 				t.Errorf("Failed to DB.Get() after DB.Create() error = %v", err)
 				return
@@ -225,7 +354,7 @@ func TestDB_Update(t *testing.T) {
 
 			db := setUpDB()
 
-			if err := db.Update(ctx, tt.user); (err != nil) != tt.wantErr {
+			if err := db.Update(ctx, tt.user, storage.AuditInfo{Actor: "test"}); (err != nil) != tt.wantErr {
 				t.Errorf("DB.Update() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
@@ -237,7 +366,7 @@ func TestDB_Update(t *testing.T) {
 				Value:     want.Id,
 			}}
 
-			got, err := db.Get(ctx, filter)
+			got, err := db.Get(ctx, filter, nil)
 			if err != nil {
 				t.Errorf("Failed to DB.Get() after DB.Update() error = %v", err)
 				return
@@ -273,7 +402,7 @@ func TestDB_Delete(t *testing.T) {
 
 			db := setUpDB()
 
-			if err := db.Delete(ctx, tt.id); (err != nil) != tt.wantErr {
+			if err := db.Delete(ctx, tt.id, storage.AuditInfo{Actor: "test"}); (err != nil) != tt.wantErr {
 				t.Errorf("DB.Delete() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
@@ -284,9 +413,9 @@ func TestDB_Delete(t *testing.T) {
 				Value:     tt.id,
 			}}
 
-			_, err := db.Get(ctx, filter)
-			if !errors.Is(err, sql.ErrNoRows) {
-				t.Errorf("DB.Delete():\n gotErr = %T, wantErr = %T, err = %v", err, sql.ErrNoRows, err)
+			_, err := db.Get(ctx, filter, nil)
+			if !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("DB.Delete():\n gotErr = %T, wantErr = %T, err = %v", err, storage.ErrNotFound, err)
 				return
 			}
 		})
@@ -0,0 +1,26 @@
+package cockroach
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cockroach-go/crdb"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+)
+
+var _ storage.EmailStorage = (*CockroachDB)(nil)
+
+func (db CockroachDB) EmailTaken(ctx context.Context, address, key, userId string) (bool, error) {
+	ctx, span := db.tracer.Start(ctx, "db.EmailTaken")
+	defer span.End()
+
+	var taken bool
+	if err := crdb.Execute(func() error {
+		return db.conn.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM "users" WHERE id <> $3 AND (email = $1 OR ($2 <> '' AND email_key = $2)))`, address, key, userId).Scan(&taken)
+	}); err != nil {
+		tracing.SetSpanErr(span, err)
+		return false, err
+	}
+
+	return taken, nil
+}
@@ -0,0 +1,93 @@
+package cockroach
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-user/pkg/entity"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+)
+
+func TestDB_EmailTaken(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping db.EmailTaken integration test.")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	db := setUpDB()
+
+	user := entity.User{Id: "email-owner", Name: "email-owner", Email: "Owner+forum@example.com", EmailKey: "owner@example.com", Password: "hash", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := db.Create(ctx, user, storage.AuditInfo{Actor: "test"}); err != nil {
+		t.Fatalf("DB.Create() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		address string
+		key     string
+		userId  string
+		want    bool
+	}{
+		{
+			name:    "Test if finds the same address",
+			address: "Owner+forum@example.com",
+			userId:  "other",
+			want:    true,
+		},
+		{
+			name:    "Test if ignores the user's own address",
+			address: "Owner+forum@example.com",
+			userId:  user.Id,
+		},
+		{
+			name:    "Test if ignores keys unless given",
+			address: "owner@example.com",
+			userId:  "other",
+		},
+		{
+			name:    "Test if finds addresses with the key",
+			address: "owner@example.com",
+			key:     "owner@example.com",
+			userId:  "other",
+			want:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := db.EmailTaken(ctx, tt.address, tt.key, tt.userId)
+			if err != nil {
+				t.Fatalf("DB.EmailTaken() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("DB.EmailTaken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDB_Create_emailTaken(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping db.Create email uniqueness integration test.")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	db := setUpDB()
+	audit := storage.AuditInfo{Actor: "test"}
+
+	owner := entity.User{Id: "key-owner", Name: "key-owner", Email: "owner+a@example.com", EmailKey: "owner@example.com", Password: "hash", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := db.Create(ctx, owner, audit); err != nil {
+		t.Fatalf("DB.Create() error = %v", err)
+	}
+
+	other := entity.User{Id: "key-other", Name: "key-other", Email: "owner+b@example.com", EmailKey: "owner@example.com", Password: "hash", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := db.Create(ctx, other, audit); !errors.Is(err, storage.ErrEmailTaken) {
+		t.Errorf("DB.Create() error = %v, want %v", err, storage.ErrEmailTaken)
+	}
+}
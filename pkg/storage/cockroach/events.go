@@ -0,0 +1,102 @@
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/cockroachdb/cockroach-go/crdb"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+)
+
+var _ storage.EventStorage = (*CockroachDB)(nil)
+
+func (db CockroachDB) RecordLogin(ctx context.Context, eventId, userId string, at time.Time) error {
+	ctx, span := db.tracer.Start(ctx, "db.RecordLogin")
+	defer span.End()
+
+	err := db.processOnce(ctx, eventId, func(tx *sql.Tx) error {
+		// Logins may be consumed out of order.
+		_, err := tx.ExecContext(ctx, `UPDATE "users" SET last_login_at = $2 WHERE id = $1 AND (last_login_at IS NULL OR last_login_at < $2)`, userId, at.UTC())
+		return err
+	})
+	if err != nil && !errors.Is(err, storage.ErrEventProcessed) {
+		tracing.SetSpanErr(span, err)
+	}
+
+	return err
+}
+
+func (db CockroachDB) AddArticle(ctx context.Context, eventId, userId, articleId string) error {
+	ctx, span := db.tracer.Start(ctx, "db.AddArticle")
+	defer span.End()
+
+	err := db.processOnce(ctx, eventId, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `INSERT INTO "user_articles" (article_id, user_id) VALUES ($1, $2) ON CONFLICT (article_id) DO NOTHING`, articleId, userId)
+		if err != nil {
+			return err
+		}
+
+		// The article might have been added by a redelivered event with a different id.
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE "users" SET post_count = post_count + 1 WHERE id = $1`, userId)
+		return err
+	})
+	if err != nil && !errors.Is(err, storage.ErrEventProcessed) {
+		tracing.SetSpanErr(span, err)
+	}
+
+	return err
+}
+
+func (db CockroachDB) RemoveArticle(ctx context.Context, eventId, articleId string) error {
+	ctx, span := db.tracer.Start(ctx, "db.RemoveArticle")
+	defer span.End()
+
+	err := db.processOnce(ctx, eventId, func(tx *sql.Tx) error {
+		var userId string
+		err := tx.QueryRowContext(ctx, `DELETE FROM "user_articles" WHERE article_id = $1 RETURNING user_id`, articleId).Scan(&userId)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Unknown or already removed article.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE "users" SET post_count = post_count - 1 WHERE id = $1 AND post_count > 0`, userId)
+		return err
+	})
+	if err != nil && !errors.Is(err, storage.ErrEventProcessed) {
+		tracing.SetSpanErr(span, err)
+	}
+
+	return err
+}
+
+// processOnce records the event as processed and applies it in a single transaction.
+// Returns storage.ErrEventProcessed without applying the event if it was already recorded.
+func (db CockroachDB) processOnce(ctx context.Context, eventId string, apply func(*sql.Tx) error) error {
+	return crdb.ExecuteTx(ctx, db.conn.DB, nil, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `INSERT INTO "processed_events" (id) VALUES ($1) ON CONFLICT (id) DO NOTHING`, eventId)
+		if err != nil {
+			return err
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if n == 0 {
+			return storage.ErrEventProcessed
+		}
+
+		return apply(tx)
+	})
+}
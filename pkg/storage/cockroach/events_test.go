@@ -0,0 +1,114 @@
+package cockroach
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+)
+
+func getActivity(t *testing.T, db CockroachDB, userId string) (lastLoginAt *time.Time, postCount int64) {
+	t.Helper()
+
+	if err := db.conn.QueryRowContext(context.Background(), `SELECT last_login_at, post_count FROM "users" WHERE id = $1`, userId).Scan(&lastLoginAt, &postCount); err != nil {
+		t.Fatalf("failed to query user activity: %v", err)
+	}
+
+	return lastLoginAt, postCount
+}
+
+func TestDB_RecordLogin(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration db.RecordLogin test.")
+	}
+
+	db := setUpDB()
+	defer db.Close()
+
+	ctx := context.Background()
+	latest := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	if err := db.RecordLogin(ctx, "login-1", "1", latest); err != nil {
+		t.Fatalf("db.RecordLogin() error = %v", err)
+	}
+
+	if err := db.RecordLogin(ctx, "login-1", "1", latest); !errors.Is(err, storage.ErrEventProcessed) {
+		t.Errorf("db.RecordLogin() on a redelivered event error = %v, want %v", err, storage.ErrEventProcessed)
+	}
+
+	// Logins consumed out of order must not move last_login_at back.
+	if err := db.RecordLogin(ctx, "login-2", "1", latest.Add(-time.Hour)); err != nil {
+		t.Fatalf("db.RecordLogin() error = %v", err)
+	}
+
+	got, _ := getActivity(t, db, "1")
+	if got == nil || !got.Equal(latest) {
+		t.Errorf("db.RecordLogin() last_login_at:\n got = %v\n want = %v", got, latest)
+	}
+}
+
+func TestDB_Articles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration db.AddArticle and db.RemoveArticle test.")
+	}
+
+	db := setUpDB()
+	defer db.Close()
+
+	ctx := context.Background()
+
+	steps := []struct {
+		name    string
+		apply   func() error
+		wantErr error
+		want    int64
+	}{
+		{
+			name:  "add article",
+			apply: func() error { return db.AddArticle(ctx, "created-1", "1", "article-1") },
+			want:  1,
+		},
+		{
+			name:    "redeliver the same event",
+			apply:   func() error { return db.AddArticle(ctx, "created-1", "1", "article-1") },
+			wantErr: storage.ErrEventProcessed,
+			want:    1,
+		},
+		{
+			name:  "add the same article with a different event",
+			apply: func() error { return db.AddArticle(ctx, "created-2", "1", "article-1") },
+			want:  1,
+		},
+		{
+			name:  "add another article",
+			apply: func() error { return db.AddArticle(ctx, "created-3", "1", "article-2") },
+			want:  2,
+		},
+		{
+			name:  "remove article",
+			apply: func() error { return db.RemoveArticle(ctx, "deleted-1", "article-1") },
+			want:  1,
+		},
+		{
+			name:  "remove already removed article",
+			apply: func() error { return db.RemoveArticle(ctx, "deleted-2", "article-1") },
+			want:  1,
+		},
+		{
+			name:  "remove unknown article",
+			apply: func() error { return db.RemoveArticle(ctx, "deleted-3", "article-3") },
+			want:  1,
+		},
+	}
+	for _, step := range steps {
+		if err := step.apply(); !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: error = %v, wantErr %v", step.name, err, step.wantErr)
+		}
+
+		if _, got := getActivity(t, db, "1"); got != step.want {
+			t.Errorf("%s: post_count:\n got = %v\n want = %v", step.name, got, step.want)
+		}
+	}
+}
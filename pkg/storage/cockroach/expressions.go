@@ -1,14 +1,17 @@
 package cockroach
 
 import (
-	"errors"
-	"reflect"
+	"fmt"
+	"strings"
 
+	"github.com/doug-martin/goqu/v9"
 	"github.com/doug-martin/goqu/v9/exp"
 	"github.com/krixlion/dev_forum-lib/filter"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
 )
 
-var ErrTagNotFound error = errors.New("tag not found")
+// likeEscaper escapes characters having a special meaning in LIKE patterns.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
 
 // filterToSqlExp converts filter.Filter into goqu.Expression to use with goqu SQL builder.
 func filterToSqlExp(params filter.Filter) ([]exp.Expression, error) {
@@ -19,18 +22,49 @@ func filterToSqlExp(params filter.Filter) ([]exp.Expression, error) {
 			return nil, err
 		}
 
-		if err := verifyField(param.Attribute); err != nil {
+		if err := storage.VerifyField(param.Attribute, storage.Filterable); err != nil {
 			return nil, err
 		}
 
 		expressions = append(expressions, exp.Ex{
-			usersTable + "." + param.Attribute: exp.Op{operator: param.Value},
+			usersTable + "." + param.Attribute: exp.Op{operator: operatorValue(param)},
 		})
 	}
 
 	return expressions, nil
 }
 
+// groupToSqlExp converts storage.FilterGroup into a goqu.Expression joining
+// the group's params and subgroups with the group's logical operator.
+func groupToSqlExp(group storage.FilterGroup) (exp.Expression, error) {
+	expressions, err := filterToSqlExp(group.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, subgroup := range group.Groups {
+		if subgroup.IsEmpty() {
+			continue
+		}
+
+		expression, err := groupToSqlExp(subgroup)
+		if err != nil {
+			return nil, err
+		}
+
+		expressions = append(expressions, expression)
+	}
+
+	switch group.Logic {
+	case storage.And:
+		return goqu.And(expressions...), nil
+	case storage.Or:
+		return goqu.Or(expressions...), nil
+	default:
+		return nil, fmt.Errorf("%w: invalid logical operator", storage.ErrInvalidQuery)
+	}
+}
+
 // matchOperator returns a goqu operator corresponding to the filter.Operator specs.
 func matchOperator(operator filter.Operator) (string, error) {
 	switch operator {
@@ -46,27 +80,26 @@ func matchOperator(operator filter.Operator) (string, error) {
 		return "lt", nil
 	case filter.LesserThanOrEqual:
 		return "lte", nil
+	case storage.Like, storage.Prefix:
+		return "like", nil
+	case storage.ILike:
+		return "ilike", nil
+	case storage.In:
+		return "in", nil
 	default:
-		return "", errors.New("invalid operator")
+		return "", fmt.Errorf("%w: invalid operator %q", storage.ErrInvalidQuery, operator)
 	}
 }
 
-// verifyField check whether provided field name is one of dataset's fields
-// based on associated tags.
-func verifyField(input string) error {
-	datasetType := reflect.TypeOf(userDataset{})
-
-	for i := 0; i < datasetType.NumField(); i++ {
-		field := datasetType.Field(i)
-		tag, ok := field.Tag.Lookup("db")
-		if !ok {
-			return ErrTagNotFound
-		}
-
-		if tag == input {
-			return nil
-		}
+// operatorValue returns the param's value in a form expected by the goqu operator
+// corresponding to the param's operator.
+func operatorValue(param filter.Parameter) interface{} {
+	switch param.Operator {
+	case storage.Prefix:
+		return likeEscaper.Replace(param.Value) + "%"
+	case storage.In:
+		return strings.Split(param.Value, storage.InValueSeparator)
+	default:
+		return param.Value
 	}
-
-	return ErrTagNotFound
 }
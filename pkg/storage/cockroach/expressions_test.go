@@ -1,41 +1,173 @@
 package cockroach
 
-import "testing"
+import (
+	"testing"
 
-func Test_verifyField(t *testing.T) {
-	type args struct {
-		input string
+	"github.com/doug-martin/goqu/v9"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/krixlion/dev_forum-lib/filter"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+)
+
+func Test_filterToSqlExp(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   filter.Filter
+		wantSQL  string
+		wantArgs []interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "Test if translates eq operator",
+			params:   filter.Filter{{Attribute: "name", Operator: filter.Equal, Value: "kri"}},
+			wantSQL:  `SELECT * FROM "users" WHERE ("users"."name" = $1)`,
+			wantArgs: []interface{}{"kri"},
+		},
+		{
+			name:     "Test if translates like operator",
+			params:   filter.Filter{{Attribute: "name", Operator: storage.Like, Value: "k_i%"}},
+			wantSQL:  `SELECT * FROM "users" WHERE ("users"."name" LIKE $1)`,
+			wantArgs: []interface{}{"k_i%"},
+		},
+		{
+			name:     "Test if translates ilike operator",
+			params:   filter.Filter{{Attribute: "email", Operator: storage.ILike, Value: "%@TEST.test"}},
+			wantSQL:  `SELECT * FROM "users" WHERE ("users"."email" ILIKE $1)`,
+			wantArgs: []interface{}{"%@TEST.test"},
+		},
+		{
+			name:     "Test if prefix operator escapes wildcards",
+			params:   filter.Filter{{Attribute: "name", Operator: storage.Prefix, Value: "k_i%"}},
+			wantSQL:  `SELECT * FROM "users" WHERE ("users"."name" LIKE $1)`,
+			wantArgs: []interface{}{`k\_i\%%`},
+		},
+		{
+			name:     "Test if in operator splits values",
+			params:   filter.Filter{{Attribute: "id", Operator: storage.In, Value: "1,2,3"}},
+			wantSQL:  `SELECT * FROM "users" WHERE ("users"."id" IN ($1, $2, $3))`,
+			wantArgs: []interface{}{"1", "2", "3"},
+		},
+		{
+			name:    "Test if fails on unknown field",
+			params:  filter.Filter{{Attribute: "unknown", Operator: filter.Equal, Value: "1"}},
+			wantErr: true,
+		},
+		{
+			name:    "Test if fails on sensitive field",
+			params:  filter.Filter{{Attribute: "password", Operator: filter.GreaterThan, Value: "$2a$"}},
+			wantErr: true,
+		},
+		{
+			name:    "Test if fails on unknown operator",
+			params:  filter.Filter{{Attribute: "name", Operator: filter.Unknown, Value: "1"}},
+			wantErr: true,
+		},
 	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exps, err := filterToSqlExp(tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("filterToSqlExp() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			gotSQL, gotArgs, err := goqu.Dialect(Driver).From(usersTable).Where(exps...).Prepared(true).ToSQL()
+			if err != nil {
+				t.Errorf("Failed to build SQL: %v", err)
+				return
+			}
+
+			if gotSQL != tt.wantSQL || !cmp.Equal(gotArgs, tt.wantArgs) {
+				t.Errorf("filterToSqlExp():\n got = %v %v\n want = %v %v", gotSQL, gotArgs, tt.wantSQL, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func Test_groupToSqlExp(t *testing.T) {
 	tests := []struct {
-		name    string
-		args    args
-		wantErr bool
+		name     string
+		group    storage.FilterGroup
+		wantSQL  string
+		wantArgs []interface{}
+		wantErr  bool
 	}{
 		{
-			name: "Test if created_at field is found",
-			args: args{input: "created_at"},
+			name:    "Test if empty group matches everything",
+			group:   storage.FilterGroup{},
+			wantSQL: `SELECT * FROM "users"`,
 		},
 		{
-			name: "Test if name field is found",
-			args: args{input: "name"},
+			name: "Test if joins params with and",
+			group: storage.FilterGroup{
+				Params: filter.Filter{
+					{Attribute: "name", Operator: filter.Equal, Value: "kri"},
+					{Attribute: "id", Operator: filter.NotEqual, Value: "1"},
+				},
+			},
+			wantSQL:  `SELECT * FROM "users" WHERE (("users"."name" = $1) AND ("users"."id" != $2))`,
+			wantArgs: []interface{}{"kri", "1"},
 		},
 		{
-			name: "Test if id field is found",
-			args: args{input: "id"},
+			name: "Test if joins nested groups",
+			group: storage.FilterGroup{
+				Logic: storage.Or,
+				Params: filter.Filter{
+					{Attribute: "id", Operator: filter.Equal, Value: "1"},
+				},
+				Groups: []storage.FilterGroup{
+					{
+						Params: filter.Filter{
+							{Attribute: "name", Operator: storage.Prefix, Value: "kri"},
+							{Attribute: "id", Operator: filter.NotEqual, Value: "2"},
+						},
+					},
+					{},
+				},
+			},
+			wantSQL:  `SELECT * FROM "users" WHERE (("users"."id" = $1) OR (("users"."name" LIKE $2) AND ("users"."id" != $3)))`,
+			wantArgs: []interface{}{"1", "kri%", "2"},
 		},
 		{
-			name: "Test if email field is found",
-			args: args{input: "email"},
+			name: "Test if fails on invalid nested param",
+			group: storage.FilterGroup{
+				Groups: []storage.FilterGroup{{
+					Params: filter.Filter{{Attribute: "unknown", Operator: filter.Equal, Value: "1"}},
+				}},
+			},
+			wantErr: true,
 		},
 		{
-			name: "Test if password field is found",
-			args: args{input: "password"},
+			name:    "Test if fails on invalid logical operator",
+			group:   storage.FilterGroup{Logic: 7},
+			wantErr: true,
 		},
 	}
-	for i, tt := range tests {
+	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := verifyField(tt.args.input); (err != nil) != tt.wantErr {
-				t.Errorf("verifyField() set: %d\n error = %v, wantErr %v", i, err, tt.wantErr)
+			where, err := groupToSqlExp(tt.group)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("groupToSqlExp() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			gotSQL, gotArgs, err := goqu.Dialect(Driver).From(usersTable).Where(where).Prepared(true).ToSQL()
+			if err != nil {
+				t.Errorf("Failed to build SQL: %v", err)
+				return
+			}
+
+			if gotSQL != tt.wantSQL || !cmp.Equal(gotArgs, tt.wantArgs, cmpopts.EquateEmpty()) {
+				t.Errorf("groupToSqlExp():\n got = %v %v\n want = %v %v", gotSQL, gotArgs, tt.wantSQL, tt.wantArgs)
 			}
 		})
 	}
@@ -0,0 +1,34 @@
+package cockroach
+
+import (
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+)
+
+// sortToSqlExp returns an expression ordering results by the given field.
+func sortToSqlExp(field string, direction exp.SortDirection) (exp.OrderedExpression, error) {
+	if err := storage.VerifyField(field, storage.Sortable); err != nil {
+		return nil, err
+	}
+
+	colExp := exp.NewColumnListExpression(field)
+	return exp.NewOrderedExpression(colExp, direction, exp.NullsLastSortType), nil
+}
+
+// columnsToSqlExp returns columns to select.
+// Returns nil if no fields are given in order to select all of them.
+func columnsToSqlExp(fields []string) ([]interface{}, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	columns := make([]interface{}, 0, len(fields))
+	for _, field := range fields {
+		if err := storage.VerifyField(field, storage.Selectable); err != nil {
+			return nil, err
+		}
+		columns = append(columns, exp.NewIdentifierExpression("", usersTable, field))
+	}
+
+	return columns, nil
+}
@@ -0,0 +1,49 @@
+package cockroach
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+)
+
+func Test_userFields(t *testing.T) {
+	datasetType := reflect.TypeOf(userDataset{})
+
+	if datasetType.NumField() != len(storage.UserFields) {
+		t.Errorf("storage.UserFields has %d entries, userDataset has %d fields", len(storage.UserFields), datasetType.NumField())
+	}
+
+	for i := 0; i < datasetType.NumField(); i++ {
+		tag := datasetType.Field(i).Tag.Get("db")
+		if _, ok := storage.UserFields[tag]; !ok {
+			t.Errorf("Capabilities of the %q column are not defined", tag)
+		}
+	}
+}
+
+func Test_sortToSqlExp(t *testing.T) {
+	tests := []struct {
+		name    string
+		field   string
+		wantErr bool
+	}{
+		{
+			name:  "Test if sorts on sortable field",
+			field: "name",
+		},
+		{
+			name:    "Test if fails on sensitive field",
+			field:   "password",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := sortToSqlExp(tt.field, exp.AscDir); (err != nil) != tt.wantErr {
+				t.Errorf("sortToSqlExp() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
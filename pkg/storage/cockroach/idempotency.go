@@ -0,0 +1,79 @@
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/cockroachdb/cockroach-go/crdb"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+)
+
+var _ storage.IdempotencyStorage = (*CockroachDB)(nil)
+
+func (db CockroachDB) ReserveIdempotencyKey(ctx context.Context, key string, fingerprint []byte, ttl time.Duration) (storage.IdempotencyRecord, error) {
+	ctx, span := db.tracer.Start(ctx, "db.ReserveIdempotencyKey")
+	defer span.End()
+
+	var record storage.IdempotencyRecord
+	err := crdb.ExecuteTx(ctx, db.conn.DB, nil, func(tx *sql.Tx) error {
+		// Expired records are taken over since the TTL job deletes them only periodically.
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO "idempotency_keys" (key, fingerprint, expires_at) VALUES ($1, $2, current_timestamp() + $3::INTERVAL)
+			ON CONFLICT (key) DO UPDATE SET fingerprint = excluded.fingerprint, response = NULL, expires_at = excluded.expires_at
+			WHERE "idempotency_keys".expires_at <= current_timestamp()`,
+			key, fingerprint, interval(ttl))
+		if err != nil {
+			return err
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if n == 1 {
+			record = storage.IdempotencyRecord{Fingerprint: fingerprint}
+			return nil
+		}
+
+		if err := tx.QueryRowContext(ctx, `SELECT fingerprint, response FROM "idempotency_keys" WHERE key = $1`, key).Scan(&record.Fingerprint, &record.Response); err != nil {
+			return err
+		}
+
+		return storage.ErrIdempotencyKeyExists
+	})
+	if err != nil && !errors.Is(err, storage.ErrIdempotencyKeyExists) {
+		tracing.SetSpanErr(span, err)
+		return storage.IdempotencyRecord{}, err
+	}
+
+	return record, err
+}
+
+func (db CockroachDB) SaveIdempotencyResponse(ctx context.Context, key string, response []byte, ttl time.Duration) error {
+	ctx, span := db.tracer.Start(ctx, "db.SaveIdempotencyResponse")
+	defer span.End()
+
+	_, err := db.conn.ExecContext(ctx, `UPDATE "idempotency_keys" SET response = $2, expires_at = current_timestamp() + $3::INTERVAL WHERE key = $1`, key, response, interval(ttl))
+	if err != nil {
+		tracing.SetSpanErr(span, err)
+		return err
+	}
+
+	return nil
+}
+
+func (db CockroachDB) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	ctx, span := db.tracer.Start(ctx, "db.ReleaseIdempotencyKey")
+	defer span.End()
+
+	if _, err := db.conn.ExecContext(ctx, `DELETE FROM "idempotency_keys" WHERE key = $1 AND response IS NULL`, key); err != nil {
+		tracing.SetSpanErr(span, err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package cockroach
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+)
+
+func TestDB_IdempotencyKeys(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration idempotency keys test.")
+	}
+
+	db := setUpDB()
+	defer db.Close()
+
+	ctx := context.Background()
+	fingerprint := []byte("fingerprint")
+	response := []byte("response")
+
+	if _, err := db.ReserveIdempotencyKey(ctx, "key", fingerprint, time.Minute); err != nil {
+		t.Fatalf("db.ReserveIdempotencyKey() error = %v", err)
+	}
+
+	got, err := db.ReserveIdempotencyKey(ctx, "key", []byte("other"), time.Minute)
+	if !errors.Is(err, storage.ErrIdempotencyKeyExists) {
+		t.Fatalf("db.ReserveIdempotencyKey() on a pending key error = %v, want %v", err, storage.ErrIdempotencyKeyExists)
+	}
+
+	if want := (storage.IdempotencyRecord{Fingerprint: fingerprint}); !cmp.Equal(got, want) {
+		t.Errorf("db.ReserveIdempotencyKey() on a pending key:\n got = %v\n want = %v", got, want)
+	}
+
+	if err := db.SaveIdempotencyResponse(ctx, "key", response, time.Minute); err != nil {
+		t.Fatalf("db.SaveIdempotencyResponse() error = %v", err)
+	}
+
+	// Completed requests must not be released.
+	if err := db.ReleaseIdempotencyKey(ctx, "key"); err != nil {
+		t.Fatalf("db.ReleaseIdempotencyKey() error = %v", err)
+	}
+
+	got, err = db.ReserveIdempotencyKey(ctx, "key", fingerprint, time.Minute)
+	if !errors.Is(err, storage.ErrIdempotencyKeyExists) {
+		t.Fatalf("db.ReserveIdempotencyKey() on a completed key error = %v, want %v", err, storage.ErrIdempotencyKeyExists)
+	}
+
+	if want := (storage.IdempotencyRecord{Fingerprint: fingerprint, Response: response}); !cmp.Equal(got, want) {
+		t.Errorf("db.ReserveIdempotencyKey() on a completed key:\n got = %v\n want = %v", got, want)
+	}
+
+	// Expired keys are taken over.
+	if _, err := db.ReserveIdempotencyKey(ctx, "expiring", fingerprint, time.Millisecond); err != nil {
+		t.Fatalf("db.ReserveIdempotencyKey() error = %v", err)
+	}
+
+	time.Sleep(time.Millisecond * 10)
+
+	if _, err := db.ReserveIdempotencyKey(ctx, "expiring", []byte("other"), time.Minute); err != nil {
+		t.Errorf("db.ReserveIdempotencyKey() on an expired key error = %v", err)
+	}
+}
@@ -0,0 +1,301 @@
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/krixlion/dev_forum-user/migrations"
+	"github.com/lib/pq"
+	"github.com/pressly/goose/v3"
+)
+
+// ErrSchemaAhead is returned when the database schema has migrations
+// applied which are newer than the latest migration known to the binary.
+var ErrSchemaAhead = errors.New("database schema is ahead of the service")
+
+const (
+	migrationLockTable = "migration_lock"
+	migrationLockId    = 1
+
+	// undefinedTable is the SQLSTATE returned when querying a non-existent table.
+	undefinedTable = "42P01"
+
+	lockRetryInterval = time.Second
+)
+
+var setUpGooseOnce sync.Once
+var errSetUpGoose error
+
+// setUpGoose points goose to the embedded migrations.
+// Goose is configured with globals so it's done only once.
+func setUpGoose() error {
+	setUpGooseOnce.Do(func() {
+		goose.SetBaseFS(&migrations.EmbedPath)
+		errSetUpGoose = goose.SetDialect(Driver)
+	})
+	return errSetUpGoose
+}
+
+// Migrate applies all pending embedded migrations while holding the migration lock.
+// It blocks until the lock is acquired or the context is done.
+func (db CockroachDB) Migrate(ctx context.Context, owner string, lockTTL time.Duration) error {
+	ctx, span := db.tracer.Start(ctx, "cockroach.Migrate")
+	defer span.End()
+
+	return db.WithMigrationLock(ctx, owner, lockTTL, func(conn *sql.DB) error {
+		if err := goose.Up(conn, "."); err != nil {
+			return fmt.Errorf("failed to migrate: %w", err)
+		}
+		return nil
+	})
+}
+
+// WithMigrationLock calls migrate with goose set up to use the embedded migrations
+// while holding a cluster-wide lock, so that replicas starting at the same time
+// and manually run migrations do not race each other.
+// It blocks until the lock is acquired or the context is done.
+//
+// The lock is a lease held by the owner and renewed every third of lockTTL.
+// If the owner dies without releasing it, others take it over once it expires.
+func (db CockroachDB) WithMigrationLock(ctx context.Context, owner string, lockTTL time.Duration, migrate func(*sql.DB) error) (err error) {
+	if err := setUpGoose(); err != nil {
+		return err
+	}
+
+	if err := db.acquireMigrationLock(ctx, owner, lockTTL); err != nil {
+		return err
+	}
+	defer func() {
+		// Release the lock even if the context is already cancelled.
+		err = errors.Join(err, db.releaseMigrationLock(context.WithoutCancel(ctx), owner))
+	}()
+
+	renewCtx, stopRenewing := context.WithCancel(ctx)
+	defer stopRenewing()
+	go db.renewMigrationLock(renewCtx, owner, lockTTL)
+
+	return migrate(db.conn.DB)
+}
+
+// CheckSchemaVersion returns ErrSchemaAhead if the database has migrations applied
+// which are newer than the latest embedded migration, eg. after a rollback
+// of the service to an older version.
+func (db CockroachDB) CheckSchemaVersion(ctx context.Context) error {
+	ctx, span := db.tracer.Start(ctx, "cockroach.CheckSchemaVersion")
+	defer span.End()
+
+	if err := setUpGoose(); err != nil {
+		return err
+	}
+
+	known, err := latestMigrationVersion()
+	if err != nil {
+		return err
+	}
+
+	current, err := db.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	if current > known {
+		return fmt.Errorf("%w: schema version %d, latest known migration %d", ErrSchemaAhead, current, known)
+	}
+
+	return nil
+}
+
+func latestMigrationVersion() (int64, error) {
+	migrations, err := goose.CollectMigrations(".", 0, goose.MaxVersion)
+	if err != nil {
+		return 0, fmt.Errorf("failed to collect migrations: %w", err)
+	}
+
+	last, err := migrations.Last()
+	if err != nil {
+		return 0, err
+	}
+
+	return last.Version, nil
+}
+
+// SchemaVersion returns the latest applied migration version
+// or 0 if no migration was ever applied.
+// Unlike goose.GetDBVersion it does not create the version table if it's missing.
+func (db CockroachDB) SchemaVersion(ctx context.Context) (int64, error) {
+	query := fmt.Sprintf("SELECT COALESCE(MAX(version_id), 0) FROM %s WHERE is_applied", goose.TableName())
+
+	var version int64
+	if err := db.conn.QueryRowContext(ctx, query).Scan(&version); err != nil {
+		if isUndefinedTable(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	return version, nil
+}
+
+// MigrationState describes whether an embedded migration is applied to the database.
+type MigrationState struct {
+	Version   int64
+	Source    string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// MigrationStates returns the state of every embedded migration in ascending order of versions.
+func (db CockroachDB) MigrationStates(ctx context.Context) ([]MigrationState, error) {
+	ctx, span := db.tracer.Start(ctx, "cockroach.MigrationStates")
+	defer span.End()
+
+	if err := setUpGoose(); err != nil {
+		return nil, err
+	}
+
+	migrations, err := goose.CollectMigrations(".", 0, goose.MaxVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect migrations: %w", err)
+	}
+
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, 0, len(migrations))
+	for _, migration := range migrations {
+		appliedAt, ok := applied[migration.Version]
+		states = append(states, MigrationState{
+			Version:   migration.Version,
+			Source:    migration.Source,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+
+	return states, nil
+}
+
+// appliedMigrations returns the time every currently applied migration was applied at.
+func (db CockroachDB) appliedMigrations(ctx context.Context) (map[int64]time.Time, error) {
+	// Goose appends a record on every up and down migration,
+	// so only the most recent record of each version is relevant.
+	query := fmt.Sprintf("SELECT version_id, is_applied, tstamp FROM %s ORDER BY id DESC", goose.TableName())
+
+	rows, err := db.conn.QueryContext(ctx, query)
+	if err != nil {
+		if isUndefinedTable(err) {
+			return map[int64]time.Time{}, nil
+		}
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+	seen := map[int64]bool{}
+	for rows.Next() {
+		var version int64
+		var isApplied bool
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &isApplied, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+		}
+
+		if seen[version] {
+			continue
+		}
+		seen[version] = true
+
+		if isApplied {
+			applied[version] = appliedAt
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+
+	return applied, nil
+}
+
+func isUndefinedTable(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == undefinedTable
+}
+
+func (db CockroachDB) acquireMigrationLock(ctx context.Context, owner string, ttl time.Duration) error {
+	createTable := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id INT PRIMARY KEY,
+		owner TEXT NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL
+	)`, migrationLockTable)
+
+	if _, err := db.conn.ExecContext(ctx, createTable); err != nil {
+		return fmt.Errorf("failed to create migration lock table: %w", err)
+	}
+
+	// Take the lock if it's free, expired or already ours.
+	query := fmt.Sprintf(`INSERT INTO %[1]s (id, owner, expires_at) VALUES ($1, $2, now() + $3::INTERVAL)
+		ON CONFLICT (id) DO UPDATE SET owner = excluded.owner, expires_at = excluded.expires_at
+		WHERE %[1]s.expires_at < now() OR %[1]s.owner = excluded.owner`, migrationLockTable)
+
+	for {
+		result, err := db.conn.ExecContext(ctx, query, migrationLockId, owner, interval(ttl))
+		if err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+
+		if n, err := result.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		} else if n == 1 {
+			return nil
+		}
+
+		timer := time.NewTimer(lockRetryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("failed to acquire migration lock: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// renewMigrationLock extends the lock lease until the context is done.
+func (db CockroachDB) renewMigrationLock(ctx context.Context, owner string, ttl time.Duration) {
+	query := fmt.Sprintf("UPDATE %s SET expires_at = now() + $3::INTERVAL WHERE id = $1 AND owner = $2", migrationLockTable)
+
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// A failed renewal is retried on the next tick.
+			// The lease is long enough to survive a couple of failures.
+			db.conn.ExecContext(ctx, query, migrationLockId, owner, interval(ttl))
+		}
+	}
+}
+
+func (db CockroachDB) releaseMigrationLock(ctx context.Context, owner string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1 AND owner = $2", migrationLockTable)
+
+	if _, err := db.conn.ExecContext(ctx, query, migrationLockId, owner); err != nil {
+		return fmt.Errorf("failed to release migration lock: %w", err)
+	}
+
+	return nil
+}
+
+// interval formats the duration as a postgres interval literal.
+func interval(d time.Duration) string {
+	return fmt.Sprintf("%d milliseconds", d.Milliseconds())
+}
@@ -0,0 +1,78 @@
+package cockroach
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDB_Migrate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration db.Migrate test.")
+	}
+
+	db := setUpDB()
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	// Replicas starting at the same time should wait for each other instead of failing.
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = db.Migrate(ctx, string(rune('a'+i)), time.Second*5)
+		}(i)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		t.Errorf("CockroachDB.Migrate() error = %v", err)
+		return
+	}
+
+	if err := db.CheckSchemaVersion(ctx); err != nil {
+		t.Errorf("CockroachDB.CheckSchemaVersion() error = %v", err)
+	}
+}
+
+func TestDB_acquireMigrationLock(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration db.acquireMigrationLock test.")
+	}
+
+	db := setUpDB()
+	defer db.Close()
+
+	ctx := context.Background()
+
+	if err := db.acquireMigrationLock(ctx, "holder", time.Minute); err != nil {
+		t.Errorf("CockroachDB.acquireMigrationLock() error = %v", err)
+		return
+	}
+	defer db.releaseMigrationLock(ctx, "holder")
+
+	// The owner should be able to re-acquire its own lock.
+	if err := db.acquireMigrationLock(ctx, "holder", time.Minute); err != nil {
+		t.Errorf("CockroachDB.acquireMigrationLock() re-acquire error = %v", err)
+		return
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Second*2)
+	defer cancel()
+
+	if err := db.acquireMigrationLock(timeoutCtx, "contender", time.Minute); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("CockroachDB.acquireMigrationLock() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func Test_interval(t *testing.T) {
+	if got, want := interval(time.Second*90), "90000 milliseconds"; got != want {
+		t.Errorf("interval():\n got = %v\n want = %v", got, want)
+	}
+}
@@ -0,0 +1,59 @@
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cockroach-go/crdb"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+	"github.com/krixlion/dev_forum-user/pkg/username"
+	"github.com/lib/pq"
+)
+
+var _ storage.NameStorage = (*CockroachDB)(nil)
+
+// uniqueViolation is the SQLSTATE returned when an insert or update violates a unique constraint.
+const uniqueViolation = "23505"
+
+// emailKeyConstraint is the unique index on the email keys of users.
+const emailKeyConstraint = "users_email_key_key"
+
+func (db CockroachDB) NameReleasedAt(ctx context.Context, skeleton, userId string) (time.Time, error) {
+	ctx, span := db.tracer.Start(ctx, "db.NameReleasedAt")
+	defer span.End()
+
+	var releasedAt sql.NullTime
+	if err := crdb.Execute(func() error {
+		return db.conn.QueryRowContext(ctx, `SELECT max(released_at) FROM "username_history" WHERE skeleton = $1 AND user_id <> $2`, skeleton, userId).Scan(&releasedAt)
+	}); err != nil {
+		tracing.SetSpanErr(span, err)
+		return time.Time{}, err
+	}
+
+	return releasedAt.Time, nil
+}
+
+// releaseName records the name the user no longer holds in their name history.
+func releaseName(ctx context.Context, tx *sql.Tx, userId, name string) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO "username_history" (user_id, name, skeleton) VALUES ($1, $2, $3)`, userId, name, username.Skeleton(name))
+	return err
+}
+
+// takenErr wraps unique violations with storage.ErrEmailTaken, if raised by the unique email keys of users,
+// or else with storage.ErrNameTaken, since the rest are raised by the unique names and name skeletons.
+func takenErr(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != uniqueViolation {
+		return err
+	}
+
+	if pqErr.Constraint == emailKeyConstraint || strings.Contains(pqErr.Message, emailKeyConstraint) {
+		return fmt.Errorf("%w: %w", storage.ErrEmailTaken, err)
+	}
+	return fmt.Errorf("%w: %w", storage.ErrNameTaken, err)
+}
@@ -0,0 +1,98 @@
+package cockroach
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-user/pkg/entity"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+	"github.com/krixlion/dev_forum-user/pkg/username"
+	"github.com/lib/pq"
+)
+
+func TestDB_names(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping db names integration test.")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	db := setUpDB()
+	audit := storage.AuditInfo{Actor: "test"}
+
+	user := entity.User{Id: "renamed", Name: "renamed", Email: "renamed@example.com", Password: "hash", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := db.Create(ctx, user, audit); err != nil {
+		t.Fatalf("DB.Create() error = %v", err)
+	}
+
+	lookAlike := entity.User{Id: "look-alike", Name: "RENAMED", Email: "look-alike@example.com", Password: "hash", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := db.Create(ctx, lookAlike, audit); !errors.Is(err, storage.ErrNameTaken) {
+		t.Errorf("DB.Create() look-alike name error = %v, want %v", err, storage.ErrNameTaken)
+	}
+
+	if err := db.Update(ctx, entity.User{Id: user.Id, Name: "renamed2", UpdatedAt: time.Now()}, audit); err != nil {
+		t.Fatalf("DB.Update() error = %v", err)
+	}
+
+	releasedAt, err := db.NameReleasedAt(ctx, username.Skeleton(user.Name), "other")
+	if err != nil {
+		t.Fatalf("DB.NameReleasedAt() error = %v", err)
+	}
+	if releasedAt.IsZero() {
+		t.Errorf("DB.NameReleasedAt() = zero time, want the time the name was released")
+	}
+
+	releasedAt, err = db.NameReleasedAt(ctx, username.Skeleton(user.Name), user.Id)
+	if err != nil {
+		t.Fatalf("DB.NameReleasedAt() error = %v", err)
+	}
+	if !releasedAt.IsZero() {
+		t.Errorf("DB.NameReleasedAt() = %v, want zero time for names released by the same user", releasedAt)
+	}
+}
+
+func Test_takenErr(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr error
+	}{
+		{
+			name:    "Test if maps violations of email keys",
+			err:     &pq.Error{Code: uniqueViolation, Constraint: emailKeyConstraint},
+			wantErr: storage.ErrEmailTaken,
+		},
+		{
+			name:    "Test if maps violations of email keys named only in the message",
+			err:     &pq.Error{Code: uniqueViolation, Message: `duplicate key value violates unique constraint "users_email_key_key"`},
+			wantErr: storage.ErrEmailTaken,
+		},
+		{
+			name:    "Test if maps other unique violations to names",
+			err:     &pq.Error{Code: uniqueViolation, Constraint: "users_name_skeleton_key"},
+			wantErr: storage.ErrNameTaken,
+		},
+		{
+			name:    "Test if keeps other errors",
+			err:     &pq.Error{Code: "40001"},
+			wantErr: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := takenErr(tt.err)
+			if !errors.Is(got, tt.err) {
+				t.Errorf("takenErr() = %v, want wrapped %v", got, tt.err)
+			}
+
+			for _, err := range []error{storage.ErrEmailTaken, storage.ErrNameTaken} {
+				if errors.Is(got, err) != (err == tt.wantErr) {
+					t.Errorf("takenErr() = %v, want wrapped %v", got, tt.wantErr)
+				}
+			}
+		})
+	}
+}
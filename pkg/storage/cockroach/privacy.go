@@ -0,0 +1,114 @@
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/cockroachdb/cockroach-go/crdb"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	"github.com/krixlion/dev_forum-user/pkg/entity"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+	"github.com/krixlion/dev_forum-user/pkg/username"
+)
+
+var _ storage.PrivacyStorage = (*CockroachDB)(nil)
+
+func (db CockroachDB) ListArticleIds(ctx context.Context, userId string) ([]string, error) {
+	ctx, span := db.tracer.Start(ctx, "db.ListArticleIds")
+	defer span.End()
+
+	ids := []string{}
+	if err := crdb.Execute(func() error {
+		return db.conn.SelectContext(ctx, &ids, `SELECT article_id FROM "user_articles" WHERE user_id = $1 ORDER BY article_id`, userId)
+	}); err != nil {
+		tracing.SetSpanErr(span, err)
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (db CockroachDB) Erase(ctx context.Context, id, tombstone string, audit storage.AuditInfo) error {
+	ctx, span := db.tracer.Start(ctx, "db.Erase")
+	defer span.End()
+
+	err := crdb.ExecuteTx(ctx, db.conn.DB, nil, func(tx *sql.Tx) error {
+		old, err := lockAudited(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		// Erasing the user again would hash the hashed email.
+		if old.Name == tombstone {
+			return nil
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE "users" SET name = $2, name_skeleton = $3, email = sha256(email), email_key = NULL, password = '', updated_at = current_timestamp() WHERE id = $1`,
+			id, tombstone, username.Skeleton(tombstone)); err != nil {
+			return err
+		}
+
+		// Released names are personal data too, so erased users' names can be taken right away.
+		if _, err := tx.ExecContext(ctx, `DELETE FROM "username_history" WHERE user_id = $1`, id); err != nil {
+			return err
+		}
+
+		if err := anonymizeAudit(ctx, tx, id); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, id, entity.AuditErase, storage.Anonymize(storage.Removal(old)), audit)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %w", storage.ErrNotFound, err)
+		}
+		tracing.SetSpanErr(span, err)
+		return err
+	}
+
+	return nil
+}
+
+// anonymizeAudit redacts all values recorded in the audit entries of the user.
+func anonymizeAudit(ctx context.Context, tx *sql.Tx, userId string) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, changes FROM "user_audit" WHERE user_id = $1`, userId)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	entries := map[string][]entity.FieldChange{}
+	for rows.Next() {
+		var id string
+		var body []byte
+		if err := rows.Scan(&id, &body); err != nil {
+			return err
+		}
+
+		var changes []entity.FieldChange
+		if err := json.Unmarshal(body, &changes); err != nil {
+			return err
+		}
+		entries[id] = changes
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, changes := range entries {
+		body, err := json.Marshal(storage.Anonymize(changes))
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE "user_audit" SET changes = $2 WHERE id = $1`, id, body); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package cockroach
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/filter"
+	"github.com/krixlion/dev_forum-user/pkg/entity"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+)
+
+func TestDB_Erase(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping db.Erase integration test.")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	db := setUpDB()
+	audit := storage.AuditInfo{Actor: "identity:admin-service"}
+
+	user := entity.User{Id: "erased", Name: "erased", Email: "erased@example.com", Password: "hash", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := db.Create(ctx, user, audit); err != nil {
+		t.Fatalf("DB.Create() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		// Erasing the user again must not change it.
+		if err := db.Erase(ctx, user.Id, "erased-tombstone", audit); err != nil {
+			t.Fatalf("DB.Erase() error = %v", err)
+		}
+	}
+
+	got, err := db.Get(ctx, filter.Filter{{Attribute: "id", Operator: filter.Equal, Value: user.Id}}, nil)
+	if err != nil {
+		t.Fatalf("DB.Get() error = %v", err)
+	}
+
+	// The email is replaced with its hex-encoded SHA-256 hash.
+	if got.Name != "erased-tombstone" || got.Email == user.Email || len(got.Email) != 64 || got.Password != "" {
+		t.Errorf("DB.Erase() left personal data: %+v", got)
+	}
+
+	entries, err := db.ListAuditEntries(ctx, user.Id, time.Time{}, time.Time{}, 0, 10)
+	if err != nil {
+		t.Fatalf("DB.ListAuditEntries() error = %v", err)
+	}
+
+	if len(entries) != 2 || entries[1].Action != entity.AuditErase {
+		t.Fatalf("DB.Erase() audit entries = %+v, want creation and erasure", entries)
+	}
+
+	for _, entry := range entries {
+		for _, change := range entry.Changes {
+			if (change.Old != "" && change.Old != storage.Redacted) || (change.New != "" && change.New != storage.Redacted) {
+				t.Errorf("DB.Erase() left personal data in the audit entry: %+v", entry)
+			}
+		}
+	}
+
+	if err := db.Erase(ctx, "not-found", "erased-not-found", audit); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("DB.Erase() error = %v, want %v", err, storage.ErrNotFound)
+	}
+}
@@ -3,13 +3,12 @@ package testdata
 import (
 	"context"
 	"database/sql"
-	"fmt"
-	"os"
 	"time"
 
 	_ "github.com/lib/pq"
 
 	"github.com/krixlion/dev_forum-lib/env"
+	"github.com/krixlion/dev_forum-user/pkg/config"
 )
 
 func init() {
@@ -22,13 +21,12 @@ func init() {
 func Seed() error {
 	env.Load("app")
 
-	port := os.Getenv("DB_PORT")
-	host := os.Getenv("DB_HOST")
-	user := os.Getenv("DB_USER")
-	pass := os.Getenv("DB_PASS")
-	dbName := os.Getenv("DB_NAME")
+	config, err := config.LoadDB(nil)
+	if err != nil {
+		return err
+	}
 
-	db, err := sql.Open("postgres", fmt.Sprintf("postgresql://%s:%s@%s:%s/%s?sslmode=disable", user, pass, host, port, dbName))
+	db, err := sql.Open("postgres", config.ConnString())
 	if err != nil {
 		return err
 	}
@@ -38,7 +36,7 @@ func Seed() error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 
-	if _, err := db.ExecContext(ctx, `TRUNCATE "users";`); err != nil {
+	if _, err := db.ExecContext(ctx, `TRUNCATE "users", "user_articles", "processed_events", "idempotency_keys", "user_audit", "username_history";`); err != nil {
 		return err
 	}
 
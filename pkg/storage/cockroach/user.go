@@ -1,22 +1,31 @@
 package cockroach
 
 import (
+	"database/sql"
 	"time"
 
 	"github.com/krixlion/dev_forum-user/pkg/entity"
+	"github.com/krixlion/dev_forum-user/pkg/username"
 )
 
 type userDataset struct {
-	Id        string `db:"id" goqu:"skipupdate,omitempty"`
-	Name      string `db:"name" goqu:"omitempty"`
-	Email     string `db:"email" goqu:"omitempty"`
-	Password  string `db:"password" goqu:"omitempty"`
-	CreatedAt string `db:"created_at" goqu:"skipupdate,omitempty"`
-	UpdatedAt string `db:"updated_at" goqu:"omitempty"`
+	Id   string `db:"id" goqu:"skipupdate,omitempty"`
+	Name string `db:"name" goqu:"omitempty"`
+	// NameSkeleton is derived from the name, so it's set only along with it.
+	NameSkeleton sql.NullString `db:"name_skeleton" goqu:"omitempty"`
+	Email        string         `db:"email" goqu:"omitempty"`
+	// EmailKey is set only along with the email. It's unique, unless it's NULL.
+	EmailKey  sql.NullString `db:"email_key" goqu:"omitempty"`
+	Password  string         `db:"password" goqu:"omitempty"`
+	CreatedAt string         `db:"created_at" goqu:"skipupdate,omitempty"`
+	UpdatedAt string         `db:"updated_at" goqu:"omitempty"`
+	// Maintained only by event consumers.
+	LastLoginAt sql.NullString `db:"last_login_at" goqu:"skipinsert,skipupdate"`
+	PostCount   int64          `db:"post_count" goqu:"skipinsert,skipupdate"`
 }
 
 func datasetFromUser(v entity.User) userDataset {
-	return userDataset{
+	dataset := userDataset{
 		Id:        v.Id,
 		Name:      v.Name,
 		Password:  v.Password,
@@ -24,29 +33,59 @@ func datasetFromUser(v entity.User) userDataset {
 		CreatedAt: v.CreatedAt.Format(time.RFC3339),
 		UpdatedAt: v.UpdatedAt.Format(time.RFC3339),
 	}
+
+	if v.Name != "" {
+		dataset.NameSkeleton = sql.NullString{String: username.Skeleton(v.Name), Valid: true}
+	}
+
+	// Users given without keys are kept unique by their exact emails.
+	if v.Email != "" {
+		key := v.EmailKey
+		if key == "" {
+			key = v.Email
+		}
+		dataset.EmailKey = sql.NullString{String: key, Valid: true}
+	}
+
+	return dataset
 }
 
 func (v userDataset) User() (entity.User, error) {
-	createdAt, err := time.Parse(time.RFC3339, v.CreatedAt)
+	createdAt, err := parseTime(v.CreatedAt)
 	if err != nil {
 		return entity.User{}, err
 	}
 
-	updatedAt, err := time.Parse(time.RFC3339, v.UpdatedAt)
+	updatedAt, err := parseTime(v.UpdatedAt)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	lastLoginAt, err := parseTime(v.LastLoginAt.String)
 	if err != nil {
 		return entity.User{}, err
 	}
 
 	return entity.User{
-		Id:        v.Id,
-		Name:      v.Name,
-		Password:  v.Password,
-		Email:     v.Email,
-		CreatedAt: createdAt,
-		UpdatedAt: updatedAt,
+		Id:          v.Id,
+		Name:        v.Name,
+		Password:    v.Password,
+		Email:       v.Email,
+		CreatedAt:   createdAt,
+		UpdatedAt:   updatedAt,
+		LastLoginAt: lastLoginAt,
+		PostCount:   v.PostCount,
 	}, nil
 }
 
+// parseTime returns a zero time if the column was not selected or is null.
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
 func usersFromDatasets(datasets []userDataset) ([]entity.User, error) {
 	users := make([]entity.User, 0, len(datasets))
 	for _, v := range datasets {
@@ -1,6 +1,7 @@
 package cockroach
 
 import (
+	"database/sql"
 	"testing"
 	"time"
 
@@ -26,10 +27,29 @@ func Test_datasetFromUser(t *testing.T) {
 				UpdatedAt: time.Now(),
 			},
 			want: userDataset{
+				Id:           "test",
+				Name:         "testname",
+				NameSkeleton: sql.NullString{String: "testname", Valid: true},
+				Email:        "test@test.test",
+				EmailKey:     sql.NullString{String: "test@test.test", Valid: true},
+				Password:     "testpass",
+				CreatedAt:    time.Now().Format(time.RFC3339),
+				UpdatedAt:    time.Now().Format(time.RFC3339),
+			},
+		},
+		{
+			name: "Test if stores the given email key",
+			arg: entity.User{
 				Id:        "test",
-				Name:      "testname",
-				Email:     "test@test.test",
-				Password:  "testpass",
+				Email:     "Test+forum@test.test",
+				EmailKey:  "test@test.test",
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+			},
+			want: userDataset{
+				Id:        "test",
+				Email:     "Test+forum@test.test",
+				EmailKey:  sql.NullString{String: "test@test.test", Valid: true},
 				CreatedAt: time.Now().Format(time.RFC3339),
 				UpdatedAt: time.Now().Format(time.RFC3339),
 			},
@@ -83,6 +103,18 @@ func Test_userFromDataset(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Test if leaves times empty when they were not selected",
+			arg: userDataset{
+				Id:   "test",
+				Name: "testname",
+			},
+			want: entity.User{
+				Id:   "test",
+				Name: "testname",
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,23 @@
+package storage
+
+import "errors"
+
+// ErrInvalidQuery is returned when a query cannot be executed
+// as requested, eg. when it filters on a non-filterable field.
+// Returned errors wrap it with details meant for the caller.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// ErrEventProcessed is returned when an event with the same id was already processed.
+var ErrEventProcessed = errors.New("event already processed")
+
+// ErrIdempotencyKeyExists is returned when a request is already stored under the idempotency key.
+var ErrIdempotencyKeyExists = errors.New("idempotency key already exists")
+
+// ErrNotFound is returned when no user matches the query.
+var ErrNotFound = errors.New("user not found")
+
+// ErrNameTaken is returned when the name looks like the name of another user.
+var ErrNameTaken = errors.New("name already taken")
+
+// ErrEmailTaken is returned when another user has an email with the same key.
+var ErrEmailTaken = errors.New("email already taken")
@@ -0,0 +1,76 @@
+package storage
+
+import "fmt"
+
+// Capability is a set of ways a field of users can be used in queries.
+type Capability int
+
+const (
+	Filterable Capability = 1 << iota
+	Sortable
+	Selectable
+	// Sensitive fields can never be filtered or sorted on,
+	// regardless of other capabilities, so that their values
+	// cannot be guessed by comparing them.
+	Sensitive
+	// Public fields can be used in queries by clients of the API.
+	// The rest are used only by the service itself, eg. to look users up by email.
+	Public
+)
+
+// UserFields holds capabilities of every field of users, named after their columns.
+// Fields not listed here cannot be used in queries at all.
+var UserFields = map[string]Capability{
+	"id":         Filterable | Sortable | Selectable | Public,
+	"name":       Filterable | Sortable | Selectable | Public,
+	"email":      Filterable | Selectable,
+	"password":   Selectable | Sensitive,
+	"created_at": Filterable | Sortable | Selectable | Public,
+	"updated_at": Filterable | Sortable | Selectable | Public,
+
+	"last_login_at": Filterable | Sortable | Selectable,
+	"post_count":    Filterable | Sortable | Selectable,
+
+	// Derived from the name only to tell apart names which look alike.
+	"name_skeleton": 0,
+	// Derived from the email only to tell apart addresses delivered to the same mailbox.
+	"email_key": 0,
+}
+
+func (c Capability) String() string {
+	switch c {
+	case Filterable:
+		return "filterable"
+	case Sortable:
+		return "sortable"
+	case Selectable:
+		return "selectable"
+	case Sensitive:
+		return "sensitive"
+	case Public:
+		return "public"
+	default:
+		return fmt.Sprintf("capability(%d)", int(c))
+	}
+}
+
+// VerifyField returns a non-nil error wrapping ErrInvalidQuery
+// if the field is unknown or lacks any of the required capabilities.
+func VerifyField(field string, required Capability) error {
+	capabilities, ok := UserFields[field]
+	if !ok {
+		return fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, field)
+	}
+
+	if capabilities&Sensitive != 0 && required&(Filterable|Sortable) != 0 {
+		return fmt.Errorf("%w: field %q is sensitive and cannot be %s", ErrInvalidQuery, field, required&(Filterable|Sortable))
+	}
+
+	for c := Filterable; c <= Public; c <<= 1 {
+		if required&c != 0 && capabilities&c == 0 {
+			return fmt.Errorf("%w: field %q is not %s", ErrInvalidQuery, field, c)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package storage_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+)
+
+func TestVerifyField(t *testing.T) {
+	type args struct {
+		field    string
+		required storage.Capability
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{name: "Test if id field is filterable", args: args{field: "id", required: storage.Filterable}},
+		{name: "Test if id field is sortable", args: args{field: "id", required: storage.Sortable}},
+		{name: "Test if id field is selectable", args: args{field: "id", required: storage.Selectable}},
+		{name: "Test if id field is public", args: args{field: "id", required: storage.Filterable | storage.Public}},
+
+		{name: "Test if name field is filterable", args: args{field: "name", required: storage.Filterable}},
+		{name: "Test if name field is sortable", args: args{field: "name", required: storage.Sortable}},
+		{name: "Test if name field is selectable", args: args{field: "name", required: storage.Selectable}},
+		{name: "Test if name field is public", args: args{field: "name", required: storage.Filterable | storage.Public}},
+
+		{name: "Test if email field is filterable", args: args{field: "email", required: storage.Filterable}},
+		{name: "Test if email field is not sortable", args: args{field: "email", required: storage.Sortable}, wantErr: true},
+		{name: "Test if email field is selectable", args: args{field: "email", required: storage.Selectable}},
+		{name: "Test if email field is not public", args: args{field: "email", required: storage.Filterable | storage.Public}, wantErr: true},
+
+		{name: "Test if password field is not filterable", args: args{field: "password", required: storage.Filterable}, wantErr: true},
+		{name: "Test if password field is not sortable", args: args{field: "password", required: storage.Sortable}, wantErr: true},
+		{name: "Test if password field is selectable", args: args{field: "password", required: storage.Selectable}},
+		{name: "Test if password field is not public", args: args{field: "password", required: storage.Public}, wantErr: true},
+
+		{name: "Test if created_at field is filterable", args: args{field: "created_at", required: storage.Filterable}},
+		{name: "Test if created_at field is sortable", args: args{field: "created_at", required: storage.Sortable}},
+		{name: "Test if created_at field is selectable", args: args{field: "created_at", required: storage.Selectable}},
+		{name: "Test if created_at field is public", args: args{field: "created_at", required: storage.Filterable | storage.Public}},
+
+		{name: "Test if updated_at field is filterable", args: args{field: "updated_at", required: storage.Filterable}},
+		{name: "Test if updated_at field is sortable", args: args{field: "updated_at", required: storage.Sortable}},
+		{name: "Test if updated_at field is selectable", args: args{field: "updated_at", required: storage.Selectable}},
+		{name: "Test if updated_at field is public", args: args{field: "updated_at", required: storage.Filterable | storage.Public}},
+
+		{name: "Test if last_login_at field is filterable", args: args{field: "last_login_at", required: storage.Filterable}},
+		{name: "Test if last_login_at field is not public", args: args{field: "last_login_at", required: storage.Filterable | storage.Public}, wantErr: true},
+
+		{name: "Test if post_count field is filterable", args: args{field: "post_count", required: storage.Filterable}},
+		{name: "Test if post_count field is not public", args: args{field: "post_count", required: storage.Filterable | storage.Public}, wantErr: true},
+
+		{name: "Test if name_skeleton field is not filterable", args: args{field: "name_skeleton", required: storage.Filterable}, wantErr: true},
+		{name: "Test if email_key field is not selectable", args: args{field: "email_key", required: storage.Selectable}, wantErr: true},
+
+		{name: "Test if unknown field is rejected", args: args{field: "unknown", required: storage.Selectable}, wantErr: true},
+		{name: "Test if qualified field is rejected", args: args{field: "users.name", required: storage.Filterable}, wantErr: true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := storage.VerifyField(tt.args.field, tt.args.required)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyField() set: %d\n error = %v, wantErr %v", i, err, tt.wantErr)
+				return
+			}
+
+			if err != nil && !errors.Is(err, storage.ErrInvalidQuery) {
+				t.Errorf("VerifyField() error = %v, want it to wrap %v", err, storage.ErrInvalidQuery)
+			}
+		})
+	}
+}
+
+func TestVerifyField_sensitive(t *testing.T) {
+	storage.UserFields["sensitive_test"] = storage.Filterable | storage.Sortable | storage.Selectable | storage.Sensitive | storage.Public
+	defer delete(storage.UserFields, "sensitive_test")
+
+	for _, required := range []storage.Capability{storage.Filterable, storage.Sortable} {
+		if err := storage.VerifyField("sensitive_test", required); err == nil {
+			t.Errorf("VerifyField() sensitive field is %s", required)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/krixlion/dev_forum-lib/filter"
+)
+
+// Operators supported by the storage on top of the ones registered in the filter package.
+const (
+	// Like matches values against an SQL LIKE pattern, eg. "kri%".
+	Like filter.Operator = "like"
+	// ILike is a case-insensitive equivalent of Like.
+	ILike filter.Operator = "ilike"
+	// Prefix matches values starting with the given string.
+	// Wildcard characters in the value are matched literally.
+	Prefix filter.Operator = "prefix"
+	// In matches any of the comma-separated values.
+	In filter.Operator = "in"
+)
+
+// InValueSeparator separates values passed to the In operator.
+const InValueSeparator = ","
+
+// Allow alphanumeric, lowercase names with underscore and dash followed by a prefixed operator within brackets.
+var paramRegexp = regexp.MustCompile(`^[a-z0-9_-]+\[\$[a-z]+\]$`)
+
+// ParseFilter works just like filter.Parse but additionally
+// accepts the operators supported only by the storage.
+// Returns nil and a nil error on empty query.
+//
+// Example input:
+//
+//	params, err := storage.ParseFilter("name[$prefix]=kri&id[$in]=1,2,3")
+func ParseFilter(query string) (filter.Filter, error) {
+	if query == "" {
+		return nil, nil
+	}
+
+	params := strings.Split(query, "&")
+	parsedParams := make(filter.Filter, 0, len(params))
+
+	for _, param := range params {
+		beforeValue, value, found := strings.Cut(param, "=")
+		if !found {
+			return nil, filter.ErrValueNotFound
+		}
+
+		if !paramRegexp.MatchString(beforeValue) {
+			return nil, filter.ErrInvalidParam
+		}
+
+		attribute, rawOperator, _ := strings.Cut(beforeValue, "[")
+		rawOperator = strings.Trim(rawOperator, "]")
+
+		operator, err := MatchOperator(rawOperator)
+		if err != nil {
+			return nil, err
+		}
+
+		parsedParams = append(parsedParams, filter.Parameter{
+			Attribute: attribute,
+			Operator:  operator,
+			Value:     value,
+		})
+	}
+
+	return parsedParams, nil
+}
+
+// MatchOperator checks if provided input is either an operator
+// registered in the filter package or one supported by the storage.
+// Returns a non-nil error if the operator is not found.
+func MatchOperator(input string) (filter.Operator, error) {
+	switch operator := filter.Operator(strings.Trim(input, "$")); operator {
+	case Like, ILike, Prefix, In:
+		return operator, nil
+	default:
+		return filter.MatchOperator(input)
+	}
+}
+
+// Logic defines how the members of a FilterGroup are joined.
+type Logic int
+
+const (
+	And Logic = iota
+	Or
+)
+
+// FilterGroup is a tree of filter params and nested groups
+// joined with the group's logical operator.
+// Zero value matches everything.
+type FilterGroup struct {
+	Logic  Logic
+	Params filter.Filter
+	Groups []FilterGroup
+}
+
+// IsEmpty reports whether the group and all of its subgroups contain no params.
+func (g FilterGroup) IsEmpty() bool {
+	if len(g.Params) > 0 {
+		return false
+	}
+
+	for _, group := range g.Groups {
+		if !group.IsEmpty() {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,70 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-lib/filter"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    filter.Filter
+		wantErr bool
+	}{
+		{
+			name:  "Test if returns nil on empty query",
+			query: "",
+			want:  nil,
+		},
+		{
+			name:  "Test if parses operators registered in the filter package",
+			query: "name[$eq]=john&created_at[$gte]=2023-01-01",
+			want: filter.Filter{
+				{Attribute: "name", Operator: filter.Equal, Value: "john"},
+				{Attribute: "created_at", Operator: filter.GreaterThanOrEqual, Value: "2023-01-01"},
+			},
+		},
+		{
+			name:  "Test if parses operators supported by the storage",
+			query: "name[$prefix]=kri&email[$ilike]=%@test.test&name[$like]=k_i%&id[$in]=1,2,3",
+			want: filter.Filter{
+				{Attribute: "name", Operator: storage.Prefix, Value: "kri"},
+				{Attribute: "email", Operator: storage.ILike, Value: "%@test.test"},
+				{Attribute: "name", Operator: storage.Like, Value: "k_i%"},
+				{Attribute: "id", Operator: storage.In, Value: "1,2,3"},
+			},
+		},
+		{
+			name:    "Test if fails on unknown operator",
+			query:   "name[$regex]=kri",
+			wantErr: true,
+		},
+		{
+			name:    "Test if fails on missing value",
+			query:   "name[$prefix]",
+			wantErr: true,
+		},
+		{
+			name:    "Test if fails on invalid param",
+			query:   "Name[prefix]=kri",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := storage.ParseFilter(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseFilter() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !cmp.Equal(got, tt.want) {
+				t.Errorf("ParseFilter():\n got = %v\n want = %v\n %v", got, tt.want, cmp.Diff(got, tt.want))
+			}
+		})
+	}
+}
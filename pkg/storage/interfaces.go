@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/krixlion/dev_forum-lib/event"
 	"github.com/krixlion/dev_forum-lib/filter"
@@ -17,22 +18,94 @@ type CQRStorage interface {
 type Storage interface {
 	Getter
 	Writer
+	AuditStorage
+	PrivacyStorage
+	NameStorage
+	EmailStorage
+	// Ping verifies the connection to the storage is alive.
+	Ping(ctx context.Context) error
 }
 
 type Getter interface {
 	io.Closer
-	Get(ctx context.Context, filter filter.Filter) (entity.User, error)
-	GetMultiple(ctx context.Context, offset, limit string, filter filter.Filter) ([]entity.User, error)
+	// Get and GetMultiple return only the given fields of the users
+	// or all of them if no fields are given.
+	Get(ctx context.Context, filter filter.Filter, fields []string) (entity.User, error)
+	GetMultiple(ctx context.Context, offset, limit string, filter FilterGroup, fields []string) ([]entity.User, error)
+	// Search returns users with names similar to the given phrase ordered by relevance.
+	Search(ctx context.Context, phrase string, offset, limit uint) ([]entity.User, error)
 }
 
+// Writer records every change in the audit log within the same transaction.
+// Names released by renamed and deleted users are recorded in their name history.
+// Create and Update return ErrNameTaken if the name looks like the name of another user
+// and ErrEmailTaken if another user has an email with the same key.
+// Update and Delete return ErrNotFound if the user does not exist.
 type Writer interface {
 	io.Closer
-	Create(context.Context, entity.User) error
-	Update(context.Context, entity.User) error
-	Delete(ctx context.Context, id string) error
+	Create(ctx context.Context, user entity.User, audit AuditInfo) error
+	// Update saves the user's non-empty fields.
+	Update(ctx context.Context, user entity.User, audit AuditInfo) error
+	Delete(ctx context.Context, id string, audit AuditInfo) error
+}
+
+// PrivacyStorage serves requests of data subjects.
+type PrivacyStorage interface {
+	// ListArticleIds returns ids of articles attributed to the user.
+	ListArticleIds(ctx context.Context, userId string) ([]string, error)
+	// Erase replaces the user's name with the tombstone, the email with its SHA-256 hash
+	// and clears the password, keeping the id. Personal data is redacted from the user's audit entries,
+	// their name history is deleted and the erasure is recorded. Returns ErrNotFound if the user does not exist.
+	Erase(ctx context.Context, id, tombstone string, audit AuditInfo) error
+}
+
+// NameStorage looks up names released by users.
+type NameStorage interface {
+	// NameReleasedAt returns when a name with the skeleton was last released by a user other than userId
+	// or a zero time if it never was.
+	NameReleasedAt(ctx context.Context, skeleton, userId string) (time.Time, error)
+}
+
+// EmailStorage looks up email addresses of users.
+type EmailStorage interface {
+	// EmailTaken reports whether a user other than userId has the address
+	// or, if the key is not empty, an address with the key, see email.Key.
+	EmailTaken(ctx context.Context, address, key, userId string) (bool, error)
 }
 
 type Eventstore interface {
 	event.Consumer
 	Writer
 }
+
+// EventStorage keeps denormalized user data consistent with events of other services.
+// Every method applies its change only once per eventId and returns
+// ErrEventProcessed for events which were already processed.
+type EventStorage interface {
+	// RecordLogin sets the time of the user's last login unless a later one is already recorded.
+	RecordLogin(ctx context.Context, eventId, userId string, at time.Time) error
+	// AddArticle attributes the article to the user and increments their post count.
+	AddArticle(ctx context.Context, eventId, userId, articleId string) error
+	// RemoveArticle decrements the post count of the article's author.
+	RemoveArticle(ctx context.Context, eventId, articleId string) error
+}
+
+// IdempotencyRecord is a request stored under a client-provided idempotency key.
+type IdempotencyRecord struct {
+	// Fingerprint identifies the payload of the request.
+	Fingerprint []byte
+	// Response is nil until the request completes.
+	Response []byte
+}
+
+// IdempotencyStorage stores responses of requests under client-provided keys until they expire.
+type IdempotencyStorage interface {
+	// ReserveIdempotencyKey stores the key with the fingerprint of a request for the given ttl.
+	// If an unexpired record is stored under the key, it's returned along with ErrIdempotencyKeyExists.
+	ReserveIdempotencyKey(ctx context.Context, key string, fingerprint []byte, ttl time.Duration) (IdempotencyRecord, error)
+	// SaveIdempotencyResponse stores the response of the request reserved under the key
+	// and keeps it for the given ttl.
+	SaveIdempotencyResponse(ctx context.Context, key string, response []byte, ttl time.Duration) error
+	// ReleaseIdempotencyKey removes the key so that the request can be retried.
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+}
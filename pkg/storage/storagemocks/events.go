@@ -0,0 +1,33 @@
+package storagemocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type EventStorage struct {
+	*mock.Mock
+}
+
+func NewEventStorage() EventStorage {
+	return EventStorage{
+		Mock: new(mock.Mock),
+	}
+}
+
+func (m EventStorage) RecordLogin(ctx context.Context, eventId, userId string, at time.Time) error {
+	args := m.Called(ctx, eventId, userId, at)
+	return args.Error(0)
+}
+
+func (m EventStorage) AddArticle(ctx context.Context, eventId, userId, articleId string) error {
+	args := m.Called(ctx, eventId, userId, articleId)
+	return args.Error(0)
+}
+
+func (m EventStorage) RemoveArticle(ctx context.Context, eventId, articleId string) error {
+	args := m.Called(ctx, eventId, articleId)
+	return args.Error(0)
+}
@@ -0,0 +1,34 @@
+package storagemocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/krixlion/dev_forum-user/pkg/storage"
+	"github.com/stretchr/testify/mock"
+)
+
+type IdempotencyStorage struct {
+	*mock.Mock
+}
+
+func NewIdempotencyStorage() IdempotencyStorage {
+	return IdempotencyStorage{
+		Mock: new(mock.Mock),
+	}
+}
+
+func (m IdempotencyStorage) ReserveIdempotencyKey(ctx context.Context, key string, fingerprint []byte, ttl time.Duration) (storage.IdempotencyRecord, error) {
+	args := m.Called(ctx, key, fingerprint, ttl)
+	return args.Get(0).(storage.IdempotencyRecord), args.Error(1)
+}
+
+func (m IdempotencyStorage) SaveIdempotencyResponse(ctx context.Context, key string, response []byte, ttl time.Duration) error {
+	args := m.Called(ctx, key, response, ttl)
+	return args.Error(0)
+}
+
+func (m IdempotencyStorage) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
@@ -2,9 +2,11 @@ package storagemocks
 
 import (
 	"context"
+	"time"
 
 	"github.com/krixlion/dev_forum-lib/filter"
 	"github.com/krixlion/dev_forum-user/pkg/entity"
+	"github.com/krixlion/dev_forum-user/pkg/storage"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -23,27 +25,62 @@ func (m Storage) Close() error {
 	return args.Error(0)
 }
 
-func (m Storage) Get(ctx context.Context, filter filter.Filter) (entity.User, error) {
-	args := m.Called(ctx, filter)
+func (m Storage) Ping(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m Storage) Get(ctx context.Context, filter filter.Filter, fields []string) (entity.User, error) {
+	args := m.Called(ctx, filter, fields)
 	return args.Get(0).(entity.User), args.Error(1)
 }
 
-func (m Storage) GetMultiple(ctx context.Context, offset, limit string, filter filter.Filter) ([]entity.User, error) {
-	args := m.Called(ctx, offset, limit, filter)
+func (m Storage) GetMultiple(ctx context.Context, offset, limit string, filter storage.FilterGroup, fields []string) ([]entity.User, error) {
+	args := m.Called(ctx, offset, limit, filter, fields)
 	return args.Get(0).([]entity.User), args.Error(1)
 }
 
-func (m Storage) Create(ctx context.Context, v entity.User) error {
-	args := m.Called(ctx, v)
+func (m Storage) Search(ctx context.Context, phrase string, offset, limit uint) ([]entity.User, error) {
+	args := m.Called(ctx, phrase, offset, limit)
+	return args.Get(0).([]entity.User), args.Error(1)
+}
+
+func (m Storage) Create(ctx context.Context, v entity.User, audit storage.AuditInfo) error {
+	args := m.Called(ctx, v, audit)
 	return args.Error(0)
 }
 
-func (m Storage) Update(ctx context.Context, v entity.User) error {
-	args := m.Called(ctx, v)
+func (m Storage) Update(ctx context.Context, v entity.User, audit storage.AuditInfo) error {
+	args := m.Called(ctx, v, audit)
 	return args.Error(0)
 }
 
-func (m Storage) Delete(ctx context.Context, id string) error {
-	args := m.Called(ctx, id)
+func (m Storage) Delete(ctx context.Context, id string, audit storage.AuditInfo) error {
+	args := m.Called(ctx, id, audit)
 	return args.Error(0)
 }
+
+func (m Storage) ListAuditEntries(ctx context.Context, userId string, from, to time.Time, offset, limit uint) ([]entity.AuditEntry, error) {
+	args := m.Called(ctx, userId, from, to, offset, limit)
+	return args.Get(0).([]entity.AuditEntry), args.Error(1)
+}
+
+func (m Storage) ListArticleIds(ctx context.Context, userId string) ([]string, error) {
+	args := m.Called(ctx, userId)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m Storage) Erase(ctx context.Context, id, tombstone string, audit storage.AuditInfo) error {
+	args := m.Called(ctx, id, tombstone, audit)
+	return args.Error(0)
+}
+
+func (m Storage) NameReleasedAt(ctx context.Context, skeleton, userId string) (time.Time, error) {
+	args := m.Called(ctx, skeleton, userId)
+	return args.Get(0).(time.Time), args.Error(1)
+}
+
+func (m Storage) EmailTaken(ctx context.Context, address, key, userId string) (bool, error) {
+	args := m.Called(ctx, address, key, userId)
+	return args.Bool(0), args.Error(1)
+}
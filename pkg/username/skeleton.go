@@ -0,0 +1,58 @@
+// Package username validates user names and tells apart ones which look alike.
+package username
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// confusables maps characters to the ones they are commonly mistaken for,
+// after names are case folded and stripped of diacritics.
+// It's a subset of the Unicode confusables (UTS #39) covering characters
+// which look like Latin letters and digits.
+var confusables = map[rune]string{
+	// Digits and symbols.
+	'0': "o", '1': "i", '|': "i", '$': "s", '@': "a",
+	// Latin.
+	'l': "i", 'ı': "i", 'ɩ': "i", 'ɑ': "a", 'ɡ': "g", 'ʋ': "u", 'ø': "o", 'ð': "d", 'ß': "ss",
+	// Greek.
+	'α': "a", 'β': "b", 'γ': "y", 'ε': "e", 'η': "n", 'ι': "i", 'κ': "k", 'ν': "v",
+	'ο': "o", 'ρ': "p", 'τ': "t", 'υ': "u", 'χ': "x", 'ω': "w",
+	// Cyrillic.
+	'а': "a", 'в': "b", 'г': "r", 'е': "e", 'з': "3", 'к': "k", 'м': "m",
+	'н': "h", 'о': "o", 'п': "n", 'р': "p", 'с': "c", 'т': "t", 'у': "y", 'х': "x", 'ь': "b",
+	'ѕ': "s", 'і': "i", 'ј': "j", 'һ': "h", 'ԁ': "d", 'ԛ': "q", 'ԝ': "w", 'ү': "y", 'ӏ': "i",
+}
+
+// sequences are replaced after confusables, since they look like single letters.
+var sequences = strings.NewReplacer("rn", "m", "vv", "w")
+
+var folder = cases.Fold()
+
+// Skeleton returns the form of the name shared by names which look like it, eg. "Krix1ion", "krixlion"
+// and "kгіхlіоn" written with Cyrillic letters. Names with equal skeletons should be treated as the same name.
+//
+// The name is normalized to NFKC, case folded, stripped of diacritics and invisible characters,
+// and its confusable characters are replaced.
+func Skeleton(name string) string {
+	name = folder.String(norm.NFKC.String(name))
+
+	var b strings.Builder
+	for _, r := range norm.NFD.String(name) {
+		switch {
+		case unicode.Is(unicode.Mn, r), unicode.Is(unicode.Cf, r), unicode.IsSpace(r):
+			continue
+		}
+
+		if s, ok := confusables[r]; ok {
+			b.WriteString(s)
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return sequences.Replace(b.String())
+}
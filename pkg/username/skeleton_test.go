@@ -0,0 +1,74 @@
+package username
+
+import "testing"
+
+func TestSkeleton(t *testing.T) {
+	tests := []struct {
+		name  string
+		a     string
+		b     string
+		equal bool
+	}{
+		{
+			name:  "Test if folds case",
+			a:     "KrixLion",
+			b:     "krixlion",
+			equal: true,
+		},
+		{
+			name:  "Test if strips diacritics",
+			a:     "krïxlìon",
+			b:     "krixlion",
+			equal: true,
+		},
+		{
+			name:  "Test if folds compatibility characters",
+			a:     "ｋｒｉｘｌｉｏｎ",
+			b:     "krixlion",
+			equal: true,
+		},
+		{
+			name:  "Test if folds Cyrillic look-alikes",
+			a:     "аdmіn",
+			b:     "admin",
+			equal: true,
+		},
+		{
+			name:  "Test if folds Greek look-alikes",
+			a:     "αdmιν",
+			b:     "admiv",
+			equal: true,
+		},
+		{
+			name:  "Test if folds look-alike digits and letters",
+			a:     "Kr1x1i0n",
+			b:     "krixlion",
+			equal: true,
+		},
+		{
+			name:  "Test if folds look-alike sequences",
+			a:     "rnoderator",
+			b:     "moderator",
+			equal: true,
+		},
+		{
+			name:  "Test if strips invisible characters and spaces",
+			a:     "krix\u200blion ",
+			b:     "krix lion",
+			equal: true,
+		},
+		{
+			name: "Test if tells apart different names",
+			a:    "krixlion",
+			b:    "krixlian",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := Skeleton(tt.a), Skeleton(tt.b)
+			if (a == b) != tt.equal {
+				t.Errorf("Skeleton(%q) = %q, Skeleton(%q) = %q, want equal %v", tt.a, a, tt.b, b, tt.equal)
+			}
+		})
+	}
+}
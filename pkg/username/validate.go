@@ -0,0 +1,58 @@
+package username
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Length bounds of names in characters.
+const (
+	MinLength = 3
+	MaxLength = 32
+)
+
+// allowedPunctuation may appear in names along with letters, digits and single spaces.
+const allowedPunctuation = "_-."
+
+// ErrInvalid is wrapped by errors describing why a name is not valid.
+var ErrInvalid = errors.New("invalid name")
+
+// Normalize returns the name in Unicode NFKC, so that eg. fullwidth letters are stored
+// as their regular forms, or an error wrapping ErrInvalid if the normalized name:
+//   - is shorter than MinLength or longer than MaxLength characters,
+//   - starts or ends with whitespace or has consecutive spaces,
+//   - has characters other than letters, digits, combining marks, spaces and allowedPunctuation,
+//     eg. control characters or symbols.
+func Normalize(name string) (string, error) {
+	name = norm.NFKC.String(name)
+
+	if n := utf8.RuneCountInString(name); n < MinLength || n > MaxLength {
+		return "", fmt.Errorf("%w: must be between %d and %d characters long", ErrInvalid, MinLength, MaxLength)
+	}
+
+	if strings.TrimSpace(name) != name {
+		return "", fmt.Errorf("%w: must not start or end with whitespace", ErrInvalid)
+	}
+
+	if strings.Contains(name, "  ") {
+		return "", fmt.Errorf("%w: must not contain consecutive spaces", ErrInvalid)
+	}
+
+	for _, r := range name {
+		if !allowed(r) {
+			return "", fmt.Errorf("%w: must not contain %q", ErrInvalid, r)
+		}
+	}
+
+	return name, nil
+}
+
+func allowed(r rune) bool {
+	return unicode.IsLetter(r) || unicode.Is(unicode.Nd, r) || unicode.Is(unicode.M, r) ||
+		r == ' ' || strings.ContainsRune(allowedPunctuation, r)
+}
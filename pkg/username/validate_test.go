@@ -0,0 +1,98 @@
+package username
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		arg     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Test if accepts letters, digits, punctuation and spaces",
+			arg:  "krix lion_1.0-b",
+			want: "krix lion_1.0-b",
+		},
+		{
+			name: "Test if accepts letters of other scripts",
+			arg:  "Żółć 漢字",
+			want: "Żółć 漢字",
+		},
+		{
+			name: "Test if normalizes to NFKC",
+			arg:  "ｋｒｉｘｌｉｏｎ",
+			want: "krixlion",
+		},
+		{
+			name: "Test if composes combining marks",
+			arg:  "kri\u0301x",
+			want: "kr\u00edx",
+		},
+		{
+			name:    "Test if rejects HTML",
+			arg:     "<b>krixlion</b>",
+			wantErr: true,
+		},
+		{
+			name:    "Test if rejects too short names",
+			arg:     "kr",
+			wantErr: true,
+		},
+		{
+			name:    "Test if rejects too long names",
+			arg:     "krixlionkrixlionkrixlionkrixlion1",
+			wantErr: true,
+		},
+		{
+			name: "Test if counts characters instead of bytes",
+			arg:  "żżżżżżżżżżżżżżżżżżżżżżżżżżżżżżżż",
+			want: "żżżżżżżżżżżżżżżżżżżżżżżżżżżżżżżż",
+		},
+		{
+			name:    "Test if rejects leading whitespace",
+			arg:     " krixlion",
+			wantErr: true,
+		},
+		{
+			name:    "Test if rejects trailing whitespace normalized to spaces",
+			arg:     "krixlion\u3000",
+			wantErr: true,
+		},
+		{
+			name:    "Test if rejects consecutive spaces",
+			arg:     "krix  lion",
+			wantErr: true,
+		},
+		{
+			name:    "Test if rejects control characters",
+			arg:     "krix\nlion",
+			wantErr: true,
+		},
+		{
+			name:    "Test if rejects invisible characters",
+			arg:     "krix\u200blion",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.arg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Normalize() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if err != nil && !errors.Is(err, ErrInvalid) {
+				t.Errorf("Normalize() error = %v, want wrapped %v", err, ErrInvalid)
+			}
+
+			if got != tt.want {
+				t.Errorf("Normalize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
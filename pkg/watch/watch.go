@@ -0,0 +1,396 @@
+// Package watch notifies subscribers about changes of users made on any replica,
+// as announced by the user events consumed from the broker.
+package watch
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/logging"
+	"github.com/krixlion/dev_forum-user/pkg/domain"
+	"github.com/krixlion/dev_forum-user/pkg/entity"
+)
+
+var (
+	// ErrInvalidToken is returned for tokens which were not issued by any hub.
+	ErrInvalidToken = errors.New("invalid watch token")
+	// ErrTokenExpired is returned for tokens issued by another replica or a previous run of this one,
+	// or ones after which changes are no longer retained.
+	ErrTokenExpired = errors.New("changes since the watch token are no longer retained")
+	// ErrSlowConsumer is returned to subscribers whose buffer overflowed.
+	ErrSlowConsumer = errors.New("watch subscriber fell behind")
+	// ErrClosed is returned to subscribers of a closed hub.
+	ErrClosed = errors.New("watch hub closed")
+)
+
+// EventTypes are the types of events announcing changes of users.
+var EventTypes = []event.EventType{event.UserCreated, event.UserUpdated, event.UserDeleted, domain.UserErased}
+
+type Config struct {
+	// Queue is prepended to the names of queues consumed from.
+	// It has to be unique to the replica, so that every replica receives all changes.
+	Queue string
+	// BufferSize limits changes waiting to be sent to a single subscriber.
+	BufferSize int
+	// HistorySize limits retained changes which subscribers can resume watching from.
+	HistorySize int
+	// HeartbeatInterval is how often heartbeats are sent to subscribers.
+	HeartbeatInterval time.Duration
+}
+
+type Dependencies struct {
+	Broker event.Broker
+	Logger logging.Logger
+}
+
+// Change of a user holding only public fields.
+// Deleted users have only the id set.
+type Change struct {
+	// Seq orders changes received by the hub.
+	Seq  uint64
+	Type event.EventType
+	User entity.User
+	Time time.Time
+}
+
+// Notification is sent to subscribers. It's a heartbeat if Change is nil.
+type Notification struct {
+	// Token allows resuming watching after this notification.
+	Token  string
+	Change *Change
+	// Time is when the heartbeat was sent.
+	Time time.Time
+}
+
+// Filter selects changes sent to a subscriber. Empty fields match all changes.
+type Filter struct {
+	UserIds []string
+	Types   []event.EventType
+}
+
+func (f Filter) matches(c Change) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, c.Type) {
+		return false
+	}
+
+	if len(f.UserIds) > 0 && !slices.Contains(f.UserIds, c.User.Id) {
+		return false
+	}
+
+	return true
+}
+
+// Hub fans out changes to subscribers and retains the latest ones,
+// so that subscribers can resume watching after reconnecting to the same replica.
+type Hub struct {
+	config Config
+	broker event.Broker
+	logger logging.Logger
+	now    func() time.Time
+	// epoch tells apart tokens issued by different replicas and runs.
+	epoch string
+
+	mu  sync.Mutex
+	seq uint64
+	// history holds the latest changes ordered by Seq.
+	history     []Change
+	subscribers map[*subscriber]struct{}
+	closed      bool
+}
+
+type subscriber struct {
+	filter  Filter
+	changes chan Change
+	// done is closed once the hub removes the subscriber, with err telling why.
+	done chan struct{}
+	err  error
+}
+
+func New(config Config, d Dependencies) *Hub {
+	return &Hub{
+		config:      config,
+		broker:      d.Broker,
+		logger:      d.Logger,
+		now:         time.Now,
+		epoch:       newEpoch(),
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// Consume starts consuming user events from the broker until the context is done.
+func (h *Hub) Consume(ctx context.Context) error {
+	for _, eType := range EventTypes {
+		queue := fmt.Sprintf("%s.%s", h.config.Queue, eType)
+
+		events, err := h.broker.Consume(ctx, queue, eType)
+		if err != nil {
+			return fmt.Errorf("failed to consume %s events: %w", eType, err)
+		}
+
+		// Events are not passed through the dispatcher,
+		// which could reorder them by handling each in a separate goroutine.
+		go h.forward(ctx, events)
+	}
+
+	return nil
+}
+
+func (h *Hub) forward(ctx context.Context, events <-chan event.Event) {
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			h.Handle(e)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Handle notifies subscribers about the change announced by the event.
+func (h *Hub) Handle(e event.Event) {
+	change, err := changeFromEvent(e)
+	if err != nil {
+		h.logger.Log(context.Background(), "Skipping malformed user event", "type", e.Type, "err", err)
+		return
+	}
+
+	h.publish(change)
+}
+
+func (h *Hub) publish(c Change) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	h.seq++
+	c.Seq = h.seq
+
+	h.history = append(h.history, c)
+	if len(h.history) > h.config.HistorySize {
+		h.history = slices.Delete(h.history, 0, len(h.history)-h.config.HistorySize)
+	}
+
+	for s := range h.subscribers {
+		if !s.filter.matches(c) {
+			continue
+		}
+
+		select {
+		case s.changes <- c:
+		default:
+			h.remove(s, ErrSlowConsumer)
+		}
+	}
+}
+
+// Watch sends retained changes made after the since token, if it's not empty, and then every new change
+// matching the filter until the context is done or send fails. Heartbeats are sent while there are no changes.
+// Subscribers which do not keep up with changes are removed with ErrSlowConsumer.
+func (h *Hub) Watch(ctx context.Context, since string, filter Filter, send func(Notification) error) error {
+	s, backlog, last, err := h.subscribe(since, filter)
+	if err != nil {
+		return err
+	}
+	defer h.unsubscribe(s)
+
+	for _, c := range backlog {
+		c := c
+		if err := send(Notification{Token: h.token(c.Seq), Change: &c}); err != nil {
+			return err
+		}
+		last = c.Seq
+	}
+
+	ticker := time.NewTicker(h.config.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case c := <-s.changes:
+			if err := send(Notification{Token: h.token(c.Seq), Change: &c}); err != nil {
+				return err
+			}
+			last = c.Seq
+		case <-ticker.C:
+			if err := send(Notification{Token: h.token(h.position(s, last)), Time: h.now()}); err != nil {
+				return err
+			}
+		case <-s.done:
+			return s.err
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// subscribe registers a subscriber and returns the retained changes it missed
+// along with the sequence number it has seen all changes up to.
+func (h *Hub) subscribe(since string, filter Filter) (*subscriber, []Change, uint64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, nil, 0, ErrClosed
+	}
+
+	last := h.seq
+	var backlog []Change
+
+	if since != "" {
+		epoch, seq, err := parseToken(since)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+
+		if epoch != h.epoch {
+			return nil, nil, 0, ErrTokenExpired
+		}
+
+		if seq > h.seq {
+			return nil, nil, 0, ErrInvalidToken
+		}
+
+		// Every change after the token has to be retained.
+		if seq < h.seq-uint64(len(h.history)) {
+			return nil, nil, 0, ErrTokenExpired
+		}
+
+		for _, c := range h.history {
+			if c.Seq > seq && filter.matches(c) {
+				backlog = append(backlog, c)
+			}
+		}
+		last = seq
+	}
+
+	s := &subscriber{
+		filter:  filter,
+		changes: make(chan Change, h.config.BufferSize),
+		done:    make(chan struct{}),
+	}
+	h.subscribers[s] = struct{}{}
+
+	return s, backlog, last, nil
+}
+
+func (h *Hub) unsubscribe(s *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.subscribers, s)
+}
+
+// remove must be called with the lock held.
+func (h *Hub) remove(s *subscriber, err error) {
+	delete(h.subscribers, s)
+	s.err = err
+	close(s.done)
+}
+
+// position returns the sequence number the subscriber has seen all changes up to.
+// If none are waiting to be sent, it has seen all changes, including the ones it filters out.
+func (h *Hub) position(s *subscriber, last uint64) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(s.changes) == 0 {
+		return h.seq
+	}
+	return last
+}
+
+// Close removes all subscribers with ErrClosed and stops accepting new ones.
+func (h *Hub) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for s := range h.subscribers {
+		h.remove(s, ErrClosed)
+	}
+
+	return nil
+}
+
+func (h *Hub) token(seq uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(h.epoch + "." + strconv.FormatUint(seq, 10)))
+}
+
+func parseToken(token string) (string, uint64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", 0, ErrInvalidToken
+	}
+
+	epoch, seq, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return "", 0, ErrInvalidToken
+	}
+
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return "", 0, ErrInvalidToken
+	}
+
+	return epoch, n, nil
+}
+
+func newEpoch() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// changeFromEvent returns the change without any of the user's private fields.
+func changeFromEvent(e event.Event) (Change, error) {
+	change := Change{
+		Type: e.Type,
+		Time: e.Timestamp,
+	}
+
+	switch e.Type {
+	case event.UserCreated, event.UserUpdated, domain.UserErased:
+		var user entity.User
+		if err := json.Unmarshal(e.Body, &user); err != nil {
+			return Change{}, err
+		}
+
+		change.User = entity.User{
+			Id:        user.Id,
+			Name:      user.Name,
+			CreatedAt: user.CreatedAt,
+			UpdatedAt: user.UpdatedAt,
+		}
+	case event.UserDeleted:
+		if err := json.Unmarshal(e.Body, &change.User.Id); err != nil {
+			return Change{}, err
+		}
+	default:
+		return Change{}, fmt.Errorf("unexpected event type %q", e.Type)
+	}
+
+	if change.User.Id == "" {
+		return Change{}, errors.New("missing user id")
+	}
+
+	return change, nil
+}
@@ -0,0 +1,335 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	"github.com/krixlion/dev_forum-user/pkg/domain"
+	"github.com/krixlion/dev_forum-user/pkg/entity"
+)
+
+func setUpHub(config Config) *Hub {
+	if config.HeartbeatInterval == 0 {
+		config.HeartbeatInterval = time.Hour
+	}
+	return New(config, Dependencies{Logger: nulls.NullLogger{}})
+}
+
+func makeEvent(t *testing.T, eType event.EventType, body interface{}) event.Event {
+	t.Helper()
+	e, err := event.MakeEvent(event.UserAggregate, eType, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// watch runs Watch in the background and returns the channels receiving
+// the sent notifications and the error Watch returned.
+func watch(ctx context.Context, h *Hub, since string, filter Filter) (<-chan Notification, <-chan error) {
+	notifications := make(chan Notification, 100)
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- h.Watch(ctx, since, filter, func(n Notification) error {
+			notifications <- n
+			return nil
+		})
+	}()
+
+	return notifications, errs
+}
+
+// waitForSubscribers blocks until the hub has n subscribers.
+func waitForSubscribers(t *testing.T, h *Hub, n int) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		h.mu.Lock()
+		got := len(h.subscribers)
+		h.mu.Unlock()
+		if got == n {
+			return
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+	t.Fatalf("hub has no %d subscribers", n)
+}
+
+func receive(t *testing.T, notifications <-chan Notification) Notification {
+	t.Helper()
+	select {
+	case n := <-notifications:
+		return n
+	case <-time.After(time.Second):
+		t.Fatal("no notification received")
+		return Notification{}
+	}
+}
+
+func TestHub_Watch(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  Filter
+		publish []entity.User
+		want    []string
+	}{
+		{
+			name:    "Test if sends changes of all users without a filter",
+			publish: []entity.User{{Id: "1"}, {Id: "2"}},
+			want:    []string{"1", "2"},
+		},
+		{
+			name:    "Test if sends changes of only the filtered users",
+			filter:  Filter{UserIds: []string{"2", "3"}},
+			publish: []entity.User{{Id: "1"}, {Id: "2"}, {Id: "3"}},
+			want:    []string{"2", "3"},
+		},
+		{
+			name:    "Test if sends no changes of filtered out types",
+			filter:  Filter{Types: []event.EventType{event.UserDeleted}},
+			publish: []entity.User{{Id: "1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			h := setUpHub(Config{BufferSize: 10, HistorySize: 10})
+			notifications, _ := watch(ctx, h, "", tt.filter)
+			waitForSubscribers(t, h, 1)
+
+			for _, user := range tt.publish {
+				h.Handle(makeEvent(t, event.UserUpdated, user))
+			}
+
+			var got []string
+			for range tt.want {
+				got = append(got, receive(t, notifications).Change.User.Id)
+			}
+
+			if !cmp.Equal(got, tt.want) {
+				t.Errorf("Hub.Watch() changes = %v, want %v", got, tt.want)
+			}
+
+			select {
+			case n := <-notifications:
+				t.Errorf("Hub.Watch() sent an unexpected notification %+v", n)
+			default:
+			}
+		})
+	}
+}
+
+func TestHub_Watch_resume(t *testing.T) {
+	h := setUpHub(Config{BufferSize: 10, HistorySize: 2})
+	for _, id := range []string{"1", "2", "3"} {
+		h.Handle(makeEvent(t, event.UserCreated, entity.User{Id: id}))
+	}
+
+	otherHub := setUpHub(Config{})
+
+	tests := []struct {
+		name    string
+		since   string
+		want    []string
+		wantErr error
+	}{
+		{
+			name:  "Test if sends retained changes after the token",
+			since: h.token(1),
+			want:  []string{"2", "3"},
+		},
+		{
+			name:  "Test if sends no changes after the latest token",
+			since: h.token(3),
+		},
+		{
+			name:    "Test if fails when changes after the token are no longer retained",
+			since:   h.token(0),
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name:    "Test if fails on tokens of other hubs",
+			since:   otherHub.token(1),
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name:    "Test if fails on tokens ahead of the hub",
+			since:   h.token(4),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "Test if fails on malformed tokens",
+			since:   "not a token",
+			wantErr: ErrInvalidToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			notifications, errs := watch(ctx, h, tt.since, Filter{})
+
+			if tt.wantErr != nil {
+				select {
+				case err := <-errs:
+					if !errors.Is(err, tt.wantErr) {
+						t.Errorf("Hub.Watch() error = %v, wantErr %v", err, tt.wantErr)
+					}
+				case <-time.After(time.Second):
+					t.Errorf("Hub.Watch() did not fail")
+				}
+				return
+			}
+
+			var got []string
+			for range tt.want {
+				got = append(got, receive(t, notifications).Change.User.Id)
+			}
+
+			if !cmp.Equal(got, tt.want) {
+				t.Errorf("Hub.Watch() changes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHub_Watch_slowConsumer(t *testing.T) {
+	h := setUpHub(Config{BufferSize: 1, HistorySize: 10})
+
+	sending, release := make(chan struct{}, 1), make(chan struct{})
+	errs := make(chan error, 1)
+	go func() {
+		errs <- h.Watch(context.Background(), "", Filter{}, func(Notification) error {
+			// Block the subscriber until the buffer overflows.
+			select {
+			case sending <- struct{}{}:
+			default:
+			}
+			<-release
+			return nil
+		})
+	}()
+	waitForSubscribers(t, h, 1)
+
+	h.Handle(makeEvent(t, event.UserDeleted, "1"))
+	<-sending
+
+	// The first change is being sent, the second one is buffered and the third one overflows the buffer.
+	h.Handle(makeEvent(t, event.UserDeleted, "2"))
+	h.Handle(makeEvent(t, event.UserDeleted, "3"))
+	close(release)
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrSlowConsumer) {
+			t.Errorf("Hub.Watch() error = %v, want %v", err, ErrSlowConsumer)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Hub.Watch() did not remove the slow subscriber")
+	}
+}
+
+func TestHub_Watch_heartbeat(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h := setUpHub(Config{BufferSize: 10, HistorySize: 10, HeartbeatInterval: time.Millisecond * 10})
+	notifications, _ := watch(ctx, h, "", Filter{UserIds: []string{"1"}})
+	waitForSubscribers(t, h, 1)
+
+	h.Handle(makeEvent(t, event.UserDeleted, "2"))
+
+	for {
+		n := receive(t, notifications)
+		if n.Change != nil {
+			t.Fatalf("Hub.Watch() sent a filtered out change %+v", n.Change)
+		}
+
+		// Heartbeats move the token past changes which were filtered out.
+		if n.Token == h.token(1) {
+			return
+		}
+	}
+}
+
+func TestHub_Close(t *testing.T) {
+	h := setUpHub(Config{BufferSize: 10, HistorySize: 10})
+	_, errs := watch(context.Background(), h, "", Filter{})
+	waitForSubscribers(t, h, 1)
+
+	if err := h.Close(); err != nil {
+		t.Fatalf("Hub.Close() error = %v", err)
+	}
+
+	if err := <-errs; !errors.Is(err, ErrClosed) {
+		t.Errorf("Hub.Watch() error = %v, want %v", err, ErrClosed)
+	}
+
+	if err := h.Watch(context.Background(), "", Filter{}, nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("Hub.Watch() after Close() error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func Test_changeFromEvent(t *testing.T) {
+	createdAt := time.Date(2026, 10, 19, 14, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		event   event.Event
+		want    entity.User
+		wantErr bool
+	}{
+		{
+			name: "Test if strips private fields",
+			event: makeEvent(t, event.UserCreated, entity.User{
+				Id:        "1",
+				Name:      "krixlion",
+				Email:     "krixlion@example.com",
+				Password:  "hash",
+				CreatedAt: createdAt,
+			}),
+			want: entity.User{Id: "1", Name: "krixlion", CreatedAt: createdAt},
+		},
+		{
+			name:  "Test if decodes tombstones of erased users",
+			event: makeEvent(t, domain.UserErased, entity.User{Id: "1", Name: "erased-1"}),
+			want:  entity.User{Id: "1", Name: "erased-1"},
+		},
+		{
+			name:  "Test if decodes ids of deleted users",
+			event: makeEvent(t, event.UserDeleted, "1"),
+			want:  entity.User{Id: "1"},
+		},
+		{
+			name:    "Test if fails on missing ids",
+			event:   makeEvent(t, event.UserUpdated, entity.User{Name: "krixlion"}),
+			wantErr: true,
+		},
+		{
+			name:    "Test if fails on unexpected event types",
+			event:   makeEvent(t, event.UserLoggedIn, "1"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := changeFromEvent(tt.event)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("changeFromEvent() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !cmp.Equal(got.User, tt.want) {
+				t.Errorf("changeFromEvent() = %+v, want %+v", got.User, tt.want)
+			}
+		})
+	}
+}